@@ -14,6 +14,22 @@ const (
 	// Use in conjunction with --allow-pod-address-override for development purposes.
 	ModelPodIPAnnotation   = "model-pod-ip"
 	ModelPodPortAnnotation = "model-pod-port"
+	// ModelPodWeightAnnotation is the annotation key used to specify the
+	// relative serving capacity of a model Pod for load balancing (defaults to 1).
+	// It is set from the weight of the resource profile of the Model.
+	ModelPodWeightAnnotation = "model-pod-weight"
+	// ModelPodDrainingAnnotation is the annotation key used to mark a model
+	// Pod that is about to be deleted. Its value is the time (RFC 3339) at
+	// which draining started. Draining Pods receive no new requests.
+	ModelPodDrainingAnnotation = "model-pod-draining"
+	// ModelPodWarmingUpAnnotation is the annotation key used to mark a model
+	// Pod that has not completed the warmup of its Model yet. Pods that are
+	// warming up receive no requests.
+	ModelPodWarmingUpAnnotation = "model-pod-warming-up"
+	// ModelPodAdapterLockAnnotation is the annotation key used to lock a
+	// model Pod while a KubeAI replica loads an adapter into it on demand.
+	// Its value is "<holder>/<expiry>" where expiry is a time (RFC 3339).
+	ModelPodAdapterLockAnnotation = "model-pod-adapter-lock"
 
 	ModelCacheEvictionFinalizer = "kubeai.org/cache-eviction"
 )
@@ -24,8 +40,17 @@ func PVCModelAnnotation(modelName string) string {
 
 const (
 	PodAdapterLabelPrefix = "adapter.kubeai.org/"
+	// PodAdapterLastUsedAnnotationPrefix is the prefix of the annotation keys
+	// used to record when an adapter that is loaded on demand was last
+	// requested (RFC 3339) so that all KubeAI replicas unload the least
+	// recently used adapters first.
+	PodAdapterLastUsedAnnotationPrefix = "adapter-last-used.kubeai.org/"
 )
 
 func PodAdapterLabel(adapterID string) string {
 	return PodAdapterLabelPrefix + adapterID
 }
+
+func PodAdapterLastUsedAnnotation(adapterID string) string {
+	return PodAdapterLastUsedAnnotationPrefix + adapterID
+}
@@ -30,6 +30,7 @@ import (
 // +kubebuilder:validation:XValidation:rule="!has(self.maxReplicas) || self.minReplicas <= self.maxReplicas", message="minReplicas should be less than or equal to maxReplicas."
 // +kubebuilder:validation:XValidation:rule="!has(self.adapters) || self.engine == \"VLLM\"", message="adapters only supported with VLLM engine."
 // +kubebuilder:validation:XValidation:rule="!has(oldSelf.cacheProfile) || self.url == oldSelf.url", message="url is immutable when using cacheProfile."
+// +kubebuilder:validation:XValidation:rule="!has(self.scalingMetrics) || self.engine == \"VLLM\" || !self.scalingMetrics.exists(m, m.type == \"VLLMRequestsWaiting\")", message="VLLMRequestsWaiting scaling metric only supported with VLLM engine."
 // +NOTE: The self.files.all() check is considered "costly" by the Kubernetes API server and will be rejected if the number of files (and length of .path) are not restricted. These restrictions are applied in field-based validations below.
 // +kubebuilder:validation:XValidation:rule="!has(self.files) || self.files.size() <= 1 || !self.files.exists(f, self.files.filter(other, other.path == f.path).size() > 1)", message="All file paths must be unique."
 // +TODO: Limits on total file size should be less than limit of total ConfigMap (1MiB) data, this fails in version 1.29 (for exceeding "cost"): "!has(self.files) || self.files.map(f, size(f.content)).sum() <= 500000"
@@ -56,6 +57,10 @@ type ModelSpec struct {
 
 	Adapters []Adapter `json:"adapters,omitempty"`
 
+	// AdapterLoading configures when adapters are loaded into the model Pods.
+	// +kubebuilder:default={}
+	AdapterLoading AdapterLoading `json:"adapterLoading,omitempty"`
+
 	// Features that the model supports.
 	// Dictates the APIs that are available for the model.
 	Features []ModelFeature `json:"features"`
@@ -91,7 +96,7 @@ type ModelSpec struct {
 
 	// Replicas is the number of Pod replicas that should be actively
 	// serving the model. KubeAI will manage this field unless AutoscalingDisabled
-	// is set to true.
+	// is set to true or the AutoscalingMode is Recommend.
 	Replicas *int32 `json:"replicas,omitempty"`
 
 	// MinReplicas is the minimum number of Pod replicas that the model can scale down to.
@@ -109,6 +114,15 @@ type ModelSpec struct {
 	// for the Model. When disabled, metrics will not be collected on server Pods.
 	AutoscalingDisabled bool `json:"autoscalingDisabled,omitempty"`
 
+	// AutoscalingMode of the Model. In Auto mode the autoscaler scales the
+	// Model. In Recommend mode the autoscaler only publishes the replicas
+	// that it would scale the Model to (in the autoscaling status and the
+	// kubeai.model.replicas.desired metric) and the replicas are left to be
+	// managed by the user. Ignored when AutoscalingDisabled is true.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=Auto
+	AutoscalingMode AutoscalingMode `json:"autoscalingMode,omitempty"`
+
 	// TargetRequests is average number of active requests that the autoscaler
 	// will try to maintain on model server Pods.
 	// +kubebuilder:validation:Minimum=1
@@ -120,6 +134,43 @@ type ModelSpec struct {
 	// +kubebuilder:default=30
 	ScaleDownDelaySeconds *int64 `json:"scaleDownDelaySeconds"`
 
+	// ScalingMetrics are additional metrics that the autoscaler scales the
+	// Model on. The autoscaler calculates the desired number of replicas for
+	// the active requests (see TargetRequests) and for each of the metrics
+	// and scales to the maximum.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxItems=3
+	// +listType=map
+	// +listMapKey=type
+	ScalingMetrics []ScalingMetric `json:"scalingMetrics,omitempty"`
+
+	// MovingAverage is the algorithm that the autoscaler smooths the active
+	// requests and the scaling metrics with over the autoscaling time window.
+	// Simple is the average over the window.
+	// Exponential weights recent values more (smoothing factor 2/(N+1) where
+	// N is the number of autoscaling intervals in the window) and snaps to
+	// zero after a window of zero values.
+	// Max is the maximum over the window.
+	// P90 is the 90th percentile over the window (the same as Max for
+	// windows of less than 10 autoscaling intervals).
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=Simple
+	MovingAverage MovingAverageAlgorithm `json:"movingAverage,omitempty"`
+
+	// ScalingBehavior limits how fast the autoscaler changes the replicas of
+	// the Model. By default the Model is scaled up to the desired replicas
+	// immediately and scaled down after the ScaleDownDelaySeconds.
+	// +kubebuilder:validation:Optional
+	ScalingBehavior *ScalingBehavior `json:"scalingBehavior,omitempty"`
+
+	// Schedule overrides the MinReplicas and MaxReplicas of the Model during
+	// time windows (i.e. to scale up before business hours or to scale to
+	// zero overnight). The first active window is applied.
+	// Only applies when autoscaling is enabled.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxItems=10
+	Schedule []ScheduleWindow `json:"schedule,omitempty"`
+
 	// Owner of the model. Used solely to populate the owner field in the
 	// OpenAI /v1/models endpoint.
 	// DEPRECATED.
@@ -135,11 +186,25 @@ type ModelSpec struct {
 	// +kubebuilder:validation:MaxItems=10
 	Files []File `json:"files,omitempty"`
 
+	// Warmup configures requests that are sent to each new Pod after it
+	// becomes ready and before it receives traffic.
+	// +kubebuilder:validation:Optional
+	Warmup *Warmup `json:"warmup,omitempty"`
+
 	// PriorityClassName sets the priority class for all pods created for this model.
 	// If specified, the PriorityClass must exist before the model is created.
 	// This is useful for implementing priority and preemption for models.
 	// +kubebuilder:validation:Optional
 	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// Fallback Models (in order of preference) that serve requests while this
+	// Model has no ready replicas (i.e. while scaling up from zero) or after
+	// requests to this Model repeatedly failed. Only fallback Models that have
+	// ready replicas are used. An adapter can be specified as "<model>_<adapter>".
+	// Fallback Models should support the same features as this Model.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxItems=4
+	Fallback []string `json:"fallback,omitempty"`
 }
 
 // +kubebuilder:validation:Enum=TextGeneration;TextEmbedding;Reranking;SpeechToText
@@ -170,6 +235,171 @@ type Adapter struct {
 	URL string `json:"url"`
 }
 
+type AdapterLoading struct {
+	// Mode determines when adapters are loaded into the model Pods.
+	// Eager loads every adapter into every Pod.
+	// OnDemand loads an adapter into a single Pod when a request for the
+	// adapter arrives and no Pod has it loaded. Requests for the adapter
+	// are then routed to the Pods that have it loaded.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=Eager
+	Mode AdapterLoadingMode `json:"mode,omitempty"`
+	// MaxLoadedAdapters is the maximum number of adapters that are loaded
+	// into a Pod at the same time in OnDemand mode. The least recently used
+	// adapters are unloaded to make room for new ones.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=8
+	MaxLoadedAdapters int `json:"maxLoadedAdapters,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=Eager;OnDemand
+type AdapterLoadingMode string
+
+const (
+	EagerAdapterLoadingMode    AdapterLoadingMode = "Eager"
+	OnDemandAdapterLoadingMode AdapterLoadingMode = "OnDemand"
+)
+
+// +kubebuilder:validation:Enum=Auto;Recommend
+type AutoscalingMode string
+
+const (
+	AutoAutoscalingMode      AutoscalingMode = "Auto"
+	RecommendAutoscalingMode AutoscalingMode = "Recommend"
+)
+
+// ScalingMetric is a metric that the autoscaler scales a Model on.
+// The values of the metrics are averaged over the autoscaling window
+// like the number of active requests.
+type ScalingMetric struct {
+	// Type of the metric.
+	// TokensPerSecond is the number of prompt and completion tokens that are
+	// processed per second. The Target is the number of tokens per second per replica.
+	// QueueWaitMilliseconds is the average time that requests waited for an
+	// endpoint. The Target is the wait time that the autoscaler tries to
+	// maintain by scaling the replicas at the start of the time window by
+	// the ratio of the current wait time to the Target.
+	// VLLMRequestsWaiting is the number of requests that are waiting in the
+	// queues of the engines (vLLM num_requests_waiting metric). The Target is
+	// the number of waiting requests per replica.
+	// +kubebuilder:validation:Required
+	Type ScalingMetricType `json:"type"`
+	// Target value of the metric.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Minimum=1
+	Target int64 `json:"target"`
+}
+
+// +kubebuilder:validation:Enum=TokensPerSecond;QueueWaitMilliseconds;VLLMRequestsWaiting
+type ScalingMetricType string
+
+const (
+	TokensPerSecondScalingMetric       ScalingMetricType = "TokensPerSecond"
+	QueueWaitMillisecondsScalingMetric ScalingMetricType = "QueueWaitMilliseconds"
+	VLLMRequestsWaitingScalingMetric   ScalingMetricType = "VLLMRequestsWaiting"
+)
+
+// +kubebuilder:validation:Enum=Simple;Exponential;Max;P90
+type MovingAverageAlgorithm string
+
+const (
+	SimpleMovingAverage      MovingAverageAlgorithm = "Simple"
+	ExponentialMovingAverage MovingAverageAlgorithm = "Exponential"
+	MaxMovingAverage         MovingAverageAlgorithm = "Max"
+	P90MovingAverage         MovingAverageAlgorithm = "P90"
+)
+
+// ScalingBehavior configures the scaling behavior of a Model (similar to the
+// behavior of a HorizontalPodAutoscaler). The replica bounds of the Model
+// are always applied.
+type ScalingBehavior struct {
+	// ScaleUp limits scaling up.
+	// +kubebuilder:validation:Optional
+	ScaleUp *ScaleUpBehavior `json:"scaleUp,omitempty"`
+	// ScaleDown limits scaling down. Scale downs are delayed by the
+	// ScaleDownDelaySeconds of the Model before the limits are applied.
+	// +kubebuilder:validation:Optional
+	ScaleDown *ScaleDownBehavior `json:"scaleDown,omitempty"`
+}
+
+type ScaleUpBehavior struct {
+	// StabilizationWindowSeconds is the time window of past decisions that
+	// is considered when scaling up. The Model is only scaled up to the
+	// lowest number of replicas that was desired during the window, so that
+	// short bursts do not scale up the Model.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=3600
+	StabilizationWindowSeconds int32 `json:"stabilizationWindowSeconds,omitempty"`
+	// Policies limit the number of replicas that are added per period.
+	// The policy that allows the largest change is applied.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxItems=10
+	Policies []ScalingPolicy `json:"policies,omitempty"`
+}
+
+type ScaleDownBehavior struct {
+	// Policies limit the number of replicas that are removed per period.
+	// The policy that allows the largest change is applied.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxItems=10
+	Policies []ScalingPolicy `json:"policies,omitempty"`
+}
+
+// ScalingPolicy limits the change of the replicas of a Model during a period.
+type ScalingPolicy struct {
+	// Type of the policy.
+	// Replicas limits the change to a number of replicas.
+	// Percent limits the change to a percentage of the replicas at the start
+	// of the period (at least 1 replica can always be added and the replicas
+	// that remain after a scale down are rounded down).
+	// +kubebuilder:validation:Required
+	Type ScalingPolicyType `json:"type"`
+	// Value of the policy.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Minimum=1
+	Value int32 `json:"value"`
+	// PeriodSeconds is the length of the period.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=1800
+	PeriodSeconds int32 `json:"periodSeconds"`
+}
+
+// +kubebuilder:validation:Enum=Replicas;Percent
+type ScalingPolicyType string
+
+const (
+	ReplicasScalingPolicy ScalingPolicyType = "Replicas"
+	PercentScalingPolicy  ScalingPolicyType = "Percent"
+)
+
+// ScheduleWindow overrides the replica bounds of a Model while the current
+// time matches a cron expression.
+// +kubebuilder:validation:XValidation:rule="!has(self.minReplicas) || !has(self.maxReplicas) || self.minReplicas <= self.maxReplicas", message="minReplicas should be less than or equal to maxReplicas."
+type ScheduleWindow struct {
+	// Cron expression ("<minute> <hour> <day of month> <month> <day of week>")
+	// that matches the minutes during which the window is active.
+	// Example: "* 8-17 * * MON-FRI" is active from 8:00 to 17:59 on weekdays.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=`^\S+( +\S+){4}$`
+	Cron string `json:"cron"`
+	// TimeZone of the cron expression (i.e. "America/New_York").
+	// Defaults to UTC.
+	// +kubebuilder:validation:Optional
+	TimeZone string `json:"timeZone,omitempty"`
+	// MinReplicas overrides the MinReplicas of the Model while the window is active.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+	// MaxReplicas overrides the MaxReplicas of the Model while the window is active.
+	// A value of 0 scales the Model to zero and keeps requests from scaling it up.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	MaxReplicas *int32 `json:"maxReplicas,omitempty"`
+}
+
 type LoadBalancing struct {
 	// +kubebuilder:validation:Optional
 	// +kubebuilder:default=LeastLoad
@@ -177,16 +407,93 @@ type LoadBalancing struct {
 	// +kubebuilder:validation:Optional
 	// +kubebuilder:default={}
 	PrefixHash PrefixHash `json:"prefixHash,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default={}
+	EngineMetrics EngineMetrics `json:"engineMetrics,omitempty"`
+	// SessionAffinity routes requests that belong to the same session
+	// (i.e. the turns of a chat) to the same endpoint, regardless of the strategy.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default={}
+	SessionAffinity SessionAffinity `json:"sessionAffinity,omitempty"`
+	// ZoneAffinity prefers endpoints in the same zone as the KubeAI replica
+	// that proxies a request.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default={}
+	ZoneAffinity ZoneAffinity `json:"zoneAffinity,omitempty"`
+	// MaxConcurrencyPerEndpoint is the maximum number of in-flight requests
+	// that a KubeAI replica sends to a single endpoint (scaled by the weight
+	// of the endpoint). Requests are queued while all endpoints are at
+	// their limit. Zero means unlimited.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	MaxConcurrencyPerEndpoint int `json:"maxConcurrencyPerEndpoint,omitempty"`
 }
 
-// +kubebuilder:validation:Enum=LeastLoad;PrefixHash
+// ZoneAffinity avoids cross-zone traffic by sending requests to endpoints
+// on nodes in the same zone (topology.kubernetes.io/zone label) as the KubeAI
+// replica that proxies the request. Requests are only sent to other zones
+// when all endpoints in the same zone are busy (or there are none).
+type ZoneAffinity struct {
+	// Enabled enables zone affinity.
+	// +kubebuilder:validation:Optional
+	Enabled bool `json:"enabled,omitempty"`
+	// MaxInFlightRequests is the number of in-flight requests (sent by a
+	// single KubeAI replica) at which an endpoint is considered busy.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=16
+	// +kubebuilder:validation:Minimum=1
+	MaxInFlightRequests int `json:"maxInFlightRequests,omitempty"`
+}
+
+// SessionAffinity identifies the session of a request by a header or by
+// the `user` field of the request. Sessions are hashed onto the same
+// consistent hashing ring that is used by the PrefixHash strategy (bounded by
+// prefixHash.meanLoadFactor) so that multi-turn conversations keep hitting
+// the endpoint that holds their KV cache.
+// Requests without a session are balanced according to the strategy.
+type SessionAffinity struct {
+	// Header is the name of the request header that contains the session ID
+	// (i.e. "X-Session-ID").
+	// +kubebuilder:validation:Optional
+	Header string `json:"header,omitempty"`
+	// User uses the `user` field of the request as the session ID
+	// when the header is not set.
+	// +kubebuilder:validation:Optional
+	User bool `json:"user,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=LeastLoad;PrefixHash;EngineMetrics;PowerOfTwo
 type LoadBalancingStrategy string
 
 const (
-	LeastLoadStrategy  LoadBalancingStrategy = "LeastLoad"
-	PrefixHashStrategy LoadBalancingStrategy = "PrefixHash"
+	LeastLoadStrategy     LoadBalancingStrategy = "LeastLoad"
+	PrefixHashStrategy    LoadBalancingStrategy = "PrefixHash"
+	EngineMetricsStrategy LoadBalancingStrategy = "EngineMetrics"
+	PowerOfTwoStrategy    LoadBalancingStrategy = "PowerOfTwo"
 )
 
+// EngineMetrics configures the EngineMetrics strategy which scores endpoints
+// using the metrics that are reported by the engine (only supported by vLLM)
+// in addition to the number of in-flight requests.
+// The endpoint with the lowest score is selected:
+// score = max(inFlight, runningRequests) + queueWeight * waitingRequests + kvCacheWeight * kvCacheUsage
+// Endpoints are scored by in-flight requests only when engine metrics
+// are not available for all endpoints.
+type EngineMetrics struct {
+	// QueueWeight is the weight of each request that is waiting in the
+	// queue of the engine.
+	// +kubebuilder:default=2
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	QueueWeight int `json:"queueWeight,omitempty"`
+	// KVCacheWeight is the weight of a fully utilized KV cache
+	// (scaled down linearly with the utilization).
+	// +kubebuilder:default=10
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	KVCacheWeight int `json:"kvCacheWeight,omitempty"`
+}
+
 type PrefixHash struct {
 	// MeanLoadPercentage is the percentage that any given endpoint's load must not exceed
 	// over the mean load of all endpoints in the hash ring. Defaults to 125% which is
@@ -203,9 +510,50 @@ type PrefixHash struct {
 	// +kubebuilder:validation:Optional
 	Replication int `json:"replication,omitempty"`
 	// PrefixCharLength is the number of characters to count when building the prefix to hash.
+	// In Blocks mode, it is the maximum number of characters of a block.
 	// +kubebuilder:validation:Optional
 	// +kubebuilder:default=100
 	PrefixCharLength int `json:"prefixCharLength,omitempty"`
+	// Mode determines how requests are hashed.
+	// FirstChars hashes the first characters of the first user message (or prompt).
+	// Blocks splits the messages (or prompt) into blocks and routes requests to the
+	// endpoint that most recently served the longest chain of matching blocks.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=FirstChars
+	Mode PrefixHashMode `json:"mode,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=FirstChars;Blocks
+type PrefixHashMode string
+
+const (
+	FirstCharsPrefixHashMode PrefixHashMode = "FirstChars"
+	BlocksPrefixHashMode     PrefixHashMode = "Blocks"
+)
+
+type Warmup struct {
+	// Requests are sent to the model server of a new Pod in order.
+	// The Pod only receives traffic once all requests succeeded or after
+	// the warmup failed 3 times (which is recorded as a WarmupFailed Event).
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=10
+	Requests []WarmupRequest `json:"requests"`
+	// TimeoutSeconds is the timeout of each warmup request.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=120
+	TimeoutSeconds int64 `json:"timeoutSeconds,omitempty"`
+}
+
+type WarmupRequest struct {
+	// Path of the request on the model server (i.e. "/v1/completions").
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=`^/`
+	Path string `json:"path"`
+	// Body of the request (JSON). Requests are sent with the POST method.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MaxLength=100000
+	Body string `json:"body"`
 }
 
 // File represents a file to be mounted in the model pod.
@@ -228,6 +576,10 @@ type File struct {
 type ModelStatus struct {
 	Replicas ModelStatusReplicas `json:"replicas,omitempty"`
 	Cache    *ModelStatusCache   `json:"cache,omitempty"`
+	// Budget is set when the resource profile of the Model has a budget.
+	Budget *ModelStatusBudget `json:"budget,omitempty"`
+	// Autoscaling is the last decision of the autoscaler.
+	Autoscaling *ModelStatusAutoscaling `json:"autoscaling,omitempty"`
 }
 
 type ModelStatusReplicas struct {
@@ -235,6 +587,56 @@ type ModelStatusReplicas struct {
 	Ready int32 `json:"ready"`
 }
 
+// ModelStatusBudget is the allocation of the budget of the resource profile
+// of the Model by the autoscaler.
+type ModelStatusBudget struct {
+	// Shortfall is the number of replicas that the autoscaler wanted to
+	// add to the Model but that did not fit into the budget.
+	Shortfall int32 `json:"shortfall"`
+}
+
+// ModelStatusAutoscaling explains the last decision of the autoscaler.
+type ModelStatusAutoscaling struct {
+	// Mode is the autoscaling mode that the decision was made in. In
+	// Recommend mode the replicas were not applied to the Model.
+	Mode AutoscalingMode `json:"mode,omitempty"`
+	// DesiredReplicas is the number of replicas that the autoscaler calculated
+	// from the metrics (within the replica bounds and the budget).
+	DesiredReplicas int32 `json:"desiredReplicas"`
+	// Replicas is the number of replicas after the decision (or the number
+	// of replicas that the Model would be scaled to in Recommend mode).
+	// It differs from DesiredReplicas while a scale down is delayed or
+	// while the change is limited by the ScalingBehavior of the Model.
+	Replicas int32 `json:"replicas"`
+	// Metrics are the averaged values of the metrics that the decision was based on.
+	Metrics []ModelStatusAutoscalingMetric `json:"metrics,omitempty"`
+	// Window is the time window that the metrics are averaged over.
+	Window metav1.Duration `json:"window"`
+	// PendingScaleDowns is the number of consecutive decisions to scale down
+	// that were delayed.
+	PendingScaleDowns int32 `json:"pendingScaleDowns"`
+	// RequiredScaleDowns is the number of consecutive decisions to scale
+	// down that are required before the Model is scaled down
+	// (see ScaleDownDelaySeconds).
+	RequiredScaleDowns int32 `json:"requiredScaleDowns"`
+	// LastScaleTime is the last time that the autoscaler changed the replicas
+	// (or the recommended replicas in Recommend mode).
+	LastScaleTime *metav1.Time `json:"lastScaleTime,omitempty"`
+}
+
+// ModelStatusAutoscalingMetric is the value of a metric that the autoscaler
+// used to calculate the desired replicas.
+type ModelStatusAutoscalingMetric struct {
+	// Name of the metric: ActiveRequests or the type of a scaling metric.
+	Name string `json:"name"`
+	// Value is the average value of the metric.
+	Value string `json:"value"`
+	// Target value of the metric.
+	Target int64 `json:"target"`
+	// DesiredReplicas is the number of replicas that the metric calls for.
+	DesiredReplicas int32 `json:"desiredReplicas"`
+}
+
 type ModelStatusCache struct {
 	Loaded bool `json:"loaded"`
 }
@@ -0,0 +1,84 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ModelAliasSpec defines the desired state of ModelAlias.
+type ModelAliasSpec struct {
+	// Routes are evaluated in order, the first Route that matches a request is used.
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	Routes []ModelAliasRoute `json:"routes"`
+}
+
+type ModelAliasRoute struct {
+	// Headers that a request must have (with exactly the given values) to match the Route.
+	// A Route without headers matches all requests.
+	// +kubebuilder:validation:Optional
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Backends that requests are split across by weight.
+	// Requests that identify an end-user (via the "user" field or an API key)
+	// are consistently sent to the same backend, as long as the weights
+	// do not change.
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	Backends []ModelAliasBackend `json:"backends"`
+}
+
+type ModelAliasBackend struct {
+	// Model is the name of the Model that serves requests.
+	// An adapter can be specified as "<model>_<adapter>".
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Model string `json:"model"`
+
+	// Weight of the backend relative to the other backends of the Route.
+	// A weight of 0 sends no traffic to the backend. Routes whose backends
+	// all have a weight of 0 are skipped.
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=1000000
+	Weight int32 `json:"weight"`
+}
+
+// ModelAlias resources provide a stable model name that is resolved into
+// one of several backing Models (i.e. to roll out a new model version).
+// +kubebuilder:object:root=true
+// +kubebuilder:validation:XValidation:rule="size(self.metadata.name) <= 40", message="name must not exceed 40 characters."
+type ModelAlias struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ModelAliasSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ModelAliasList contains a list of ModelAliases.
+type ModelAliasList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ModelAlias `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ModelAlias{}, &ModelAliasList{})
+}
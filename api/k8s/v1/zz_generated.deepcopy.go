@@ -40,6 +40,36 @@ func (in *Adapter) DeepCopy() *Adapter {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AdapterLoading) DeepCopyInto(out *AdapterLoading) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdapterLoading.
+func (in *AdapterLoading) DeepCopy() *AdapterLoading {
+	if in == nil {
+		return nil
+	}
+	out := new(AdapterLoading)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EngineMetrics) DeepCopyInto(out *EngineMetrics) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EngineMetrics.
+func (in *EngineMetrics) DeepCopy() *EngineMetrics {
+	if in == nil {
+		return nil
+	}
+	out := new(EngineMetrics)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *File) DeepCopyInto(out *File) {
 	*out = *in
@@ -59,6 +89,9 @@ func (in *File) DeepCopy() *File {
 func (in *LoadBalancing) DeepCopyInto(out *LoadBalancing) {
 	*out = *in
 	out.PrefixHash = in.PrefixHash
+	out.EngineMetrics = in.EngineMetrics
+	out.SessionAffinity = in.SessionAffinity
+	out.ZoneAffinity = in.ZoneAffinity
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadBalancing.
@@ -98,6 +131,128 @@ func (in *Model) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ModelAlias) DeepCopyInto(out *ModelAlias) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ModelAlias.
+func (in *ModelAlias) DeepCopy() *ModelAlias {
+	if in == nil {
+		return nil
+	}
+	out := new(ModelAlias)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ModelAlias) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ModelAliasBackend) DeepCopyInto(out *ModelAliasBackend) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ModelAliasBackend.
+func (in *ModelAliasBackend) DeepCopy() *ModelAliasBackend {
+	if in == nil {
+		return nil
+	}
+	out := new(ModelAliasBackend)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ModelAliasList) DeepCopyInto(out *ModelAliasList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ModelAlias, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ModelAliasList.
+func (in *ModelAliasList) DeepCopy() *ModelAliasList {
+	if in == nil {
+		return nil
+	}
+	out := new(ModelAliasList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ModelAliasList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ModelAliasRoute) DeepCopyInto(out *ModelAliasRoute) {
+	*out = *in
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Backends != nil {
+		in, out := &in.Backends, &out.Backends
+		*out = make([]ModelAliasBackend, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ModelAliasRoute.
+func (in *ModelAliasRoute) DeepCopy() *ModelAliasRoute {
+	if in == nil {
+		return nil
+	}
+	out := new(ModelAliasRoute)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ModelAliasSpec) DeepCopyInto(out *ModelAliasSpec) {
+	*out = *in
+	if in.Routes != nil {
+		in, out := &in.Routes, &out.Routes
+		*out = make([]ModelAliasRoute, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ModelAliasSpec.
+func (in *ModelAliasSpec) DeepCopy() *ModelAliasSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ModelAliasSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ModelList) DeepCopyInto(out *ModelList) {
 	*out = *in
@@ -138,6 +293,7 @@ func (in *ModelSpec) DeepCopyInto(out *ModelSpec) {
 		*out = make([]Adapter, len(*in))
 		copy(*out, *in)
 	}
+	out.AdapterLoading = in.AdapterLoading
 	if in.Features != nil {
 		in, out := &in.Features, &out.Features
 		*out = make([]ModelFeature, len(*in))
@@ -182,12 +338,39 @@ func (in *ModelSpec) DeepCopyInto(out *ModelSpec) {
 		*out = new(int64)
 		**out = **in
 	}
+	if in.ScalingMetrics != nil {
+		in, out := &in.ScalingMetrics, &out.ScalingMetrics
+		*out = make([]ScalingMetric, len(*in))
+		copy(*out, *in)
+	}
+	if in.ScalingBehavior != nil {
+		in, out := &in.ScalingBehavior, &out.ScalingBehavior
+		*out = new(ScalingBehavior)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Schedule != nil {
+		in, out := &in.Schedule, &out.Schedule
+		*out = make([]ScheduleWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	out.LoadBalancing = in.LoadBalancing
 	if in.Files != nil {
 		in, out := &in.Files, &out.Files
 		*out = make([]File, len(*in))
 		copy(*out, *in)
 	}
+	if in.Warmup != nil {
+		in, out := &in.Warmup, &out.Warmup
+		*out = new(Warmup)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Fallback != nil {
+		in, out := &in.Fallback, &out.Fallback
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ModelSpec.
@@ -209,6 +392,16 @@ func (in *ModelStatus) DeepCopyInto(out *ModelStatus) {
 		*out = new(ModelStatusCache)
 		**out = **in
 	}
+	if in.Budget != nil {
+		in, out := &in.Budget, &out.Budget
+		*out = new(ModelStatusBudget)
+		**out = **in
+	}
+	if in.Autoscaling != nil {
+		in, out := &in.Autoscaling, &out.Autoscaling
+		*out = new(ModelStatusAutoscaling)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ModelStatus.
@@ -221,6 +414,61 @@ func (in *ModelStatus) DeepCopy() *ModelStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ModelStatusAutoscaling) DeepCopyInto(out *ModelStatusAutoscaling) {
+	*out = *in
+	if in.Metrics != nil {
+		in, out := &in.Metrics, &out.Metrics
+		*out = make([]ModelStatusAutoscalingMetric, len(*in))
+		copy(*out, *in)
+	}
+	out.Window = in.Window
+	if in.LastScaleTime != nil {
+		in, out := &in.LastScaleTime, &out.LastScaleTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ModelStatusAutoscaling.
+func (in *ModelStatusAutoscaling) DeepCopy() *ModelStatusAutoscaling {
+	if in == nil {
+		return nil
+	}
+	out := new(ModelStatusAutoscaling)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ModelStatusAutoscalingMetric) DeepCopyInto(out *ModelStatusAutoscalingMetric) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ModelStatusAutoscalingMetric.
+func (in *ModelStatusAutoscalingMetric) DeepCopy() *ModelStatusAutoscalingMetric {
+	if in == nil {
+		return nil
+	}
+	out := new(ModelStatusAutoscalingMetric)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ModelStatusBudget) DeepCopyInto(out *ModelStatusBudget) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ModelStatusBudget.
+func (in *ModelStatusBudget) DeepCopy() *ModelStatusBudget {
+	if in == nil {
+		return nil
+	}
+	out := new(ModelStatusBudget)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ModelStatusCache) DeepCopyInto(out *ModelStatusCache) {
 	*out = *in
@@ -265,3 +513,188 @@ func (in *PrefixHash) DeepCopy() *PrefixHash {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScaleDownBehavior) DeepCopyInto(out *ScaleDownBehavior) {
+	*out = *in
+	if in.Policies != nil {
+		in, out := &in.Policies, &out.Policies
+		*out = make([]ScalingPolicy, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScaleDownBehavior.
+func (in *ScaleDownBehavior) DeepCopy() *ScaleDownBehavior {
+	if in == nil {
+		return nil
+	}
+	out := new(ScaleDownBehavior)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScaleUpBehavior) DeepCopyInto(out *ScaleUpBehavior) {
+	*out = *in
+	if in.Policies != nil {
+		in, out := &in.Policies, &out.Policies
+		*out = make([]ScalingPolicy, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScaleUpBehavior.
+func (in *ScaleUpBehavior) DeepCopy() *ScaleUpBehavior {
+	if in == nil {
+		return nil
+	}
+	out := new(ScaleUpBehavior)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScalingBehavior) DeepCopyInto(out *ScalingBehavior) {
+	*out = *in
+	if in.ScaleUp != nil {
+		in, out := &in.ScaleUp, &out.ScaleUp
+		*out = new(ScaleUpBehavior)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ScaleDown != nil {
+		in, out := &in.ScaleDown, &out.ScaleDown
+		*out = new(ScaleDownBehavior)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScalingBehavior.
+func (in *ScalingBehavior) DeepCopy() *ScalingBehavior {
+	if in == nil {
+		return nil
+	}
+	out := new(ScalingBehavior)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScalingMetric) DeepCopyInto(out *ScalingMetric) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScalingMetric.
+func (in *ScalingMetric) DeepCopy() *ScalingMetric {
+	if in == nil {
+		return nil
+	}
+	out := new(ScalingMetric)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScalingPolicy) DeepCopyInto(out *ScalingPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScalingPolicy.
+func (in *ScalingPolicy) DeepCopy() *ScalingPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ScalingPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScheduleWindow) DeepCopyInto(out *ScheduleWindow) {
+	*out = *in
+	if in.MinReplicas != nil {
+		in, out := &in.MinReplicas, &out.MinReplicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxReplicas != nil {
+		in, out := &in.MaxReplicas, &out.MaxReplicas
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScheduleWindow.
+func (in *ScheduleWindow) DeepCopy() *ScheduleWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(ScheduleWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SessionAffinity) DeepCopyInto(out *SessionAffinity) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SessionAffinity.
+func (in *SessionAffinity) DeepCopy() *SessionAffinity {
+	if in == nil {
+		return nil
+	}
+	out := new(SessionAffinity)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Warmup) DeepCopyInto(out *Warmup) {
+	*out = *in
+	if in.Requests != nil {
+		in, out := &in.Requests, &out.Requests
+		*out = make([]WarmupRequest, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Warmup.
+func (in *Warmup) DeepCopy() *Warmup {
+	if in == nil {
+		return nil
+	}
+	out := new(Warmup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WarmupRequest) DeepCopyInto(out *WarmupRequest) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WarmupRequest.
+func (in *WarmupRequest) DeepCopy() *WarmupRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(WarmupRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZoneAffinity) DeepCopyInto(out *ZoneAffinity) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZoneAffinity.
+func (in *ZoneAffinity) DeepCopy() *ZoneAffinity {
+	if in == nil {
+		return nil
+	}
+	out := new(ZoneAffinity)
+	in.DeepCopyInto(out)
+	return out
+}
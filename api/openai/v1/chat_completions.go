@@ -522,6 +522,30 @@ func (r *ChatCompletionRequest) SetModel(m string) {
 	r.Model = m
 }
 
+func (r *ChatCompletionRequest) GetUser() string {
+	return r.User
+}
+
+func (r *ChatCompletionRequest) IsStream() bool {
+	return r.Stream
+}
+
+// IncludeStreamUsage ensures that usage statistics are included in a streamed
+// response. It returns true if the request was modified.
+func (r *ChatCompletionRequest) IncludeStreamUsage() bool {
+	if !r.Stream {
+		return false
+	}
+	if r.StreamOptions == nil {
+		r.StreamOptions = &StreamOptions{}
+	}
+	if r.StreamOptions.IncludeUsage {
+		return false
+	}
+	r.StreamOptions.IncludeUsage = true
+	return true
+}
+
 func (r *ChatCompletionRequest) Prefix(n int) string {
 	if len(r.Messages) == 0 {
 		return ""
@@ -542,6 +566,30 @@ func (r *ChatCompletionRequest) Prefix(n int) string {
 	return ""
 }
 
+// PrefixBlocks splits the messages into blocks of at most n characters.
+// Every message starts a new block so that the blocks of a conversation
+// stay the same when turns are appended.
+func (r *ChatCompletionRequest) PrefixBlocks(n int) []string {
+	if n <= 0 {
+		return nil
+	}
+	var blocks []string
+	for _, m := range r.Messages {
+		var s string
+		if m.Content != nil {
+			if len(m.Content.Array) > 0 {
+				for i := 0; i < len(m.Content.Array); i++ {
+					s += m.Content.Array[i].Text
+				}
+			} else {
+				s = m.Content.String
+			}
+		}
+		blocks = append(blocks, splitNChars(m.Role+": "+s, n)...)
+	}
+	return blocks
+}
+
 // ToolType defines the type of tool that the model can use.
 type ToolType string
 
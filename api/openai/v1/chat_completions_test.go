@@ -43,6 +43,28 @@ func TestChatCompletionRequestPrefix(t *testing.T) {
 	}
 }
 
+func TestChatCompletionRequestPrefixBlocks(t *testing.T) {
+	cases := []struct {
+		input string
+		n     int
+		exp   []string
+	}{
+		{`{"messages": []}`, 10, nil},
+		{`{"messages": [{"role": "user", "content": "abc"}]}`, 0, nil},
+		{`{"messages": [{"role": "user", "content": "abc"}]}`, 10, []string{"user: abc"}},
+		{`{"messages": [{"role": "system", "content": "abcdefgh"}, {"role": "user", "content": [{"type": "text", "text": "x"}, {"type": "text", "text": "yz"}]}]}`, 6,
+			[]string{"system", ": abcd", "efgh", "user: ", "xyz"}},
+		{`{"messages": [{"role": "user", "content": "世界"}, {"role": "assistant", "content": null}]}`, 7, []string{"user: 世", "界", "assista", "nt: "}},
+	}
+	for _, c := range cases {
+		t.Run(fmt.Sprintf("%q %d", c.input, c.n), func(t *testing.T) {
+			var body v1.ChatCompletionRequest
+			require.NoError(t, json.Unmarshal([]byte(c.input), &body))
+			require.Equal(t, c.exp, body.PrefixBlocks(c.n))
+		})
+	}
+}
+
 func TestChatCompletionRequest_JSON(t *testing.T) {
 	cases := []struct {
 		name          string
@@ -94,6 +94,11 @@ type CompletionRequest struct {
 	// +optional
 	Stream bool `json:"stream,omitzero"`
 
+	// StreamOptions configures options for streaming response.
+	// Only set this when stream is true.
+	// +optional
+	StreamOptions *StreamOptions `json:"stream_options,omitzero"`
+
 	// Suffix is the suffix that comes after a completion of inserted text.
 	// +optional
 	Suffix string `json:"suffix,omitzero"`
@@ -131,10 +136,42 @@ func (r *CompletionRequest) SetModel(m string) {
 	r.Model = m
 }
 
+func (r *CompletionRequest) GetUser() string {
+	return r.User
+}
+
+func (r *CompletionRequest) IsStream() bool {
+	return r.Stream
+}
+
+// IncludeStreamUsage ensures that usage statistics are included in a streamed
+// response. It returns true if the request was modified.
+func (r *CompletionRequest) IncludeStreamUsage() bool {
+	if !r.Stream {
+		return false
+	}
+	if r.StreamOptions == nil {
+		r.StreamOptions = &StreamOptions{}
+	}
+	if r.StreamOptions.IncludeUsage {
+		return false
+	}
+	r.StreamOptions.IncludeUsage = true
+	return true
+}
+
 func (r *CompletionRequest) Prefix(n int) string {
 	return firstNChars(r.prompt0(), n)
 }
 
+// PrefixBlocks splits the prompt into blocks of at most n characters.
+func (r *CompletionRequest) PrefixBlocks(n int) []string {
+	if n <= 0 {
+		return nil
+	}
+	return splitNChars(r.prompt0(), n)
+}
+
 func (r *CompletionRequest) prompt0() string {
 	if p, ok := r.Prompt.(string); ok {
 		return p
@@ -37,6 +37,26 @@ func TestCompletionRequestPrefix(t *testing.T) {
 	}
 }
 
+func TestCompletionRequestPrefixBlocks(t *testing.T) {
+	cases := []struct {
+		input string
+		n     int
+		exp   []string
+	}{
+		{`{"prompt": ""}`, 3, nil},
+		{`{"prompt": "abc"}`, 0, nil},
+		{`{"prompt": "abcdefg"}`, 3, []string{"abc", "def", "g"}},
+		{`{"prompt": ["世界", "other"]}`, 1, []string{"世", "界"}},
+	}
+	for _, c := range cases {
+		t.Run(fmt.Sprintf("%q %d", c.input, c.n), func(t *testing.T) {
+			var req v1.CompletionRequest
+			require.NoError(t, json.Unmarshal([]byte(c.input), &req))
+			require.Equal(t, c.exp, req.PrefixBlocks(c.n))
+		})
+	}
+}
+
 func TestCompletionRequest_JSON(t *testing.T) {
 	cases := []struct {
 		name          string
@@ -51,6 +51,10 @@ func (r *EmbeddingRequest) SetModel(m string) {
 	r.Model = m
 }
 
+func (r *EmbeddingRequest) GetUser() string {
+	return r.User
+}
+
 // EmbeddingResponse is the response from a Create embeddings request.
 type EmbeddingResponse struct {
 	Object string          `json:"object"`
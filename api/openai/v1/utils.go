@@ -7,6 +7,18 @@ func firstNChars(s string, n int) string {
 	return string(runes[:min(n, len(runes))])
 }
 
+// splitNChars splits a string into chunks of at most n characters.
+func splitNChars(s string, n int) []string {
+	runes := []rune(s)
+	var chunks []string
+	for len(runes) > 0 {
+		i := min(n, len(runes))
+		chunks = append(chunks, string(runes[:i]))
+		runes = runes[i:]
+	}
+	return chunks
+}
+
 // Ptr is a helper function for creating an inline pointer to a constant.
 func Ptr[T any](v T) *T {
 	return &v
@@ -0,0 +1,249 @@
+package apikeys
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+)
+
+const (
+	// SecretLabel is the label that marks a Secret as containing an API key.
+	// Only Secrets with this label set to "true" are considered.
+	SecretLabel = "kubeai.org/api-key"
+
+	// SecretHashKey is the Secret data key that holds the hex-encoded
+	// SHA-256 hash of the API key. The key itself is never stored.
+	SecretHashKey = "sha256"
+
+	// SecretLabelSelectorKey is the Secret data key that holds the label
+	// selector restricting the Models that can be accessed with the API key.
+	// An empty or missing selector allows access to all Models.
+	SecretLabelSelectorKey = "labelSelector"
+
+	// SecretPriorityKey is the Secret data key that holds the priority
+	// (an integer, higher is more important) of requests sent with the API key.
+	// Defaults to 0.
+	SecretPriorityKey = "priority"
+)
+
+var (
+	ErrMissingKey = errors.New("missing API key")
+	ErrInvalidKey = errors.New("invalid API key")
+)
+
+// Key is an API key that was presented by a client.
+type Key struct {
+	// Name is the name of the Secret that the key is stored in.
+	// It is used to identify the caller.
+	Name string
+
+	// Selector restricts the Models that can be accessed with the key.
+	Selector string
+
+	// Priority of requests sent with the key.
+	Priority int32
+}
+
+// Hash returns the hex-encoded SHA-256 hash of an API key as it should
+// be stored in a Secret.
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Authenticator looks up the API keys presented by clients. The keys are
+// indexed by their hash in memory and the index is kept up to date by
+// reconciling the API key Secrets (see SetupWithManager).
+type Authenticator struct {
+	client    client.Reader
+	namespace string
+
+	mtx sync.RWMutex
+	// synced is set once the index was built from all Secrets.
+	synced bool
+	// keys indexes the API keys by their hash.
+	keys map[string]indexedKey
+	// hashes are the hashes of the API keys by the name of their Secret.
+	hashes map[string]string
+}
+
+type indexedKey struct {
+	key Key
+	// err is set if the Secret of the key is invalid.
+	err error
+}
+
+func NewAuthenticator(client client.Reader, namespace string) *Authenticator {
+	return &Authenticator{
+		client:    client,
+		namespace: namespace,
+		keys:      map[string]indexedKey{},
+		hashes:    map[string]string{},
+	}
+}
+
+func (a *Authenticator) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("apikeys").
+		WithOptions(controller.Options{NeedLeaderElection: ptr.To(false)}).
+		For(&corev1.Secret{}).
+		Complete(a)
+}
+
+// Reconcile updates the index entry of the API key in a Secret.
+func (a *Authenticator) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	if req.Namespace != a.namespace {
+		return ctrl.Result{}, nil
+	}
+	var secret corev1.Secret
+	if err := a.client.Get(ctx, req.NamespacedName, &secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			a.mtx.Lock()
+			a.unindex(req.Name)
+			a.mtx.Unlock()
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	a.index(&secret)
+	return ctrl.Result{}, nil
+}
+
+// Authenticate returns the Key that matches the bearer token in the
+// Authorization header of the request.
+func (a *Authenticator) Authenticate(ctx context.Context, r *http.Request) (*Key, error) {
+	token, ok := bearerToken(r.Header.Get("Authorization"))
+	if !ok {
+		return nil, ErrMissingKey
+	}
+	if err := a.sync(ctx); err != nil {
+		return nil, err
+	}
+
+	a.mtx.RLock()
+	entry, ok := a.keys[Hash(token)]
+	a.mtx.RUnlock()
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+	if entry.err != nil {
+		return nil, entry.err
+	}
+	key := entry.key
+	return &key, nil
+}
+
+// sync builds the index from all API key Secrets (once). Afterwards the
+// index is updated by Reconcile.
+func (a *Authenticator) sync(ctx context.Context) error {
+	a.mtx.RLock()
+	synced := a.synced
+	a.mtx.RUnlock()
+	if synced {
+		return nil
+	}
+
+	var secrets corev1.SecretList
+	if err := a.client.List(ctx, &secrets,
+		client.InNamespace(a.namespace),
+		client.MatchingLabels{SecretLabel: "true"},
+	); err != nil {
+		return fmt.Errorf("listing API key secrets: %w", err)
+	}
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	if a.synced {
+		return nil
+	}
+	a.keys = map[string]indexedKey{}
+	a.hashes = map[string]string{}
+	for i := range secrets.Items {
+		a.index(&secrets.Items[i])
+	}
+	a.synced = true
+	return nil
+}
+
+// index adds the API key of the Secret to the index (replacing its previous
+// key). The caller should hold mtx.
+func (a *Authenticator) index(s *corev1.Secret) {
+	a.unindex(s.Name)
+	if s.Labels[SecretLabel] != "true" {
+		return
+	}
+	hash := strings.TrimSpace(string(s.Data[SecretHashKey]))
+	if hash == "" {
+		return
+	}
+	a.keys[hash] = parseKey(s)
+	a.hashes[s.Name] = hash
+}
+
+// unindex removes the API key of the named Secret from the index.
+// The caller should hold mtx.
+func (a *Authenticator) unindex(name string) {
+	hash, ok := a.hashes[name]
+	if !ok {
+		return
+	}
+	delete(a.hashes, name)
+	if a.keys[hash].key.Name == name {
+		delete(a.keys, hash)
+	}
+}
+
+func parseKey(s *corev1.Secret) indexedKey {
+	selector := strings.TrimSpace(string(s.Data[SecretLabelSelectorKey]))
+	if _, err := labels.Parse(selector); err != nil {
+		return indexedKey{key: Key{Name: s.Name}, err: fmt.Errorf("parsing label selector for API key %q: %w", s.Name, err)}
+	}
+	var priority int64
+	if p := strings.TrimSpace(string(s.Data[SecretPriorityKey])); p != "" {
+		var err error
+		priority, err = strconv.ParseInt(p, 10, 32)
+		if err != nil {
+			return indexedKey{key: Key{Name: s.Name}, err: fmt.Errorf("parsing priority for API key %q: %w", s.Name, err)}
+		}
+	}
+	return indexedKey{key: Key{Name: s.Name, Selector: selector, Priority: int32(priority)}}
+}
+
+func bearerToken(header string) (string, bool) {
+	const prefix = "Bearer "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+	token := strings.TrimSpace(header[len(prefix):])
+	return token, token != ""
+}
+
+type contextKey struct{}
+
+// NewContext returns a new Context that carries the authenticated Key.
+func NewContext(ctx context.Context, key *Key) context.Context {
+	return context.WithValue(ctx, contextKey{}, key)
+}
+
+// FromContext returns the authenticated Key stored in ctx, if any.
+func FromContext(ctx context.Context) (*Key, bool) {
+	key, ok := ctx.Value(contextKey{}).(*Key)
+	return key, ok
+}
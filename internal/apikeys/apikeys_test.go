@@ -0,0 +1,167 @@
+package apikeys
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestAuthenticate(t *testing.T) {
+	const ns = "default"
+	secret := func(name string, labeled bool, key, selector string) *corev1.Secret {
+		s := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns, Labels: map[string]string{}},
+			Data: map[string][]byte{
+				SecretHashKey:          []byte(Hash(key)),
+				SecretLabelSelectorKey: []byte(selector),
+			},
+		}
+		if labeled {
+			s.Labels[SecretLabel] = "true"
+		}
+		return s
+	}
+	prioritized := secret("batch", true, "key-batch", "")
+	prioritized.Data[SecretPriorityKey] = []byte("-10")
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+		secret("team-a", true, "key-a", "tenancy=team-a"),
+		prioritized,
+		secret("admin", true, "key-admin", ""),
+		secret("unlabeled", false, "key-unlabeled", ""),
+		secret("bad-selector", true, "key-bad-selector", "!!!"),
+	).Build()
+	a := NewAuthenticator(c, ns)
+
+	cases := []struct {
+		name   string
+		header string
+		expKey *Key
+		expErr error
+	}{
+		{
+			name:   "no header",
+			expErr: ErrMissingKey,
+		},
+		{
+			name:   "not a bearer token",
+			header: "Basic abc",
+			expErr: ErrMissingKey,
+		},
+		{
+			name:   "empty bearer token",
+			header: "Bearer ",
+			expErr: ErrMissingKey,
+		},
+		{
+			name:   "unknown key",
+			header: "Bearer does-not-exist",
+			expErr: ErrInvalidKey,
+		},
+		{
+			name:   "key in unlabeled secret",
+			header: "Bearer key-unlabeled",
+			expErr: ErrInvalidKey,
+		},
+		{
+			name:   "key with selector",
+			header: "Bearer key-a",
+			expKey: &Key{Name: "team-a", Selector: "tenancy=team-a"},
+		},
+		{
+			name:   "key without selector",
+			header: "bearer key-admin",
+			expKey: &Key{Name: "admin"},
+		},
+		{
+			name:   "key with priority",
+			header: "Bearer key-batch",
+			expKey: &Key{Name: "batch", Priority: -10},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r, err := http.NewRequest(http.MethodGet, "/", nil)
+			require.NoError(t, err)
+			if c.header != "" {
+				r.Header.Set("Authorization", c.header)
+			}
+			key, err := a.Authenticate(context.Background(), r)
+			if c.expErr != nil {
+				require.ErrorIs(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expKey, key)
+		})
+	}
+
+	t.Run("invalid selector", func(t *testing.T) {
+		r, err := http.NewRequest(http.MethodGet, "/", nil)
+		require.NoError(t, err)
+		r.Header.Set("Authorization", "Bearer key-bad-selector")
+		_, err = a.Authenticate(context.Background(), r)
+		require.Error(t, err)
+		require.NotErrorIs(t, err, ErrInvalidKey)
+	})
+}
+
+func TestAuthenticatorReconcile(t *testing.T) {
+	const ns = "default"
+	ctx := context.Background()
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "team-a", Namespace: ns, Labels: map[string]string{SecretLabel: "true"}},
+		Data:       map[string][]byte{SecretHashKey: []byte(Hash("key-a"))},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(secret).Build()
+	a := NewAuthenticator(c, ns)
+
+	authenticate := func(token string) (*Key, error) {
+		r, err := http.NewRequest(http.MethodGet, "/", nil)
+		require.NoError(t, err)
+		r.Header.Set("Authorization", "Bearer "+token)
+		return a.Authenticate(ctx, r)
+	}
+	reconcile := func() {
+		_, err := a.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Namespace: ns, Name: secret.Name}})
+		require.NoError(t, err)
+	}
+
+	key, err := authenticate("key-a")
+	require.NoError(t, err)
+	require.Equal(t, &Key{Name: "team-a"}, key)
+
+	// Rotate the key.
+	secret.Data[SecretHashKey] = []byte(Hash("key-a2"))
+	require.NoError(t, c.Update(ctx, secret))
+	reconcile()
+	_, err = authenticate("key-a")
+	require.ErrorIs(t, err, ErrInvalidKey, "the old key should be revoked")
+	_, err = authenticate("key-a2")
+	require.NoError(t, err)
+
+	// Remove the label.
+	secret.Labels = nil
+	require.NoError(t, c.Update(ctx, secret))
+	reconcile()
+	_, err = authenticate("key-a2")
+	require.ErrorIs(t, err, ErrInvalidKey)
+
+	// Add the label again and delete the Secret.
+	secret.Labels = map[string]string{SecretLabel: "true"}
+	require.NoError(t, c.Update(ctx, secret))
+	reconcile()
+	_, err = authenticate("key-a2")
+	require.NoError(t, err)
+	require.NoError(t, c.Delete(ctx, secret))
+	reconcile()
+	_, err = authenticate("key-a2")
+	require.ErrorIs(t, err, ErrInvalidKey)
+}
@@ -0,0 +1,92 @@
+package apiutils
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"net/http"
+
+	k8sv1 "github.com/kubeai-project/kubeai/api/k8s/v1"
+	"github.com/kubeai-project/kubeai/internal/apikeys"
+)
+
+// userRequest should be implemented by requests that identify an end-user
+// so that the user can be consistently routed to the same backing Model of
+// a ModelAlias.
+type userRequest interface {
+	GetUser() string
+}
+
+// resolveModelAlias returns the name of the Model (possibly merged with an
+// adapter) that serves a request for the given model name. If the name does
+// not refer to a ModelAlias, it is returned as is.
+func (r *Request) resolveModelAlias(ctx context.Context, client ModelClient, requested, user string, headers http.Header) (string, error) {
+	alias, err := client.LookupModelAlias(ctx, requested, r.Selectors)
+	if err != nil {
+		return "", fmt.Errorf("lookup model alias: %w", err)
+	}
+	if alias == nil {
+		return requested, nil
+	}
+
+	if user == "" {
+		if key, ok := apikeys.FromContext(ctx); ok {
+			user = key.Name
+		}
+	}
+	backend, ok := pickModelAliasBackend(alias, headers, user)
+	if !ok {
+		return "", fmt.Errorf("%w: no route of model alias %q matches the request", ErrModelNotFound, requested)
+	}
+	r.Alias = alias.Name
+	return backend, nil
+}
+
+// pickModelAliasBackend selects a backend from the first Route of the alias
+// that matches the request headers (skipping Routes whose backends all have
+// a weight of 0). Backends are picked at random according
+// to their weights, unless a user is given, in which case the same user is
+// always assigned the same backend (as long as the weights do not change).
+func pickModelAliasBackend(alias *k8sv1.ModelAlias, headers http.Header, user string) (string, bool) {
+	for _, route := range alias.Spec.Routes {
+		if !routeMatches(route, headers) {
+			continue
+		}
+
+		var total uint64
+		for _, b := range route.Backends {
+			total += uint64(max(0, b.Weight))
+		}
+		if total == 0 {
+			// All backends of the Route are disabled.
+			continue
+		}
+
+		var n uint64
+		if user != "" {
+			h := fnv.New64a()
+			h.Write([]byte(alias.Name + "/" + user))
+			n = h.Sum64() % total
+		} else {
+			n = rand.Uint64N(total)
+		}
+		for _, b := range route.Backends {
+			w := uint64(max(0, b.Weight))
+			if n < w {
+				return b.Model, true
+			}
+			n -= w
+		}
+	}
+	return "", false
+}
+
+func routeMatches(route k8sv1.ModelAliasRoute, headers http.Header) bool {
+	for k, v := range route.Headers {
+		if headers.Get(k) != v {
+			return false
+		}
+	}
+	return true
+}
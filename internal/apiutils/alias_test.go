@@ -0,0 +1,96 @@
+package apiutils
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	k8sv1 "github.com/kubeai-project/kubeai/api/k8s/v1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPickModelAliasBackend(t *testing.T) {
+	alias := &k8sv1.ModelAlias{
+		ObjectMeta: metav1.ObjectMeta{Name: "llama"},
+		Spec: k8sv1.ModelAliasSpec{
+			Routes: []k8sv1.ModelAliasRoute{
+				{
+					Headers: map[string]string{"X-Tier": "beta"},
+					Backends: []k8sv1.ModelAliasBackend{
+						{Model: "llama-3.3", Weight: 1},
+					},
+				},
+				{
+					Backends: []k8sv1.ModelAliasBackend{
+						{Model: "llama-3.1", Weight: 3},
+						{Model: "llama-3.3", Weight: 1},
+						{Model: "llama-3.4", Weight: 0},
+					},
+				},
+			},
+		},
+	}
+
+	t.Run("header match", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			backend, ok := pickModelAliasBackend(alias, http.Header{"X-Tier": []string{"beta"}}, "")
+			require.True(t, ok)
+			require.Equal(t, "llama-3.3", backend)
+		}
+	})
+
+	t.Run("weighted", func(t *testing.T) {
+		const n = 10_000
+		counts := map[string]int{}
+		for i := 0; i < n; i++ {
+			backend, ok := pickModelAliasBackend(alias, http.Header{}, "")
+			require.True(t, ok)
+			counts[backend]++
+		}
+		require.InDelta(t, 0.75*n, counts["llama-3.1"], 0.05*n)
+		require.InDelta(t, 0.25*n, counts["llama-3.3"], 0.05*n)
+		require.Zero(t, counts["llama-3.4"])
+	})
+
+	t.Run("sticky per user", func(t *testing.T) {
+		counts := map[string]int{}
+		for u := 0; u < 1000; u++ {
+			user := fmt.Sprintf("user-%d", u)
+			first, ok := pickModelAliasBackend(alias, http.Header{}, user)
+			require.True(t, ok)
+			for i := 0; i < 10; i++ {
+				backend, _ := pickModelAliasBackend(alias, http.Header{}, user)
+				require.Equal(t, first, backend, "user should always be routed to the same backend")
+			}
+			counts[first]++
+		}
+		require.InDelta(t, 750, counts["llama-3.1"], 75)
+		require.InDelta(t, 250, counts["llama-3.3"], 75)
+	})
+
+	t.Run("routes without weights are skipped", func(t *testing.T) {
+		backend, ok := pickModelAliasBackend(&k8sv1.ModelAlias{
+			Spec: k8sv1.ModelAliasSpec{
+				Routes: []k8sv1.ModelAliasRoute{
+					{Backends: []k8sv1.ModelAliasBackend{{Model: "llama-3.4", Weight: 0}}},
+					{Backends: []k8sv1.ModelAliasBackend{{Model: "llama-3.3", Weight: 1}}},
+				},
+			},
+		}, http.Header{}, "")
+		require.True(t, ok)
+		require.Equal(t, "llama-3.3", backend)
+	})
+
+	t.Run("no matching route", func(t *testing.T) {
+		_, ok := pickModelAliasBackend(&k8sv1.ModelAlias{
+			Spec: k8sv1.ModelAliasSpec{
+				Routes: []k8sv1.ModelAliasRoute{{
+					Headers:  map[string]string{"X-Tier": "beta"},
+					Backends: []k8sv1.ModelAliasBackend{{Model: "llama-3.3", Weight: 1}},
+				}},
+			},
+		}, http.Header{}, "")
+		require.False(t, ok)
+	})
+}
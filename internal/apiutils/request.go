@@ -8,14 +8,17 @@ import (
 	"mime"
 	"mime/multipart"
 	"net/http"
+	"strconv"
 
 	"github.com/go-json-experiment/json"
 
 	"context"
 
+	"github.com/cespare/xxhash"
 	"github.com/google/uuid"
 	k8sv1 "github.com/kubeai-project/kubeai/api/k8s/v1"
 	openaiv1 "github.com/kubeai-project/kubeai/api/openai/v1"
+	"github.com/kubeai-project/kubeai/internal/apikeys"
 )
 
 var (
@@ -35,6 +38,22 @@ type inferenceRequest interface {
 	Prefix(int) string
 }
 
+// prefixBlocksRequest should be implemented by inference requests that can be
+// split into blocks for the Blocks prefix hashing mode.
+type prefixBlocksRequest interface {
+	PrefixBlocks(int) []string
+}
+
+// maxPrefixBlocks limits the number of blocks of a request that are hashed.
+const maxPrefixBlocks = 256
+
+// streamingRequest should be implemented by requests that support streamed
+// responses so that usage statistics can be requested from the backend.
+type streamingRequest interface {
+	IsStream() bool
+	IncludeStreamUsage() bool
+}
+
 type Request struct {
 	Body         []byte
 	modelRequest modelRequest
@@ -45,28 +64,83 @@ type Request struct {
 
 	// RequestedModel is the model name requested by the client.
 	// This might contain the adapter name as well.
+	// If the client requested a ModelAlias, this is the name
+	// that the alias was resolved to.
 	RequestedModel string
 
+	// Alias is the name of the ModelAlias that the client requested (if any).
+	Alias string
+
 	Model   string
 	Adapter string
 
 	LoadBalancing k8sv1.LoadBalancing
 
+	// AdapterOnDemand is true if the requested adapter is loaded into
+	// the Pods of the Model on demand (and might be unloaded again).
+	AdapterOnDemand bool
+
+	// Fallback Models of the requested Model (if any).
+	Fallback []string
+
 	Prefix string
+	// PrefixBlocks are the cumulative hashes of the blocks of the request
+	// (in the Blocks prefix hashing mode): PrefixBlocks[i] identifies the
+	// first i+1 blocks.
+	PrefixBlocks []uint64
+
+	// SessionID identifies the session that the request belongs to if
+	// session affinity is configured for the Model.
+	SessionID string
+
+	// Priority orders requests that are waiting for an endpoint.
+	// Higher values are served (and kept when shedding load) first.
+	Priority int32
+
+	// Stream is true if the client requested a streamed response.
+	Stream bool
+	// StreamUsageInjected is true if usage statistics were requested from
+	// the backend on behalf of the client (in order to account for token usage).
+	// The usage chunk should not be forwarded to the client in this case.
+	StreamUsageInjected bool
 
 	ContentLength int64
+
+	// headers and user are used to identify the session of the request.
+	headers http.Header
+	user    string
 }
 
 type ModelClient interface {
 	LookupModel(ctx context.Context, model, adapter string, selectors []string) (*k8sv1.Model, error)
+	LookupModelAlias(ctx context.Context, name string, selectors []string) (*k8sv1.ModelAlias, error)
 }
 
 func ParseRequest(ctx context.Context, client ModelClient, body io.Reader, path string, headers http.Header) (*Request, error) {
 	r := &Request{
-		ID: uuid.New().String(),
+		ID:      uuid.New().String(),
+		headers: headers,
 	}
 
 	r.Selectors = headers.Values("X-Label-Selector")
+	// Selectors are ANDed together, so the selector that is tied to an API key
+	// can not be circumvented by selectors that a client sends.
+	key, authenticated := apikeys.FromContext(ctx)
+	if authenticated && key.Selector != "" {
+		r.Selectors = append(r.Selectors, key.Selector)
+	}
+
+	// The priority that is tied to an API key takes precedence
+	// over the priority that a client requests.
+	if authenticated {
+		r.Priority = key.Priority
+	} else if p := headers.Get("X-Priority"); p != "" {
+		priority, err := strconv.ParseInt(p, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%w: parsing X-Priority header: %w", ErrBadRequest, err)
+		}
+		r.Priority = int32(priority)
+	}
 
 	// Parse media type (with params - which are used for multipart form data)
 	var (
@@ -85,17 +159,21 @@ func ParseRequest(ctx context.Context, client ModelClient, body io.Reader, path
 		}
 	}
 
+	resolve := func(requested, user string) (string, error) {
+		return r.resolveModelAlias(ctx, client, requested, user, headers)
+	}
+
 	switch mediaType {
 	// Multipart form data is used for endpoints that accept file uploads:
 	case "multipart/form-data":
-		if err := r.readyMultiPartBody(body, mediaParams); err != nil {
-			return nil, fmt.Errorf("%w: reading multipart form data: %w", ErrBadRequest, err)
+		if err := r.readyMultiPartBody(body, mediaParams, resolve); err != nil {
+			return nil, wrapBodyError("reading multipart form data", err)
 		}
 
 	// Assume "application/json":
 	default:
-		if err := r.readJSONBody(body, path); err != nil {
-			return nil, fmt.Errorf("%w: reading model from body: %w", ErrBadRequest, err)
+		if err := r.readJSONBody(body, path, resolve); err != nil {
+			return nil, wrapBodyError("reading model from body", err)
 		}
 	}
 
@@ -106,7 +184,29 @@ func ParseRequest(ctx context.Context, client ModelClient, body io.Reader, path
 	return r, nil
 }
 
-func (r *Request) readyMultiPartBody(body io.Reader, mediaParams map[string]string) error {
+// wrapBodyError treats errors from reading the body as bad requests,
+// unless they originate from resolving a ModelAlias.
+func wrapBodyError(msg string, err error) error {
+	var resolveErr *resolveError
+	if errors.As(err, &resolveErr) {
+		return resolveErr.err
+	}
+	return fmt.Errorf("%w: %s: %w", ErrBadRequest, msg, err)
+}
+
+// resolveError wraps errors that occur while resolving the requested model.
+type resolveError struct {
+	err error
+}
+
+func (e *resolveError) Error() string { return e.err.Error() }
+func (e *resolveError) Unwrap() error { return e.err }
+
+// resolveFunc resolves the requested model name (that might refer to a ModelAlias)
+// into the name of a Model.
+type resolveFunc func(requested, user string) (string, error)
+
+func (r *Request) readyMultiPartBody(body io.Reader, mediaParams map[string]string, resolve resolveFunc) error {
 	boundary := mediaParams["boundary"]
 	if boundary == "" {
 		return fmt.Errorf("no boundary specified in multipart form data")
@@ -135,8 +235,12 @@ func (r *Request) readyMultiPartBody(body io.Reader, mediaParams map[string]stri
 			if err != nil {
 				return fmt.Errorf("reading multipart form value: %w", err)
 			}
-			r.Model, r.Adapter = SplitModelAdapter(string(value))
-			r.RequestedModel = string(value)
+			resolved, err := resolve(string(value), "")
+			if err != nil {
+				return &resolveError{err: err}
+			}
+			r.Model, r.Adapter = SplitModelAdapter(resolved)
+			r.RequestedModel = resolved
 			// WORKAROUND ALERT:
 			// Omit the "model" field from the proxy request to avoid FasterWhisper validation issues:
 			// See https://github.com/fedirz/faster-whisper-server/issues/71
@@ -164,7 +268,7 @@ func (r *Request) readyMultiPartBody(body io.Reader, mediaParams map[string]stri
 	return nil
 }
 
-func (r *Request) readJSONBody(body io.Reader, path string) error {
+func (r *Request) readJSONBody(body io.Reader, path string, resolve resolveFunc) error {
 	switch path {
 	case "/v1/completions":
 		r.modelRequest = &openaiv1.CompletionRequest{}
@@ -186,7 +290,20 @@ func (r *Request) readJSONBody(body io.Reader, path string) error {
 		return errors.New("missing 'model' field")
 	}
 
-	r.RequestedModel = r.modelRequest.GetModel()
+	var user string
+	if uReq, ok := r.modelRequest.(userRequest); ok {
+		user = uReq.GetUser()
+	}
+	r.user = user
+	resolved, err := resolve(r.modelRequest.GetModel(), user)
+	if err != nil {
+		return &resolveError{err: err}
+	}
+	if resolved != r.modelRequest.GetModel() {
+		r.modelRequest.SetModel(resolved)
+	}
+
+	r.RequestedModel = resolved
 	r.Model, r.Adapter = SplitModelAdapter(r.RequestedModel)
 
 	if r.Adapter != "" {
@@ -194,6 +311,12 @@ func (r *Request) readJSONBody(body io.Reader, path string) error {
 		r.modelRequest.SetModel(r.Adapter)
 	}
 
+	if strReq, ok := r.modelRequest.(streamingRequest); ok && strReq.IsStream() {
+		r.Stream = true
+		// Make sure the backend reports token usage at the end of the stream.
+		r.StreamUsageInjected = strReq.IncludeStreamUsage()
+	}
+
 	rewritten, err := json.Marshal(r.modelRequest)
 	if err != nil {
 		return fmt.Errorf("remarshalling: %w", err)
@@ -213,17 +336,96 @@ func (r *Request) lookupModel(ctx context.Context, client ModelClient, path stri
 		return fmt.Errorf("%w: %q", ErrModelNotFound, r.RequestedModel)
 	}
 
-	r.LoadBalancing = model.Spec.LoadBalancing
+	r.Fallback = model.Spec.Fallback
+	r.setLoadBalancing(model)
 
-	if infReq, ok := r.modelRequest.(inferenceRequest); ok {
-		if r.LoadBalancing.Strategy == k8sv1.PrefixHashStrategy && r.modelRequest != nil {
-			r.Prefix = infReq.Prefix(r.LoadBalancing.PrefixHash.PrefixCharLength)
-		}
+	return nil
+}
+
+// SwitchModel changes the Model (and adapter) that serves the request,
+// i.e. to fall back to another Model. The Model is subject to the same
+// label selectors as the requested Model. The request body is rewritten
+// to reference the new Model.
+func (r *Request) SwitchModel(ctx context.Context, client ModelClient, name string) error {
+	modelName, adapter := SplitModelAdapter(name)
+	model, err := client.LookupModel(ctx, modelName, adapter, r.Selectors)
+	if err != nil {
+		return fmt.Errorf("lookup model: %w", err)
+	}
+	if model == nil {
+		return fmt.Errorf("%w: %q", ErrModelNotFound, name)
+	}
+
+	r.Model, r.Adapter = modelName, adapter
+	r.Prefix = ""
+	r.setLoadBalancing(model)
+
+	// Multipart requests do not contain the model in the body.
+	if r.modelRequest == nil {
+		return nil
+	}
+	if r.Adapter != "" {
+		// vLLM expects the adapter to be in the model field.
+		r.modelRequest.SetModel(r.Adapter)
+	} else {
+		r.modelRequest.SetModel(r.Model)
+	}
+	rewritten, err := json.Marshal(r.modelRequest)
+	if err != nil {
+		return fmt.Errorf("remarshalling: %w", err)
 	}
+	r.Body = rewritten
+	r.ContentLength = int64(len(r.Body))
 
 	return nil
 }
 
+func (r *Request) setLoadBalancing(model *k8sv1.Model) {
+	r.LoadBalancing = model.Spec.LoadBalancing
+	r.AdapterOnDemand = r.Adapter != "" && model.Spec.AdapterLoading.Mode == k8sv1.OnDemandAdapterLoadingMode
+
+	r.PrefixBlocks = nil
+	if r.LoadBalancing.Strategy == k8sv1.PrefixHashStrategy && r.modelRequest != nil {
+		switch r.LoadBalancing.PrefixHash.Mode {
+		case k8sv1.BlocksPrefixHashMode:
+			if bReq, ok := r.modelRequest.(prefixBlocksRequest); ok {
+				r.PrefixBlocks = hashPrefixBlocks(r.Adapter, bReq.PrefixBlocks(r.LoadBalancing.PrefixHash.PrefixCharLength))
+			}
+		default:
+			if infReq, ok := r.modelRequest.(inferenceRequest); ok {
+				r.Prefix = infReq.Prefix(r.LoadBalancing.PrefixHash.PrefixCharLength)
+			}
+		}
+	}
+
+	r.SessionID = ""
+	if h := r.LoadBalancing.SessionAffinity.Header; h != "" {
+		r.SessionID = r.headers.Get(h)
+	}
+	if r.SessionID == "" && r.LoadBalancing.SessionAffinity.User {
+		r.SessionID = r.user
+	}
+}
+
+// hashPrefixBlocks returns the cumulative hashes of the given blocks.
+// The hashes of different adapters never match.
+func hashPrefixBlocks(adapter string, blocks []string) []uint64 {
+	if len(blocks) == 0 {
+		return nil
+	}
+	blocks = blocks[:min(len(blocks), maxPrefixBlocks)]
+	hashes := make([]uint64, len(blocks))
+	h := xxhash.New()
+	h.Write([]byte(adapter))
+	for i, b := range blocks {
+		// The separator avoids collisions between different splits of the same text.
+		h.Write([]byte{0})
+		h.Write([]byte(b))
+		hashes[i] = h.Sum64()
+	}
+	return hashes
+}
+
 // firstNChars returns the first n characters of a string.
 // This function is needed because Go's string indexing is based on bytes, not runes.
 func firstNChars(s string, n int) string {
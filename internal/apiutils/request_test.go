@@ -7,7 +7,9 @@ import (
 	"testing"
 
 	v1 "github.com/kubeai-project/kubeai/api/k8s/v1"
+	"github.com/kubeai-project/kubeai/internal/apikeys"
 	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestParseRequest(t *testing.T) {
@@ -16,9 +18,19 @@ func TestParseRequest(t *testing.T) {
 		body       string
 		path       string
 		headers    http.Header
+		apiKey     *apikeys.Key
 		expModel   string
 		expAdapter string
+		expAlias   string
 		expPrefix  string
+		expSession string
+		// expSelectors are the selectors that are passed to LookupModel.
+		expSelectors []string
+		expPriority  int32
+		expStream    bool
+		// expStreamUsageInjected implies that the body was rewritten.
+		expStreamUsageInjected bool
+		expBody                string
 	}{
 		{
 			name:     "model only",
@@ -59,6 +71,100 @@ func TestParseRequest(t *testing.T) {
 			expModel:  "test-model",
 			expPrefix: "test-prefi", // "test-prefix" (max 10) --> "test-prefi"
 		},
+		{
+			name:                   "streamed chat completion without usage",
+			body:                   `{"model": "test-model", "messages": [], "stream": true}`,
+			path:                   "/v1/chat/completions",
+			expModel:               "test-model",
+			expStream:              true,
+			expStreamUsageInjected: true,
+			expBody:                `{"model":"test-model","messages":[],"stream":true,"stream_options":{"include_usage":true}}`,
+		},
+		{
+			name:      "streamed chat completion with usage",
+			body:      `{"model": "test-model", "stream": true, "stream_options": {"include_usage": true}}`,
+			path:      "/v1/chat/completions",
+			expModel:  "test-model",
+			expStream: true,
+		},
+		{
+			name:                   "streamed legacy completion without usage",
+			body:                   `{"model": "test-model", "prompt": "test-prefix", "stream": true}`,
+			path:                   "/v1/completions",
+			expModel:               "test-model",
+			expPrefix:              "test-prefi",
+			expStream:              true,
+			expStreamUsageInjected: true,
+			expBody:                `{"model":"test-model","prompt":"test-prefix","stream":true,"stream_options":{"include_usage":true}}`,
+		},
+		{
+			name:         "selectors from header",
+			body:         `{"model": "test-model"}`,
+			path:         "/v1/chat/completions",
+			headers:      http.Header{"X-Label-Selector": []string{"a=b"}},
+			expModel:     "test-model",
+			expSelectors: []string{"a=b"},
+		},
+		{
+			name:         "selectors from header and api key",
+			body:         `{"model": "test-model"}`,
+			path:         "/v1/chat/completions",
+			headers:      http.Header{"X-Label-Selector": []string{"a=b"}},
+			apiKey:       &apikeys.Key{Name: "team-a", Selector: "tenancy=team-a"},
+			expModel:     "test-model",
+			expSelectors: []string{"a=b", "tenancy=team-a"},
+		},
+		{
+			name:        "priority from header",
+			body:        `{"model": "test-model"}`,
+			path:        "/v1/chat/completions",
+			headers:     http.Header{"X-Priority": []string{"10"}},
+			expModel:    "test-model",
+			expPriority: 10,
+		},
+		{
+			name:        "priority from api key takes precedence over header",
+			body:        `{"model": "test-model"}`,
+			path:        "/v1/chat/completions",
+			headers:     http.Header{"X-Priority": []string{"10"}},
+			apiKey:      &apikeys.Key{Name: "batch", Priority: -1},
+			expModel:    "test-model",
+			expPriority: -1,
+		},
+		{
+			name:     "model alias",
+			body:     `{"model": "test-alias", "messages": []}`,
+			path:     "/v1/chat/completions",
+			expModel: "test-model",
+			expAlias: "test-alias",
+			expBody:  `{"model":"test-model","messages":[]}`,
+		},
+		{
+			name:       "model alias with header route to adapter",
+			body:       `{"model": "test-alias", "messages": []}`,
+			path:       "/v1/chat/completions",
+			headers:    http.Header{"X-Canary": []string{"true"}},
+			expModel:   "test-model",
+			expAdapter: "test-adapter",
+			expAlias:   "test-alias",
+			expBody:    `{"model":"test-adapter","messages":[]}`,
+		},
+		{
+			name:       "session from header",
+			body:       `{"model": "test-model", "messages": [], "user": "user-a"}`,
+			path:       "/v1/chat/completions",
+			headers:    http.Header{"X-Session-Id": []string{"session-a"}},
+			expModel:   "test-model",
+			expSession: "session-a",
+		},
+		{
+			name:       "session from user",
+			body:       `{"model": "test-model", "prompt": "test-prefix", "user": "user-a"}`,
+			path:       "/v1/completions",
+			expModel:   "test-model",
+			expPrefix:  "test-prefi",
+			expSession: "user-a",
+		},
 		{
 			name:     "rerank request",
 			body:     `{"model": "test-model", "query": "q", "documents": ["d1", "d2"]}`,
@@ -69,6 +175,9 @@ func TestParseRequest(t *testing.T) {
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
 			ctx := context.Background()
+			if c.apiKey != nil {
+				ctx = apikeys.NewContext(ctx, c.apiKey)
+			}
 
 			mockClient := &mockModelClient{prefixCharLen: 10}
 
@@ -77,17 +186,72 @@ func TestParseRequest(t *testing.T) {
 
 			require.Equal(t, c.expModel, req.Model, "model")
 			require.Equal(t, c.expAdapter, req.Adapter, "adapter")
+			require.Equal(t, c.expAlias, req.Alias, "alias")
 			require.Equal(t, c.expPrefix, req.Prefix, "prefix")
+			require.Equal(t, c.expSession, req.SessionID, "session")
+			require.Equal(t, c.expSelectors, mockClient.selectors, "selectors")
+			require.Equal(t, c.expPriority, req.Priority, "priority")
+			require.Equal(t, c.expStream, req.Stream, "stream")
+			require.Equal(t, c.expStreamUsageInjected, req.StreamUsageInjected, "stream usage injected")
+			if c.expBody != "" {
+				require.JSONEq(t, c.expBody, string(req.Body), "body")
+			}
 		})
 	}
 
 }
 
+func TestParseRequestPrefixBlocks(t *testing.T) {
+	mockClient := &mockModelClient{prefixCharLen: 10, prefixHashMode: v1.BlocksPrefixHashMode}
+	parse := func(body string) *Request {
+		t.Helper()
+		req, err := ParseRequest(context.Background(), mockClient, bytes.NewReader([]byte(body)), "/v1/chat/completions", nil)
+		require.NoError(t, err)
+		require.Empty(t, req.Prefix)
+		return req
+	}
+
+	turn1 := parse(`{"model": "test-model", "messages": [{"role": "system", "content": "long system prompt"}, {"role": "user", "content": "hi"}]}`)
+	require.Len(t, turn1.PrefixBlocks, 4, "the system prompt should be split into 3 blocks")
+	turn2 := parse(`{"model": "test-model", "messages": [{"role": "system", "content": "long system prompt"}, {"role": "user", "content": "hi"}, {"role": "assistant", "content": "hello"}, {"role": "user", "content": "bye"}]}`)
+	require.Equal(t, turn1.PrefixBlocks, turn2.PrefixBlocks[:4], "turns should extend the chain of blocks")
+
+	other := parse(`{"model": "test-model", "messages": [{"role": "system", "content": "long system prompt"}, {"role": "user", "content": "hey"}]}`)
+	require.Equal(t, turn1.PrefixBlocks[:3], other.PrefixBlocks[:3])
+	require.NotEqual(t, turn1.PrefixBlocks[3], other.PrefixBlocks[3])
+
+	adapter := parse(`{"model": "test-model_test-adapter", "messages": [{"role": "system", "content": "long system prompt"}, {"role": "user", "content": "hi"}]}`)
+	require.NotEqual(t, turn1.PrefixBlocks[0], adapter.PrefixBlocks[0], "adapters should not share blocks")
+}
+
 type mockModelClient struct {
-	prefixCharLen int
+	prefixCharLen  int
+	prefixHashMode v1.PrefixHashMode
+	selectors      []string
+}
+
+func (m *mockModelClient) LookupModelAlias(ctx context.Context, name string, selectors []string) (*v1.ModelAlias, error) {
+	if name != "test-alias" {
+		return nil, nil
+	}
+	return &v1.ModelAlias{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: v1.ModelAliasSpec{
+			Routes: []v1.ModelAliasRoute{
+				{
+					Headers:  map[string]string{"X-Canary": "true"},
+					Backends: []v1.ModelAliasBackend{{Model: "test-model_test-adapter", Weight: 1}},
+				},
+				{
+					Backends: []v1.ModelAliasBackend{{Model: "test-model", Weight: 1}},
+				},
+			},
+		},
+	}, nil
 }
 
 func (m *mockModelClient) LookupModel(ctx context.Context, model, adapter string, selectors []string) (*v1.Model, error) {
+	m.selectors = selectors
 	return &v1.Model{
 		Spec: v1.ModelSpec{
 			LoadBalancing: v1.LoadBalancing{
@@ -95,6 +259,11 @@ func (m *mockModelClient) LookupModel(ctx context.Context, model, adapter string
 				PrefixHash: v1.PrefixHash{
 					// "test-prefix" --> "test-prefi"
 					PrefixCharLength: m.prefixCharLen,
+					Mode:             m.prefixHashMode,
+				},
+				SessionAffinity: v1.SessionAffinity{
+					Header: "X-Session-ID",
+					User:   true,
 				},
 			},
 		},
@@ -0,0 +1,67 @@
+package apiutils
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+
+	openaiv1 "github.com/kubeai-project/kubeai/api/openai/v1"
+)
+
+// ReportsUsage returns true if responses for the given path include
+// token usage statistics.
+func ReportsUsage(path string) bool {
+	switch path {
+	case "/v1/completions", "/v1/chat/completions", "/v1/embeddings":
+		return true
+	}
+	return false
+}
+
+// ReadUsage reads the top-level "usage" field from a JSON response body
+// for the given path. Other fields are skipped without being buffered so that
+// large responses (i.e. embeddings) can be inspected cheaply.
+// A nil usage is returned if the response did not report usage.
+func ReadUsage(path string, body io.Reader) (*openaiv1.CompletionUsage, error) {
+	dec := jsontext.NewDecoder(body)
+	tok, err := dec.ReadToken()
+	if err != nil {
+		return nil, fmt.Errorf("reading start of object: %w", err)
+	}
+	if tok.Kind() != '{' {
+		return nil, nil
+	}
+	for dec.PeekKind() != '}' {
+		name, err := dec.ReadToken()
+		if err != nil {
+			return nil, fmt.Errorf("reading field name: %w", err)
+		}
+		if name.String() != "usage" {
+			if err := dec.SkipValue(); err != nil {
+				return nil, fmt.Errorf("skipping field %q: %w", name.String(), err)
+			}
+			continue
+		}
+		if dec.PeekKind() == 'n' {
+			return nil, nil
+		}
+		if path == "/v1/embeddings" {
+			var usage openaiv1.EmbeddingUsage
+			if err := json.UnmarshalDecode(dec, &usage); err != nil {
+				return nil, fmt.Errorf("decoding embedding usage: %w", err)
+			}
+			return &openaiv1.CompletionUsage{
+				PromptTokens: usage.PromptTokens,
+				TotalTokens:  usage.TotalTokens,
+			}, nil
+		}
+		var usage openaiv1.CompletionUsage
+		if err := json.UnmarshalDecode(dec, &usage); err != nil {
+			return nil, fmt.Errorf("decoding completion usage: %w", err)
+		}
+		return &usage, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,71 @@
+package apiutils
+
+import (
+	"strings"
+	"testing"
+
+	openaiv1 "github.com/kubeai-project/kubeai/api/openai/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadUsage(t *testing.T) {
+	cases := []struct {
+		name     string
+		path     string
+		body     string
+		expUsage *openaiv1.CompletionUsage
+		expErr   bool
+	}{
+		{
+			name: "chat completion",
+			path: "/v1/chat/completions",
+			body: `{"id":"x","choices":[{"index":0,"message":{"role":"assistant","content":"hi"}}],"usage":{"prompt_tokens":10,"completion_tokens":2,"total_tokens":12}}`,
+			expUsage: &openaiv1.CompletionUsage{
+				PromptTokens:     10,
+				CompletionTokens: 2,
+				TotalTokens:      12,
+			},
+		},
+		{
+			name: "embeddings",
+			path: "/v1/embeddings",
+			body: `{"object":"list","data":[{"object":"embedding","embedding":[0.1,0.2],"index":0}],"model":"m","usage":{"prompt_tokens":5,"total_tokens":5}}`,
+			expUsage: &openaiv1.CompletionUsage{
+				PromptTokens: 5,
+				TotalTokens:  5,
+			},
+		},
+		{
+			name: "no usage",
+			path: "/v1/completions",
+			body: `{"id":"x","choices":[]}`,
+		},
+		{
+			name: "null usage",
+			path: "/v1/completions",
+			body: `{"id":"x","usage":null}`,
+		},
+		{
+			name: "not an object",
+			path: "/v1/completions",
+			body: `["a"]`,
+		},
+		{
+			name:   "truncated",
+			path:   "/v1/completions",
+			body:   `{"id":"x","choi`,
+			expErr: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			usage, err := ReadUsage(c.path, strings.NewReader(c.body))
+			if c.expErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expUsage, usage)
+		})
+	}
+}
@@ -17,7 +17,7 @@ type System struct {
 
 	ModelLoading ModelLoading `json:"modelLoading" validate:"required"`
 
-	ResourceProfiles map[string]ResourceProfile `json:"resourceProfiles" validate:"required"`
+	ResourceProfiles map[string]ResourceProfile `json:"resourceProfiles" validate:"required,dive"`
 
 	CacheProfiles map[string]CacheProfile `json:"cacheProfiles"`
 
@@ -37,8 +37,22 @@ type System struct {
 
 	ModelRollouts ModelRollouts `json:"modelRollouts"`
 
+	ModelDraining ModelDraining `json:"modelDraining"`
+
 	LeaderElection LeaderElection `json:"leaderElection"`
 
+	ModelProxy ModelProxy `json:"modelProxy"`
+
+	APIKeys APIKeys `json:"apiKeys"`
+
+	RateLimiting RateLimiting `json:"rateLimiting"`
+
+	RequestQueue RequestQueue `json:"requestQueue"`
+
+	OutlierDetection OutlierDetection `json:"outlierDetection"`
+
+	EngineMetricsScraping EngineMetricsScraping `json:"engineMetricsScraping"`
+
 	// AllowPodAddressOverride will allow the pod address to be overridden by the Model objects. Useful for development purposes.
 	AllowPodAddressOverride bool `json:"allowPodAddressOverride"`
 
@@ -77,6 +91,37 @@ func (s *System) DefaultAndValidate() error {
 		s.LeaderElection.RetryPeriod.Duration = 2 * time.Second
 	}
 
+	if s.ModelProxy.CallerHeader == "" {
+		s.ModelProxy.CallerHeader = "X-Caller-ID"
+	}
+
+	if s.RateLimiting.KeyBy == "" {
+		s.RateLimiting.KeyBy = RateLimitKeyByCaller
+	}
+
+	if s.OutlierDetection.Interval.Duration == 0 {
+		s.OutlierDetection.Interval.Duration = 30 * time.Second
+	}
+	if s.OutlierDetection.MinRequests == 0 {
+		s.OutlierDetection.MinRequests = 10
+	}
+	if s.OutlierDetection.BaseEjectionTime.Duration == 0 {
+		s.OutlierDetection.BaseEjectionTime.Duration = 30 * time.Second
+	}
+	if s.OutlierDetection.MaxEjectionTime.Duration == 0 {
+		s.OutlierDetection.MaxEjectionTime.Duration = 5 * time.Minute
+	}
+	if s.OutlierDetection.MaxEjectionPercent == 0 {
+		s.OutlierDetection.MaxEjectionPercent = 50
+	}
+
+	if s.EngineMetricsScraping.Interval.Duration == 0 {
+		s.EngineMetricsScraping.Interval.Duration = 2 * time.Second
+	}
+	if s.EngineMetricsScraping.Timeout.Duration == 0 {
+		s.EngineMetricsScraping.Timeout.Duration = time.Second
+	}
+
 	if s.CacheProfiles == nil {
 		s.CacheProfiles = map[string]CacheProfile{}
 	}
@@ -111,11 +156,134 @@ type LeaderElection struct {
 	RetryPeriod Duration `json:"retryPeriod"`
 }
 
+type ModelProxy struct {
+	// CallerHeader is the name of the request header that identifies
+	// the caller of a request. It is used to attribute token usage
+	// in metrics (i.e. for chargeback across teams).
+	// Defaults to "X-Caller-ID".
+	CallerHeader string `json:"callerHeader"`
+	// RetryInterruptedResponses buffers non-streamed responses so that
+	// requests can be retried when the connection to a backend fails
+	// while the response body is being read (i.e. during a rollout).
+	// Streamed responses and responses larger than 10 MiB are never retried
+	// once they have started.
+	RetryInterruptedResponses bool `json:"retryInterruptedResponses"`
+	// AllowPriorityHeader lets clients set the priority of their requests
+	// in the request queue with the "X-Priority" header. Clients are not
+	// authenticated (unless API keys are enabled, in which case the priority
+	// of the API key is used instead), so any client can prioritize its
+	// requests over those of others.
+	AllowPriorityHeader bool `json:"allowPriorityHeader"`
+}
+
+type APIKeys struct {
+	// Enabled requires clients of the OpenAI API to authenticate with an
+	// API key ("Authorization: Bearer <key>"). Keys are stored (hashed) in
+	// Secrets labeled "kubeai.org/api-key: true", each optionally restricting
+	// the accessible Models with a label selector.
+	Enabled bool `json:"enabled"`
+}
+
+type RateLimitKeyBy string
+
+const (
+	// RateLimitKeyByCaller identifies callers by their API key (if API keys
+	// are enabled) or else by the value of the ModelProxy.CallerHeader.
+	RateLimitKeyByCaller RateLimitKeyBy = "Caller"
+	// RateLimitKeyByLabelSelector identifies callers (tenants) by the label
+	// selectors of a request (X-Label-Selector headers and API key selectors).
+	RateLimitKeyByLabelSelector RateLimitKeyBy = "LabelSelector"
+)
+
+type RateLimiting struct {
+	// KeyBy determines how callers are identified.
+	// Requests that can not be attributed to a caller share a single limit.
+	// Defaults to "Caller".
+	KeyBy RateLimitKeyBy `json:"keyBy" validate:"oneof=Caller LabelSelector"`
+	// Default is the limit applied to each caller that is not listed in Callers.
+	Default RateLimit `json:"default"`
+	// Callers overrides the Default limit for specific callers.
+	Callers map[string]RateLimit `json:"callers,omitempty" validate:"dive"`
+}
+
+// RateLimit is the limit applied to a single caller across all KubeAI
+// replicas. It is approximated by dividing it evenly among the replicas,
+// which do not share state. A value of 0 means unlimited.
+type RateLimit struct {
+	RequestsPerMinute int64 `json:"requestsPerMinute" validate:"min=0"`
+	// TokensPerMinute limits the number of tokens (prompt + completion)
+	// that are reported by the backends in responses to a caller.
+	TokensPerMinute int64 `json:"tokensPerMinute" validate:"min=0"`
+}
+
+type RequestQueue struct {
+	// MaxDepth is the maximum number of requests that can wait for an
+	// endpoint of a single Model (per KubeAI replica). When the queue is full,
+	// a new request displaces the newest request with a lower priority
+	// or is rejected with a 429.
+	// 0 means unbounded.
+	MaxDepth int `json:"maxDepth" validate:"min=0"`
+	// MaxWait is the maximum time that a request will wait for an endpoint
+	// before being rejected with a 503.
+	// 0 means that requests wait until the client gives up.
+	MaxWait Duration `json:"maxWait"`
+}
+
+// OutlierDetection configures passive health checking of model endpoints.
+// Endpoints that fail are temporarily ejected from load balancing.
+type OutlierDetection struct {
+	// ConsecutiveFailures is the number of consecutive failed requests
+	// after which an endpoint is ejected.
+	// 0 disables ejection based on consecutive failures.
+	ConsecutiveFailures int `json:"consecutiveFailures" validate:"min=0"`
+	// FailureRatePercent is the percentage of failed requests within an
+	// Interval at which an endpoint is ejected.
+	// 0 disables ejection based on the failure rate.
+	FailureRatePercent int `json:"failureRatePercent" validate:"min=0,max=100"`
+	// MinRequests is the minimum number of requests within an Interval
+	// before the failure rate of an endpoint is considered.
+	MinRequests int `json:"minRequests" validate:"min=0"`
+	// Interval over which the failure rate is calculated.
+	Interval Duration `json:"interval"`
+	// SlowResponseThreshold is the time to receive the response headers
+	// after which a request is counted as failed.
+	// 0 means that slow responses are not counted as failures.
+	SlowResponseThreshold Duration `json:"slowResponseThreshold"`
+	// BaseEjectionTime is the duration of the first ejection of an endpoint.
+	// It doubles with every consecutive ejection of the same endpoint.
+	BaseEjectionTime Duration `json:"baseEjectionTime"`
+	// MaxEjectionTime caps the duration of an ejection.
+	MaxEjectionTime Duration `json:"maxEjectionTime"`
+	// MaxEjectionPercent is the maximum percentage of the endpoints of a
+	// Model that can be ejected at the same time.
+	MaxEjectionPercent int `json:"maxEjectionPercent" validate:"min=0,max=100"`
+}
+
+func (o OutlierDetection) Enabled() bool {
+	return o.ConsecutiveFailures > 0 || o.FailureRatePercent > 0
+}
+
+// EngineMetricsScraping configures how the metrics of model engines are
+// scraped for the EngineMetrics load balancing strategy.
+type EngineMetricsScraping struct {
+	// Interval between scrapes of each endpoint.
+	Interval Duration `json:"interval"`
+	// Timeout of a single scrape.
+	Timeout Duration `json:"timeout"`
+}
+
 type ModelRollouts struct {
 	// Surge is the number of additional Pods to create when rolling out an update.
 	Surge int32 `json:"surge"`
 }
 
+type ModelDraining struct {
+	// Timeout is the maximum time to wait for the in-flight requests of a
+	// ready Pod to complete before the Pod is deleted (on scale-down or
+	// rollout). Pods are deleted without draining if zero.
+	Timeout Duration `json:"timeout"`
+}
+
 type ModelAutoscaling struct {
 	// Interval is the time between each autoscaling check.
 	// Defaults to 10 seconds.
@@ -197,6 +365,17 @@ type ResourceProfile struct {
 	Tolerations      []corev1.Toleration `json:"tolerations,omitempty"`
 	SchedulerName    string              `json:"schedulerName,omitempty"`
 	RuntimeClassName *string             `json:"runtimeClassName,omitempty"`
+	// Weight is the relative serving capacity of Pods with this profile
+	// (i.e. 1 for an older GPU type and 3 for a GPU type that serves
+	// three times as many requests). Load is balanced in proportion to
+	// the weights of the Pods of a Model. Defaults to 1.
+	Weight int `json:"weight,omitempty" validate:"min=0"`
+	// Budget is the number of units of the profile (i.e. GPUs) that the
+	// replicas of all Models together may use. When the desired replicas of
+	// the autoscaled Models exceed the budget, replicas are allocated by the
+	// priority of the Models (see Model .spec.priorityClassName).
+	// Zero means unlimited.
+	Budget int64 `json:"budget,omitempty" validate:"min=0"`
 }
 
 type CacheProfile struct {
@@ -11,7 +11,7 @@ import (
 	"go.opentelemetry.io/otel/metric"
 )
 
-func (g *group) chwblGetAddr(key string, loadFactor float64, adapter string) (endpoint, bool) {
+func (g *group) chwblGetAddr(key string, loadFactor float64, f endpointFilter) (endpoint, bool) {
 	if len(g.chwblHashes) == 0 {
 		return endpoint{}, false
 	}
@@ -40,21 +40,14 @@ func (g *group) chwblGetAddr(key string, loadFactor float64, adapter string) (en
 			panic(fmt.Sprintf("endpoints corrupted, %q should be in map", name))
 		}
 
-		var adapterMatches bool
-		if adapter == "" {
-			adapterMatches = true
-		} else {
-			_, adapterMatches = ep.adapters[adapter]
-		}
-
-		if adapterMatches {
+		if f.matches(ep) {
 			if defaultEndpoint == nil {
 				// Save the first endpoint that has the adapter in case no
 				// endpoint is found with acceptable load.
 				defaultEndpoint = &ep
 				defaultEndpointName = name
 			}
-			if chwblLoadOK(ep.inFlight.Load(), g.totalInFlight.Load(), len(g.endpoints), loadFactor) {
+			if chwblLoadOK(ep.inFlight.Load(), g.totalInFlight.Load(), ep.weight, g.totalWeight, loadFactor) {
 				metrics.InferenceRequestsHashLookupIterations.Record(context.Background(), int64(n+1))
 				metrics.InferenceRequestsHashLookupFinal.Add(context.Background(), 1, metric.WithAttributeSet(attribute.NewSet(
 					metrics.AttrEndpoint.String(name),
@@ -149,13 +142,15 @@ func chwblEndpointReplicaHashInput(name string, replica int) string {
 	return fmt.Sprintf("%s%d", name, replica)
 }
 
-func chwblLoadOK(load, totalLoad int64, n int, loadFactor float64) bool {
+// chwblLoadOK returns true if the load of an endpoint is within the bound
+// of its share of the total load (according to its weight).
+func chwblLoadOK(load, totalLoad int64, weight, totalWeight, loadFactor float64) bool {
 	if totalLoad == 0 {
 		return true
 	}
 
 	// The "+1"s are to simulate the load of the new request.
-	avgLoad := float64(totalLoad+1) / float64(n)
+	avgLoad := float64(totalLoad+1) / totalWeight * weight
 	threshold := avgLoad * loadFactor
 	ok := float64(load) <= threshold
 	return ok
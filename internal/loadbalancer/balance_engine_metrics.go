@@ -0,0 +1,36 @@
+package loadbalancer
+
+import (
+	v1 "github.com/kubeai-project/kubeai/api/k8s/v1"
+)
+
+// getAddrEngineMetrics returns the endpoint with the lowest score based on the
+// metrics reported by the engines. If metrics are not available for all
+// endpoints, it falls back to the least load strategy.
+func (g *group) getAddrEngineMetrics(f endpointFilter, cfg v1.EngineMetrics) (endpoint, bool) {
+	var bestEp endpoint
+	var found bool
+	var minScore float64
+	for _, ep := range g.endpoints {
+		if !f.matches(ep) {
+			continue
+		}
+		m := ep.engine.load(f.now)
+		if m == nil {
+			return g.getAddrLeastLoad(f)
+		}
+		// The in-flight count is up to date while the number of running
+		// requests (which includes requests from other KubeAI replicas)
+		// is only as recent as the last scrape.
+		score := max(float64(ep.inFlight.Load()), m.Running) +
+			float64(cfg.QueueWeight)*m.Waiting +
+			float64(cfg.KVCacheWeight)*m.KVCacheUsage
+		if !found || score < minScore {
+			bestEp = ep
+			found = true
+			minScore = score
+		}
+	}
+
+	return bestEp, found
+}
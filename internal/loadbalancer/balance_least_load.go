@@ -1,21 +1,20 @@
 package loadbalancer
 
-func (g *group) getAddrLeastLoad(adapter string) (endpoint, bool) {
+// getAddrLeastLoad returns the endpoint with the fewest in-flight requests
+// relative to its weight.
+func (g *group) getAddrLeastLoad(f endpointFilter) (endpoint, bool) {
 	var bestEp endpoint
 	var found bool
-	var minInFlight int
+	var minLoad float64
 	for _, ep := range g.endpoints {
-		if adapter != "" {
-			// Skip endpoints that don't have the requested adapter.
-			if _, ok := ep.adapters[adapter]; !ok {
-				continue
-			}
+		if !f.matches(ep) {
+			continue
 		}
-		inFlight := int(ep.inFlight.Load())
-		if !found || inFlight < minInFlight {
+		load := float64(ep.inFlight.Load()) / ep.weight
+		if !found || load < minLoad {
 			bestEp = ep
 			found = true
-			minInFlight = inFlight
+			minLoad = load
 		}
 	}
 
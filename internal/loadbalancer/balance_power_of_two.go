@@ -0,0 +1,44 @@
+package loadbalancer
+
+import "math/rand/v2"
+
+// getAddrPowerOfTwo samples two endpoints at random and returns the one
+// with fewer in-flight requests (relative to its weight). Unlike the least
+// load strategy, this avoids multiple KubeAI replicas (that each only know
+// about their own in-flight requests) sending bursts of requests to the
+// same endpoint.
+func (g *group) getAddrPowerOfTwo(f endpointFilter) (endpoint, bool) {
+	// Reservoir sampling of two endpoints (without allocating).
+	var a, b endpoint
+	var n int
+	for _, ep := range g.endpoints {
+		if !f.matches(ep) {
+			continue
+		}
+		n++
+		switch {
+		case n == 1:
+			a = ep
+		case n == 2:
+			b = ep
+		default:
+			switch rand.IntN(n) {
+			case 0:
+				a = ep
+			case 1:
+				b = ep
+			}
+		}
+	}
+
+	switch n {
+	case 0:
+		return endpoint{}, false
+	case 1:
+		return a, true
+	}
+	if float64(b.inFlight.Load())/b.weight < float64(a.inFlight.Load())/a.weight {
+		return b, true
+	}
+	return a, true
+}
@@ -0,0 +1,95 @@
+package loadbalancer
+
+import (
+	"strconv"
+	"sync"
+)
+
+// prefixIndexCapacity is the number of blocks that are retained per
+// generation of the prefix index of a group.
+const prefixIndexCapacity = 1 << 14
+
+// getAddrPrefixBlocks returns the endpoint that most recently served the
+// longest chain of the given blocks (cumulative hashes), as long as its load
+// is acceptable. Requests that do not match any chain are hashed onto the
+// CHWBL ring by their full chain of blocks.
+func (g *group) getAddrPrefixBlocks(blocks []uint64, loadFactor float64, f endpointFilter) (endpoint, bool) {
+	for i := len(blocks) - 1; i >= 0; i-- {
+		name, ok := g.prefixIndex.get(blocks[i])
+		if !ok {
+			continue
+		}
+		ep, ok := g.endpoints[name]
+		if !ok || !f.matches(ep) {
+			continue
+		}
+		if !chwblLoadOK(ep.inFlight.Load(), g.totalInFlight.Load(), ep.weight, g.totalWeight, loadFactor) {
+			continue
+		}
+		g.prefixIndex.put(blocks, name)
+		return ep, true
+	}
+
+	ep, found := g.chwblGetAddr(strconv.FormatUint(blocks[len(blocks)-1], 16), loadFactor, f)
+	if found {
+		g.prefixIndex.put(blocks, ep.name)
+	}
+	return ep, found
+}
+
+// prefixIndex is an approximate index of the blocks that were recently sent
+// to each endpoint (and are likely to be in its prefix cache). Memory is
+// bounded by keeping two generations of entries: once the current generation
+// is full, it replaces the previous one. Blocks that are looked up are
+// promoted to the current generation.
+type prefixIndex struct {
+	mtx      sync.Mutex
+	capacity int
+	current  map[uint64]string
+	previous map[uint64]string
+}
+
+func newPrefixIndex(capacity int) *prefixIndex {
+	return &prefixIndex{
+		capacity: capacity,
+		current:  map[uint64]string{},
+		previous: map[uint64]string{},
+	}
+}
+
+// get returns the name of the endpoint that most recently served the block.
+func (x *prefixIndex) get(block uint64) (string, bool) {
+	x.mtx.Lock()
+	defer x.mtx.Unlock()
+	if name, ok := x.current[block]; ok {
+		return name, true
+	}
+	if name, ok := x.previous[block]; ok {
+		x.set(block, name)
+		return name, true
+	}
+	return "", false
+}
+
+// put records that the blocks were sent to the given endpoint.
+func (x *prefixIndex) put(blocks []uint64, name string) {
+	x.mtx.Lock()
+	defer x.mtx.Unlock()
+	for _, b := range blocks {
+		x.set(b, name)
+	}
+}
+
+func (x *prefixIndex) set(block uint64, name string) {
+	if _, ok := x.current[block]; !ok && len(x.current) >= x.capacity {
+		x.previous = x.current
+		x.current = make(map[uint64]string, x.capacity)
+	}
+	x.current[block] = name
+}
+
+func (x *prefixIndex) len() int {
+	x.mtx.Lock()
+	defer x.mtx.Unlock()
+	return len(x.current) + len(x.previous)
+}
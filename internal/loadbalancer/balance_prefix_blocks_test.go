@@ -0,0 +1,87 @@
+package loadbalancer
+
+import (
+	"context"
+	"testing"
+
+	v1 "github.com/kubeai-project/kubeai/api/k8s/v1"
+	"github.com/kubeai-project/kubeai/internal/apiutils"
+	"github.com/kubeai-project/kubeai/internal/config"
+	"github.com/kubeai-project/kubeai/internal/metrics/metricstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrefixBlocks(t *testing.T) {
+	metricstest.Init(t)
+
+	g := newEndpointGroup(v1.LoadBalancing{PrefixHash: v1.PrefixHash{Replication: 256}}, config.RequestQueue{}, config.OutlierDetection{})
+	g.reconcileEndpoints(map[string]endpoint{
+		"pod1": {address: "10.0.0.1:8000"},
+		"pod2": {address: "10.0.0.2:8000"},
+		"pod3": {address: "10.0.0.3:8000"},
+	})
+	getEndpoint := func(blocks ...uint64) endpoint {
+		t.Helper()
+		addr, done, err := g.getBestAddr(context.Background(), &apiutils.Request{
+			PrefixBlocks: blocks,
+			LoadBalancing: v1.LoadBalancing{
+				Strategy:   v1.PrefixHashStrategy,
+				PrefixHash: v1.PrefixHash{MeanLoadPercentage: 125, Mode: v1.BlocksPrefixHashMode},
+			},
+		}, false)
+		require.NoError(t, err)
+		done()
+		for _, ep := range g.endpoints {
+			if ep.address == addr {
+				return ep
+			}
+		}
+		t.Fatalf("unknown address %q", addr)
+		return endpoint{}
+	}
+
+	// Turns of a conversation stick to the same endpoint.
+	first := getEndpoint(1, 2)
+	require.Equal(t, first.name, getEndpoint(1, 2, 3, 4).name)
+	require.Equal(t, first.name, getEndpoint(1, 2, 3, 4, 5, 6).name)
+
+	// The longest chain wins.
+	g.prefixIndex.put([]uint64{1, 2, 3, 4, 5, 6, 7, 8}, "pod3")
+	g.prefixIndex.put([]uint64{1, 2, 3, 4, 20, 21}, "pod2")
+	require.Equal(t, "pod3", getEndpoint(1, 2, 3, 4, 5, 6, 7, 8, 100).name)
+	require.Equal(t, "pod2", getEndpoint(1, 2, 3, 4, 20, 21, 22).name)
+
+	// Overloaded endpoints are skipped.
+	g.addInFlight(g.endpoints["pod2"].inFlight, 10)
+	moved := getEndpoint(1, 2, 3, 4, 20, 21, 22, 23)
+	require.NotEqual(t, "pod2", moved.name)
+	g.addInFlight(g.endpoints["pod2"].inFlight, -10)
+	require.Equal(t, moved.name, getEndpoint(1, 2, 3, 4, 20, 21, 22, 23, 24).name, "the chain should have moved")
+
+	// Removed endpoints are ignored.
+	g.reconcileEndpoints(map[string]endpoint{
+		"pod1": {address: "10.0.0.1:8000"},
+		"pod2": {address: "10.0.0.2:8000"},
+	})
+	require.NotEqual(t, "pod3", getEndpoint(1, 2, 3, 4, 5, 6, 7, 8, 100, 101).name)
+}
+
+func TestPrefixIndexCapacity(t *testing.T) {
+	x := newPrefixIndex(2)
+	x.put([]uint64{1, 2, 3}, "pod1")
+	require.Equal(t, 3, x.len())
+
+	// Lookups promote blocks of the previous generation.
+	name, ok := x.get(1)
+	require.True(t, ok)
+	require.Equal(t, "pod1", name)
+
+	x.put([]uint64{4}, "pod2")
+	_, ok = x.get(2)
+	require.False(t, ok, "block 2 should have been evicted")
+	for _, b := range []uint64{1, 3, 4} {
+		_, ok := x.get(b)
+		require.True(t, ok, "block %d should be retained", b)
+	}
+	require.LessOrEqual(t, x.len(), 4)
+}
@@ -0,0 +1,158 @@
+package loadbalancer
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	io_prometheus_client "github.com/prometheus/client_model/go"
+)
+
+// Names of the vLLM metrics that are used to score endpoints.
+var (
+	engineMetricsWaiting = []string{"vllm:num_requests_waiting"}
+	engineMetricsRunning = []string{"vllm:num_requests_running"}
+	// The name of the KV cache metric changed in later versions of vLLM.
+	engineMetricsKVCacheUsage = []string{"vllm:kv_cache_usage_perc", "vllm:gpu_cache_usage_perc"}
+)
+
+// EngineMetrics are the metrics of an engine at the time of a scrape.
+type EngineMetrics struct {
+	// Waiting is the number of requests in the queue of the engine.
+	Waiting float64
+	// Running is the number of requests that the engine is processing.
+	Running float64
+	// KVCacheUsage is the fraction of the KV cache that is used (0-1).
+	KVCacheUsage float64
+
+	// expiresAt is the time after which the metrics are considered stale.
+	expiresAt time.Time
+}
+
+// engineState holds the last metrics that were scraped from an endpoint.
+type engineState struct {
+	metrics atomic.Pointer[EngineMetrics]
+	// failing is true if the last scrape failed (used to avoid repetitive logging).
+	failing atomic.Bool
+}
+
+// load returns the metrics of the engine or nil if they are not available.
+func (s *engineState) load(now time.Time) *EngineMetrics {
+	m := s.metrics.Load()
+	if m == nil || now.After(m.expiresAt) {
+		return nil
+	}
+	return m
+}
+
+// Start periodically scrapes the metrics of the endpoints of groups that use
+// the EngineMetrics strategy. It implements manager.Runnable.
+func (r *LoadBalancer) Start(ctx context.Context) error {
+	ticker := time.NewTicker(r.scrapeCfg.Interval.Duration)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			r.scrapeEngineMetrics(ctx)
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable.
+// Every replica scrapes the endpoints that it balances load across.
+func (r *LoadBalancer) NeedLeaderElection() bool {
+	return false
+}
+
+func (r *LoadBalancer) scrapeEngineMetrics(ctx context.Context) {
+	r.endpointsMtx.Lock()
+	var groups []*group
+	for _, g := range r.groups {
+		if g.engineMetricsUsed.Load() {
+			groups = append(groups, g)
+		}
+	}
+	r.endpointsMtx.Unlock()
+
+	var wg sync.WaitGroup
+	for _, g := range groups {
+		g.mtx.RLock()
+		for name, ep := range g.endpoints {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				m, err := r.scrapeEndpoint(ctx, ep.address)
+				if err != nil {
+					ep.engine.metrics.Store(nil)
+					if !ep.engine.failing.Swap(true) {
+						log.Printf("Unable to scrape engine metrics of endpoint %q, falling back to in-flight requests: %v", name, err)
+					}
+					return
+				}
+				// Metrics are considered stale after a few missed scrapes.
+				m.expiresAt = time.Now().Add(3 * r.scrapeCfg.Interval.Duration)
+				ep.engine.metrics.Store(m)
+				ep.engine.failing.Store(false)
+			}()
+		}
+		g.mtx.RUnlock()
+	}
+	wg.Wait()
+}
+
+func (r *LoadBalancer) scrapeEndpoint(ctx context.Context, address string) (*EngineMetrics, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.scrapeCfg.Timeout.Duration)
+	defer cancel()
+	return ScrapeEngineMetrics(ctx, address)
+}
+
+// ScrapeEngineMetrics scrapes the metrics of the engine (vLLM) at the given
+// address ("<ip>:<port>").
+func ScrapeEngineMetrics(ctx context.Context, address string) (*EngineMetrics, error) {
+	metricFamilies, err := ScrapeMetrics(ctx, http.DefaultClient, address)
+	if err != nil {
+		return nil, err
+	}
+	return engineMetricsFrom(metricFamilies)
+}
+
+func engineMetricsFrom(metricFamilies map[string]*io_prometheus_client.MetricFamily) (*EngineMetrics, error) {
+	m := &EngineMetrics{}
+	var found bool
+	for _, v := range []struct {
+		names []string
+		dst   *float64
+	}{
+		{engineMetricsWaiting, &m.Waiting},
+		{engineMetricsRunning, &m.Running},
+		{engineMetricsKVCacheUsage, &m.KVCacheUsage},
+	} {
+		for _, name := range v.names {
+			if fam, ok := metricFamilies[name]; ok {
+				*v.dst = sumGauges(fam)
+				found = true
+				break
+			}
+		}
+	}
+	if !found {
+		return nil, errors.New("no engine metrics found")
+	}
+	return m, nil
+}
+
+func sumGauges(mf *io_prometheus_client.MetricFamily) float64 {
+	var sum float64
+	for _, m := range mf.Metric {
+		if m.Gauge != nil {
+			sum += m.GetGauge().GetValue()
+		}
+	}
+	return sum
+}
@@ -0,0 +1,141 @@
+package loadbalancer
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/kubeai-project/kubeai/api/k8s/v1"
+	"github.com/kubeai-project/kubeai/internal/apiutils"
+	"github.com/kubeai-project/kubeai/internal/config"
+	"github.com/kubeai-project/kubeai/internal/metrics/metricstest"
+	"github.com/stretchr/testify/require"
+)
+
+const testVLLMMetrics = `# HELP vllm:num_requests_running Number of requests currently running on GPU.
+# TYPE vllm:num_requests_running gauge
+vllm:num_requests_running{model_name="llama"} 3.0
+# HELP vllm:num_requests_waiting Number of requests waiting to be processed.
+# TYPE vllm:num_requests_waiting gauge
+vllm:num_requests_waiting{model_name="llama"} 5.0
+# HELP vllm:gpu_cache_usage_perc GPU KV-cache usage. 1 means 100 percent usage.
+# TYPE vllm:gpu_cache_usage_perc gauge
+vllm:gpu_cache_usage_perc{model_name="llama"} 0.75
+`
+
+func TestParseEngineMetrics(t *testing.T) {
+	parseEngineMetrics := func(r io.Reader) (*EngineMetrics, error) {
+		metricFamilies, err := parseMetrics(r)
+		require.NoError(t, err)
+		return engineMetricsFrom(metricFamilies)
+	}
+	m, err := parseEngineMetrics(strings.NewReader(testVLLMMetrics))
+	require.NoError(t, err)
+	require.Equal(t, &EngineMetrics{Waiting: 5, Running: 3, KVCacheUsage: 0.75}, m)
+
+	m, err = parseEngineMetrics(strings.NewReader(`# TYPE vllm:kv_cache_usage_perc gauge
+vllm:kv_cache_usage_perc{model_name="llama"} 0.5
+`))
+	require.NoError(t, err)
+	require.Equal(t, &EngineMetrics{KVCacheUsage: 0.5}, m)
+
+	_, err = parseEngineMetrics(strings.NewReader(`# TYPE other_metric gauge
+other_metric 1.0
+`))
+	require.Error(t, err, "engines that do not report vLLM metrics should not be scored")
+}
+
+func TestEngineMetricsStrategy(t *testing.T) {
+	metricstest.Init(t)
+
+	g := newEndpointGroup(v1.LoadBalancing{}, config.RequestQueue{}, config.OutlierDetection{})
+	now := time.Now()
+	g.now = func() time.Time { return now }
+	g.reconcileEndpoints(map[string]endpoint{
+		"pod1": {address: "10.0.0.1:8000"},
+		"pod2": {address: "10.0.0.2:8000"},
+	})
+	req := &apiutils.Request{
+		LoadBalancing: v1.LoadBalancing{
+			Strategy:      v1.EngineMetricsStrategy,
+			EngineMetrics: v1.EngineMetrics{QueueWeight: 2, KVCacheWeight: 10},
+		},
+	}
+	setMetrics := func(name string, m *EngineMetrics) {
+		if m != nil {
+			m.expiresAt = now.Add(time.Second)
+		}
+		g.endpoints[name].engine.metrics.Store(m)
+	}
+	getAddr := func() string {
+		addr, done, err := g.getBestAddr(context.Background(), req, false)
+		require.NoError(t, err)
+		done()
+		return addr
+	}
+
+	// KubeAI sent more requests to pod1 but pod2 has a long queue.
+	g.endpoints["pod1"].inFlight.Store(2)
+	setMetrics("pod1", &EngineMetrics{Running: 2})
+	setMetrics("pod2", &EngineMetrics{Running: 4, Waiting: 3})
+	require.Equal(t, "10.0.0.1:8000", getAddr())
+
+	// pod1 is almost out of KV cache.
+	setMetrics("pod1", &EngineMetrics{Running: 2, KVCacheUsage: 0.99})
+	setMetrics("pod2", &EngineMetrics{Running: 4, KVCacheUsage: 0.1})
+	require.Equal(t, "10.0.0.2:8000", getAddr())
+
+	// Metrics of pod2 are missing: fall back to in-flight requests.
+	setMetrics("pod2", nil)
+	require.Equal(t, "10.0.0.2:8000", getAddr())
+	g.endpoints["pod2"].inFlight.Store(5)
+	require.Equal(t, "10.0.0.1:8000", getAddr())
+
+	// Metrics of pod2 are stale.
+	setMetrics("pod1", &EngineMetrics{Running: 20})
+	setMetrics("pod2", &EngineMetrics{Running: 0})
+	now = now.Add(2 * time.Second)
+	require.Equal(t, "10.0.0.1:8000", getAddr())
+}
+
+func TestScrapeEngineMetrics(t *testing.T) {
+	metricstest.Init(t)
+
+	vllm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, metricsPath, r.URL.Path)
+		_, _ = w.Write([]byte(testVLLMMetrics))
+	}))
+	defer vllm.Close()
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer other.Close()
+
+	lb := &LoadBalancer{
+		groups: map[string]*group{},
+		scrapeCfg: config.EngineMetricsScraping{
+			Interval: config.Duration{Duration: time.Minute},
+			Timeout:  config.Duration{Duration: time.Second},
+		},
+	}
+	g := lb.getOrCreateEndpointGroup("my-model", v1.LoadBalancing{})
+	g.reconcileEndpoints(map[string]endpoint{
+		"vllm":  {address: vllm.Listener.Addr().String()},
+		"other": {address: other.Listener.Addr().String()},
+	})
+
+	lb.scrapeEngineMetrics(context.Background())
+	require.Nil(t, g.endpoints["vllm"].engine.load(time.Now()), "groups that do not use the strategy should not be scraped")
+
+	g.engineMetricsUsed.Store(true)
+	lb.scrapeEngineMetrics(context.Background())
+	m := g.endpoints["vllm"].engine.load(time.Now())
+	require.NotNil(t, m)
+	require.Equal(t, 5.0, m.Waiting)
+	require.Nil(t, g.endpoints["other"].engine.load(time.Now()))
+	require.True(t, g.endpoints["other"].engine.failing.Load())
+}
@@ -2,21 +2,42 @@ package loadbalancer
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	v1 "github.com/kubeai-project/kubeai/api/k8s/v1"
 	"github.com/kubeai-project/kubeai/internal/apiutils"
+	"github.com/kubeai-project/kubeai/internal/config"
+	"github.com/kubeai-project/kubeai/internal/metrics"
+	"go.opentelemetry.io/otel/metric"
 )
 
-func newEndpointGroup(lb v1.LoadBalancing) *group {
+// ErrAdapterNotLoaded is returned for requests for adapters that are loaded on
+// demand if no endpoint had the adapter loaded for adapterLoadWait (i.e.
+// because it was unloaded to make room for another adapter). The caller
+// should load the adapter again.
+var ErrAdapterNotLoaded = errors.New("adapter is not loaded into any endpoint")
+
+// adapterLoadWait is how long requests wait for an endpoint with an adapter
+// that is loaded on demand. It leaves the load balancer time to observe
+// adapters that were just loaded.
+var adapterLoadWait = 5 * time.Second
+
+func newEndpointGroup(lb v1.LoadBalancing, queueCfg config.RequestQueue, outlierCfg config.OutlierDetection) *group {
 	g := &group{
+		queue:             newQueue(queueCfg),
+		outlierDetection:  outlierCfg,
+		now:               time.Now,
+		localZone:         func() string { return "" },
 		endpoints:         make(map[string]endpoint),
 		totalInFlight:     &atomic.Int64{},
 		chwblReplication:  lb.PrefixHash.Replication,
 		chwblHashes:       map[uint64]string{},
 		chwblSortedHashes: []uint64{},
+		prefixIndex:       newPrefixIndex(prefixIndexCapacity),
 		bcast:             make(chan struct{}),
 	}
 	return g
@@ -28,6 +49,8 @@ type group struct {
 	endpoints map[string]endpoint
 
 	totalInFlight *atomic.Int64
+	// totalWeight is the sum of the weights of all endpoints.
+	totalWeight float64
 
 	// the number of times an endpoint is replicated on the hash ring
 	chwblReplication int
@@ -36,55 +59,289 @@ type group struct {
 	// sorted list of hashed node-replicas
 	chwblSortedHashes []uint64
 
+	// prefixIndex tracks the endpoints that served chains of prefix blocks.
+	prefixIndex *prefixIndex
+
 	bmtx  sync.RWMutex
 	bcast chan struct{} // closed when there's a broadcast
+
+	// queue bounds the requests that are waiting for an endpoint.
+	queue *queue
+
+	outlierDetection config.OutlierDetection
+	// hmtx guards the health state of the endpoints.
+	hmtx sync.Mutex
+	// onEject is called when an endpoint is ejected (optional).
+	onEject func(name, reason string, d time.Duration)
+
+	// engineMetricsUsed is set once the EngineMetrics strategy is used
+	// to enable scraping of the metrics of the endpoints.
+	engineMetricsUsed atomic.Bool
+
+	// localZone returns the zone of the KubeAI replica (empty if unknown).
+	localZone func() string
+
+	// now is a hook for testing.
+	now func() time.Time
 }
 
 type endpoint struct {
+	name    string
 	address string
+	// zone of the node of the endpoint (empty if unknown).
+	zone string
+	// weight is the relative serving capacity of the endpoint.
+	weight float64
+	// draining endpoints are about to be deleted and receive no new
+	// requests. They are kept to track their in-flight requests.
+	draining bool
 
 	inFlight *atomic.Int64
+	health   *endpointHealth
+	engine   *engineState
 
 	adapters map[string]struct{}
 }
 
+// concurrencyLimit returns the maximum number of in-flight requests of the
+// endpoint given the limit of an endpoint with a weight of 1.
+func (ep endpoint) concurrencyLimit(maxConcurrency int) int64 {
+	return max(1, int64(float64(maxConcurrency)*ep.weight))
+}
+
+// endpointFilter matches the endpoints that are able to serve a request.
+type endpointFilter struct {
+	adapter string
+	// zone restricts the endpoints to a zone (optional).
+	zone string
+	// maxConcurrency excludes endpoints that are at their concurrency
+	// limit (optional).
+	maxConcurrency int
+	now            time.Time
+}
+
+func (f endpointFilter) matches(ep endpoint) bool {
+	if ep.draining || ep.health.ejected(f.now) {
+		return false
+	}
+	if f.adapter != "" {
+		// Skip endpoints that don't have the requested adapter.
+		if _, ok := ep.adapters[f.adapter]; !ok {
+			return false
+		}
+	}
+	if f.zone != "" && ep.zone != f.zone {
+		return false
+	}
+	if f.maxConcurrency > 0 && ep.inFlight.Load() >= ep.concurrencyLimit(f.maxConcurrency) {
+		return false
+	}
+	return true
+}
+
 // getBestAddr returns the best "IP:Port". It blocks until there are available endpoints
-// in the endpoint group.
+// in the endpoint group. While blocked, the request is held in the queue of the group.
 func (g *group) getBestAddr(ctx context.Context, req *apiutils.Request, awaitChangeEndpoints bool) (string, func(), error) {
-	g.mtx.RLock()
-	// await endpoints exists
-	for awaitChangeEndpoints || len(g.endpoints) == 0 {
-		g.mtx.RUnlock()
-		select {
-		case <-g.awaitEndpoints():
-		case <-ctx.Done():
-			return "", func() {}, ctx.Err()
+	var (
+		queued  *waiter
+		timeout <-chan time.Time
+		// changed is closed when the endpoints of the group changed or
+		// an in-flight request completed.
+		changed chan struct{}
+		// woken is true if it is the turn of the queued request to look
+		// for an endpoint. The turn is passed on to the next request in
+		// the queue after looking.
+		woken bool
+		// adapterMissing fires if no endpoint has the adapter of a
+		// request that is loaded on demand.
+		adapterMissing <-chan time.Time
+	)
+	passTurn := func() {
+		if woken {
+			g.queue.wakeNext(queued)
+			woken = false
 		}
+	}
+	start := time.Now()
+	defer func() {
+		if queued != nil {
+			passTurn()
+			g.queue.remove(queued)
+			metrics.InferenceRequestsQueued.Add(ctx, -1, metric.WithAttributes(metrics.AttrRequestModel.String(req.Model)))
+		}
+		// Requests that did not wait are recorded as well so that the
+		// autoscaler can calculate the average wait time of all requests.
+		metrics.InferenceRequestsQueueWait.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(metrics.AttrRequestModel.String(req.Model)))
+	}()
+
+	for {
 		g.mtx.RLock()
+		// await endpoints exists
+		for awaitChangeEndpoints || len(g.endpoints) == 0 {
+			if changed == nil {
+				// Obtain the broadcast channel while holding the lock to
+				// avoid missing a change that happens after unlocking.
+				changed = g.awaitEndpoints()
+			}
+			g.mtx.RUnlock()
+
+			if queued == nil {
+				w, err := g.queue.add(req.Priority)
+				if err != nil {
+					return "", func() {}, err
+				}
+				queued = w
+				metrics.InferenceRequestsQueued.Add(ctx, 1, metric.WithAttributes(metrics.AttrRequestModel.String(req.Model)))
+				if g.queue.maxWait > 0 {
+					timer := time.NewTimer(g.queue.maxWait)
+					defer timer.Stop()
+					timeout = timer.C
+				}
+				select {
+				case <-changed:
+					// The endpoints changed since they were checked.
+					queued.signal()
+				default:
+				}
+			}
+			passTurn()
+
+			select {
+			case <-queued.wake:
+				woken = true
+			case <-queued.evicted:
+				return "", func() {}, ErrQueueFull
+			case <-timeout:
+				return "", func() {}, ErrQueueWaitExceeded
+			case <-adapterMissing:
+				if !g.hasAdapter(req.Adapter) {
+					return "", func() {}, ErrAdapterNotLoaded
+				}
+				adapterMissing = nil
+			case <-ctx.Done():
+				return "", func() {}, ctx.Err()
+			}
+			awaitChangeEndpoints = false
+			changed = nil
+			g.mtx.RLock()
+		}
+
+		// Obtain the broadcast channel before selecting an endpoint to avoid
+		// missing in-flight requests that complete in the meantime.
+		changed = g.awaitEndpoints()
+		f := endpointFilter{
+			adapter:        req.Adapter,
+			maxConcurrency: req.LoadBalancing.MaxConcurrencyPerEndpoint,
+			now:            g.now(),
+		}
+		if za := req.LoadBalancing.ZoneAffinity; za.Enabled {
+			f.zone = g.preferredZone(f, za.MaxInFlightRequests)
+		}
+		ep, found, err := g.getAddr(req, f)
+		if err != nil {
+			g.mtx.RUnlock()
+			return "", func() {}, err
+		}
+
+		if !found {
+			if req.AdapterOnDemand && adapterMissing == nil && !g.hasAdapterLocked(req.Adapter) {
+				timer := time.NewTimer(adapterLoadWait)
+				defer timer.Stop()
+				adapterMissing = timer.C
+			}
+			g.mtx.RUnlock()
+			awaitChangeEndpoints = true
+			continue
+		}
+
+		if f.maxConcurrency == 0 {
+			g.addInFlight(ep.inFlight, 1)
+		} else if !g.tryAddInFlight(ep.inFlight, ep.concurrencyLimit(f.maxConcurrency)) {
+			// Another request took the last slot of the endpoint in the meantime.
+			g.mtx.RUnlock()
+			continue
+		}
+		inFlightAttrs := metric.WithAttributes(
+			metrics.AttrRequestModel.String(req.Model),
+			metrics.AttrRequestAdapter.String(req.Adapter),
+			metrics.AttrEndpoint.String(ep.name),
+		)
+		metrics.EndpointRequestsInFlight.Add(ctx, 1, inFlightAttrs)
+		decFunc := func() {
+			g.addInFlight(ep.inFlight, -1)
+			metrics.EndpointRequestsInFlight.Add(context.Background(), -1, inFlightAttrs)
+			if f.maxConcurrency > 0 {
+				// Wake up requests that are waiting for a free slot.
+				g.broadcastEndpoints()
+			}
+		}
+		g.mtx.RUnlock()
+		return ep.address, decFunc, nil
 	}
+}
 
+// getAddr selects an endpoint that matches the filter according to the
+// load balancing configuration of the request.
+func (g *group) getAddr(req *apiutils.Request, f endpointFilter) (endpoint, bool, error) {
 	var ep endpoint
 	var found bool
+	if req.SessionID != "" {
+		// Requests of the same session should hit the same endpoint
+		// (as long as it is not overloaded) to reuse its KV cache.
+		ep, found = g.chwblGetAddr(req.Adapter+req.SessionID, float64(req.LoadBalancing.PrefixHash.MeanLoadPercentage)/100, f)
+		return ep, found, nil
+	}
+
 	switch req.LoadBalancing.Strategy {
 	case v1.PrefixHashStrategy:
-		ep, found = g.chwblGetAddr(req.Adapter+req.Prefix, float64(req.LoadBalancing.PrefixHash.MeanLoadPercentage)/100, req.Adapter)
+		if len(req.PrefixBlocks) > 0 {
+			ep, found = g.getAddrPrefixBlocks(req.PrefixBlocks, float64(req.LoadBalancing.PrefixHash.MeanLoadPercentage)/100, f)
+		} else {
+			ep, found = g.chwblGetAddr(req.Adapter+req.Prefix, float64(req.LoadBalancing.PrefixHash.MeanLoadPercentage)/100, f)
+		}
 	case v1.LeastLoadStrategy:
-		ep, found = g.getAddrLeastLoad(req.Adapter)
+		ep, found = g.getAddrLeastLoad(f)
+	case v1.PowerOfTwoStrategy:
+		ep, found = g.getAddrPowerOfTwo(f)
+	case v1.EngineMetricsStrategy:
+		g.engineMetricsUsed.Store(true)
+		ep, found = g.getAddrEngineMetrics(f, req.LoadBalancing.EngineMetrics)
 	default:
-		return "", func() {}, fmt.Errorf("unknown load balancing strategy: %v", req.LoadBalancing.Strategy)
+		return endpoint{}, false, fmt.Errorf("unknown load balancing strategy: %v", req.LoadBalancing.Strategy)
 	}
+	return ep, found, nil
+}
 
-	if !found {
-		g.mtx.RUnlock()
-		return g.getBestAddr(ctx, req, true)
+// preferredZone returns the zone of the KubeAI replica if any endpoint in
+// that zone matches the filter and has fewer than maxInFlight in-flight
+// requests. Otherwise requests can be sent to any zone.
+func (g *group) preferredZone(f endpointFilter, maxInFlight int) string {
+	f.zone = g.localZone()
+	if f.zone == "" {
+		return ""
 	}
+	for _, ep := range g.endpoints {
+		if f.matches(ep) && ep.inFlight.Load() < int64(maxInFlight) {
+			return f.zone
+		}
+	}
+	return ""
+}
+
+// hasAdapter reports whether any endpoint that is not draining has the adapter loaded.
+func (g *group) hasAdapter(adapter string) bool {
+	g.mtx.RLock()
+	defer g.mtx.RUnlock()
+	return g.hasAdapterLocked(adapter)
+}
 
-	g.addInFlight(ep.inFlight, 1)
-	decFunc := func() {
-		g.addInFlight(ep.inFlight, -1)
+func (g *group) hasAdapterLocked(adapter string) bool {
+	for _, ep := range g.endpoints {
+		if _, ok := ep.adapters[adapter]; ok && !ep.draining {
+			return true
+		}
 	}
-	g.mtx.RUnlock()
-	return ep.address, decFunc, nil
+	return false
 }
 
 func (g *group) awaitEndpoints() chan struct{} {
@@ -99,22 +356,46 @@ func (g *group) getAllAddrs() []string {
 
 	var hosts []string
 	for _, ep := range g.endpoints {
+		if ep.draining {
+			continue
+		}
 		hosts = append(hosts, ep.address)
 	}
 
 	return hosts
 }
 
+// getInFlight returns the number of in-flight requests of the endpoint
+// with the given name.
+func (g *group) getInFlight(name string) int64 {
+	g.mtx.RLock()
+	defer g.mtx.RUnlock()
+	ep, ok := g.endpoints[name]
+	if !ok {
+		return 0
+	}
+	return ep.inFlight.Load()
+}
+
 func (g *group) reconcileEndpoints(observed map[string]endpoint) {
 	g.mtx.Lock()
 	for name, observedEp := range observed {
 		if currentEp, ok := g.endpoints[name]; ok {
 			currentEp.adapters = observedEp.adapters
+			currentEp.zone = observedEp.zone
+			currentEp.weight = observedEp.weight
+			currentEp.draining = observedEp.draining
 			g.endpoints[name] = currentEp
 		} else {
 			g.endpoints[name] = endpoint{
+				name:     name,
 				inFlight: &atomic.Int64{},
+				health:   &endpointHealth{},
+				engine:   &engineState{},
 				address:  observedEp.address,
+				zone:     observedEp.zone,
+				weight:   observedEp.weight,
+				draining: observedEp.draining,
 				adapters: observedEp.adapters,
 			}
 			g.chwblAddEndpoint(name)
@@ -128,6 +409,16 @@ func (g *group) reconcileEndpoints(observed map[string]endpoint) {
 			delete(g.endpoints, name)
 		}
 	}
+	g.totalWeight = 0
+	for name, ep := range g.endpoints {
+		if ep.weight <= 0 {
+			ep.weight = 1
+			g.endpoints[name] = ep
+		}
+		if !ep.draining {
+			g.totalWeight += ep.weight
+		}
+	}
 	g.mtx.Unlock()
 
 	// notify waiting requests
@@ -138,13 +429,29 @@ func (g *group) reconcileEndpoints(observed map[string]endpoint) {
 
 func (g *group) broadcastEndpoints() {
 	g.bmtx.Lock()
-	defer g.bmtx.Unlock()
-
 	close(g.bcast)
 	g.bcast = make(chan struct{})
+	g.bmtx.Unlock()
+
+	g.queue.wakeHead()
 }
 
 func (g *group) addInFlight(endpointInFlight *atomic.Int64, add int64) int64 {
 	g.totalInFlight.Add(add)
 	return endpointInFlight.Add(add)
 }
+
+// tryAddInFlight adds an in-flight request to the endpoint unless
+// the endpoint already has the given number of in-flight requests.
+func (g *group) tryAddInFlight(endpointInFlight *atomic.Int64, limit int64) bool {
+	for {
+		n := endpointInFlight.Load()
+		if n >= limit {
+			return false
+		}
+		if endpointInFlight.CompareAndSwap(n, n+1) {
+			g.totalInFlight.Add(1)
+			return true
+		}
+	}
+}
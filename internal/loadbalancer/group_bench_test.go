@@ -2,19 +2,26 @@ package loadbalancer
 
 import (
 	"context"
+	"fmt"
+	"math/rand/v2"
 	"testing"
 
 	v1 "github.com/kubeai-project/kubeai/api/k8s/v1"
 	"github.com/kubeai-project/kubeai/internal/apiutils"
+	"github.com/kubeai-project/kubeai/internal/config"
+	"github.com/kubeai-project/kubeai/internal/metrics/metricstest"
 )
 
 func BenchmarkEndpointGroup(b *testing.B) {
-	e := newEndpointGroup(v1.LoadBalancing{PrefixHash: v1.PrefixHash{Replication: 100}})
+	metricstest.Init(b)
+
+	e := newEndpointGroup(v1.LoadBalancing{PrefixHash: v1.PrefixHash{Replication: 100}}, config.RequestQueue{}, config.OutlierDetection{})
 	e.reconcileEndpoints(map[string]endpoint{"pod1": {address: "10.0.0.1:8000"}})
+	req := &apiutils.Request{LoadBalancing: v1.LoadBalancing{Strategy: v1.LeastLoadStrategy}}
 	b.ResetTimer()
 	b.RunParallel(func(pb *testing.PB) {
 		for pb.Next() {
-			_, f, err := e.getBestAddr(context.Background(), &apiutils.Request{}, false)
+			_, f, err := e.getBestAddr(context.Background(), req, false)
 			if err != nil {
 				b.Fatal(err)
 			}
@@ -22,3 +29,111 @@ func BenchmarkEndpointGroup(b *testing.B) {
 		}
 	})
 }
+
+func BenchmarkStrategy(b *testing.B) {
+	metricstest.Init(b)
+
+	endpoints := map[string]endpoint{}
+	for i := 0; i < 16; i++ {
+		endpoints[fmt.Sprintf("pod%d", i)] = endpoint{address: fmt.Sprintf("10.0.0.%d:8000", i)}
+	}
+	for _, strategy := range []v1.LoadBalancingStrategy{v1.LeastLoadStrategy, v1.PowerOfTwoStrategy} {
+		b.Run(string(strategy), func(b *testing.B) {
+			e := newEndpointGroup(v1.LoadBalancing{}, config.RequestQueue{}, config.OutlierDetection{})
+			e.reconcileEndpoints(endpoints)
+			req := &apiutils.Request{LoadBalancing: v1.LoadBalancing{Strategy: strategy}}
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					_, f, err := e.getBestAddr(context.Background(), req, false)
+					if err != nil {
+						b.Fatal(err)
+					}
+					f()
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkLoadSpread simulates several KubeAI replicas (proxies) that balance
+// requests across the same endpoints, each only knowing about its own in-flight
+// requests. Requests arrive at the proxies in bursts and take a random number of
+// steps to complete. The reported "max/mean" metric is the average ratio of the
+// highest endpoint load to the mean endpoint load (1 is a perfect spread).
+func BenchmarkLoadSpread(b *testing.B) {
+	metricstest.Init(b)
+
+	const (
+		numEndpoints = 16
+		// burst is the number of consecutive requests that arrive at the same proxy.
+		burst = 8
+		// maxDuration is the maximum number of steps that a request takes.
+		maxDuration = 256
+	)
+	endpoints := map[string]endpoint{}
+	for i := 0; i < numEndpoints; i++ {
+		endpoints[fmt.Sprintf("pod%d", i)] = endpoint{address: fmt.Sprintf("10.0.0.%d:8000", i)}
+	}
+
+	for _, strategy := range []v1.LoadBalancingStrategy{v1.LeastLoadStrategy, v1.PowerOfTwoStrategy} {
+		for _, numProxies := range []int{1, 4, 16} {
+			b.Run(fmt.Sprintf("%s/proxies=%d", strategy, numProxies), func(b *testing.B) {
+				proxies := make([]*group, numProxies)
+				for i := range proxies {
+					proxies[i] = newEndpointGroup(v1.LoadBalancing{}, config.RequestQueue{}, config.OutlierDetection{})
+					proxies[i].reconcileEndpoints(endpoints)
+				}
+				req := &apiutils.Request{LoadBalancing: v1.LoadBalancing{Strategy: strategy}}
+
+				type activeRequest struct {
+					addr      string
+					done      func()
+					remaining int
+				}
+				var (
+					active []activeRequest
+					// load is the total number of requests per endpoint (across all proxies).
+					load      = map[string]int{}
+					imbalance float64
+				)
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					proxy := proxies[(i/burst)%numProxies]
+					addr, done, err := proxy.getBestAddr(context.Background(), req, false)
+					if err != nil {
+						b.Fatal(err)
+					}
+					load[addr]++
+					active = append(active, activeRequest{addr: addr, done: done, remaining: 1 + rand.IntN(maxDuration)})
+
+					// Advance all requests by one step.
+					n := 0
+					for _, r := range active {
+						r.remaining--
+						if r.remaining == 0 {
+							r.done()
+							load[r.addr]--
+							continue
+						}
+						active[n] = r
+						n++
+					}
+					active = active[:n]
+
+					var maxLoad int
+					for _, l := range load {
+						maxLoad = max(maxLoad, l)
+					}
+					if len(active) > 0 {
+						imbalance += float64(maxLoad) / (float64(len(active)) / numEndpoints)
+					} else {
+						imbalance++
+					}
+				}
+				b.ReportMetric(imbalance/float64(b.N), "max/mean")
+			})
+		}
+	}
+}
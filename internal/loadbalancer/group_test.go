@@ -5,9 +5,12 @@ import (
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	v1 "github.com/kubeai-project/kubeai/api/k8s/v1"
 	"github.com/kubeai-project/kubeai/internal/apiutils"
+	"github.com/kubeai-project/kubeai/internal/config"
+	"github.com/kubeai-project/kubeai/internal/metrics/metricstest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -15,6 +18,8 @@ import (
 )
 
 func TestConcurrentAccess(t *testing.T) {
+	metricstest.Init(t)
+
 	const (
 		myModel = "myModel"
 		myAddr  = "10.0.0.1:8000"
@@ -46,7 +51,7 @@ func TestConcurrentAccess(t *testing.T) {
 		}
 		t.Run(name, func(t *testing.T) {
 			// setup endpoint with one endpoint so that requests are not waiting
-			group := newEndpointGroup(v1.LoadBalancing{PrefixHash: v1.PrefixHash{Replication: 100}})
+			group := newEndpointGroup(v1.LoadBalancing{PrefixHash: v1.PrefixHash{Replication: 100}}, config.RequestQueue{}, config.OutlierDetection{})
 			group.reconcileEndpoints(
 				map[string]endpoint{myModel: {address: myAddr}},
 			)
@@ -77,6 +82,8 @@ func TestConcurrentAccess(t *testing.T) {
 }
 
 func TestBlockAndWaitForEndpoints(t *testing.T) {
+	metricstest.Init(t)
+
 	var completed atomic.Int32
 	var startWg, doneWg sync.WaitGroup
 	startTogether := func(n int, f func()) {
@@ -92,7 +99,7 @@ func TestBlockAndWaitForEndpoints(t *testing.T) {
 			}()
 		}
 	}
-	group := newEndpointGroup(v1.LoadBalancing{PrefixHash: v1.PrefixHash{Replication: 100}})
+	group := newEndpointGroup(v1.LoadBalancing{PrefixHash: v1.PrefixHash{Replication: 100}}, config.RequestQueue{}, config.OutlierDetection{})
 	ctx := context.TODO()
 	startTogether(100, func() {
 		group.getBestAddr(ctx, &apiutils.Request{}, false)
@@ -109,6 +116,8 @@ func TestBlockAndWaitForEndpoints(t *testing.T) {
 }
 
 func TestAbortOnCtxCancel(t *testing.T) {
+	metricstest.Init(t)
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	var startWg, doneWg sync.WaitGroup
@@ -116,7 +125,7 @@ func TestAbortOnCtxCancel(t *testing.T) {
 	doneWg.Add(1)
 	go func(t *testing.T) {
 		startWg.Wait()
-		endpoint := newEndpointGroup(v1.LoadBalancing{PrefixHash: v1.PrefixHash{Replication: 100}})
+		endpoint := newEndpointGroup(v1.LoadBalancing{PrefixHash: v1.PrefixHash{Replication: 100}}, config.RequestQueue{}, config.OutlierDetection{})
 		_, f, err := endpoint.getBestAddr(ctx, &apiutils.Request{}, false)
 		defer f()
 		require.Error(t, err)
@@ -127,3 +136,402 @@ func TestAbortOnCtxCancel(t *testing.T) {
 
 	doneWg.Wait()
 }
+
+func TestQueueBackpressure(t *testing.T) {
+	metricstest.Init(t)
+
+	const myModel = "my-model"
+	req := func(priority int32) *apiutils.Request {
+		return &apiutils.Request{
+			Model:         myModel,
+			Priority:      priority,
+			LoadBalancing: v1.LoadBalancing{Strategy: v1.LeastLoadStrategy},
+		}
+	}
+
+	t.Run("max wait exceeded", func(t *testing.T) {
+		g := newEndpointGroup(v1.LoadBalancing{}, config.RequestQueue{
+			MaxWait: config.Duration{Duration: 10 * time.Millisecond},
+		}, config.OutlierDetection{})
+		_, _, err := g.getBestAddr(context.Background(), req(0), false)
+		require.ErrorIs(t, err, ErrQueueWaitExceeded)
+		require.Equal(t, 0, g.queue.len())
+	})
+
+	t.Run("full queue sheds lowest priority", func(t *testing.T) {
+		g := newEndpointGroup(v1.LoadBalancing{}, config.RequestQueue{MaxDepth: 2}, config.OutlierDetection{})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		results := map[string]chan error{}
+		await := func(name string, priority int32) {
+			results[name] = make(chan error, 1)
+			go func() {
+				_, done, err := g.getBestAddr(ctx, req(priority), false)
+				if err == nil {
+					done()
+				}
+				results[name] <- err
+			}()
+		}
+		requireQueueLen := func(n int) {
+			require.Eventually(t, func() bool { return g.queue.len() == n }, time.Second, time.Millisecond)
+		}
+
+		await("low", 0)
+		requireQueueLen(1)
+		await("high", 10)
+		requireQueueLen(2)
+
+		// Not higher than any waiting request.
+		_, _, err := g.getBestAddr(ctx, req(0), false)
+		require.ErrorIs(t, err, ErrQueueFull)
+
+		// Displaces the low priority request.
+		await("higher", 20)
+		require.ErrorIs(t, <-results["low"], ErrQueueFull)
+		requireQueueLen(2)
+
+		g.reconcileEndpoints(map[string]endpoint{"pod1": {address: "10.0.0.1:8000"}})
+		require.NoError(t, <-results["high"])
+		require.NoError(t, <-results["higher"])
+		require.Equal(t, 0, g.queue.len())
+	})
+}
+
+func TestQueuePriorityOrder(t *testing.T) {
+	metricstest.Init(t)
+
+	g := newEndpointGroup(v1.LoadBalancing{}, config.RequestQueue{}, config.OutlierDetection{})
+	g.reconcileEndpoints(map[string]endpoint{"pod1": {address: "10.0.0.1:8000"}})
+	req := func(priority int32) *apiutils.Request {
+		return &apiutils.Request{
+			Priority: priority,
+			LoadBalancing: v1.LoadBalancing{
+				Strategy:                  v1.LeastLoadStrategy,
+				MaxConcurrencyPerEndpoint: 1,
+			},
+		}
+	}
+	_, done, err := g.getBestAddr(context.Background(), req(0), false)
+	require.NoError(t, err)
+
+	type served struct {
+		name string
+		done func()
+	}
+	results := make(chan served)
+	for i, r := range []struct {
+		name     string
+		priority int32
+	}{{"low", 0}, {"high", 10}, {"mid", 5}} {
+		go func() {
+			_, done, err := g.getBestAddr(context.Background(), req(r.priority), false)
+			assert.NoError(t, err)
+			results <- served{name: r.name, done: done}
+		}()
+		require.Eventually(t, func() bool { return g.queue.len() == i+1 }, time.Second, time.Millisecond)
+	}
+
+	// Every completed request frees the slot for the waiting request
+	// with the highest priority.
+	var order []string
+	for range 3 {
+		done()
+		s := <-results
+		order = append(order, s.name)
+		done = s.done
+	}
+	done()
+	require.Equal(t, []string{"high", "mid", "low"}, order)
+}
+
+func TestPowerOfTwoStrategy(t *testing.T) {
+	metricstest.Init(t)
+
+	g := newEndpointGroup(v1.LoadBalancing{}, config.RequestQueue{}, config.OutlierDetection{})
+	g.reconcileEndpoints(map[string]endpoint{
+		"pod1": {address: "10.0.0.1:8000"},
+		"pod2": {address: "10.0.0.2:8000"},
+		"pod3": {address: "10.0.0.3:8000"},
+	})
+	g.endpoints["pod1"].inFlight.Store(1)
+	g.endpoints["pod2"].inFlight.Store(5)
+	g.endpoints["pod3"].inFlight.Store(1)
+
+	req := &apiutils.Request{LoadBalancing: v1.LoadBalancing{Strategy: v1.PowerOfTwoStrategy}}
+	selected := map[string]int{}
+	for i := 0; i < 100; i++ {
+		addr, done, err := g.getBestAddr(context.Background(), req, false)
+		require.NoError(t, err)
+		done()
+		selected[addr]++
+	}
+	require.Zero(t, selected["10.0.0.2:8000"], "the most loaded endpoint should never win a comparison")
+	require.NotZero(t, selected["10.0.0.1:8000"])
+	require.NotZero(t, selected["10.0.0.3:8000"])
+}
+
+func TestSessionAffinity(t *testing.T) {
+	metricstest.Init(t)
+
+	g := newEndpointGroup(v1.LoadBalancing{PrefixHash: v1.PrefixHash{Replication: 256}}, config.RequestQueue{}, config.OutlierDetection{})
+	g.reconcileEndpoints(map[string]endpoint{
+		"pod1": {address: "10.0.0.1:8000"},
+		"pod2": {address: "10.0.0.2:8000"},
+		"pod3": {address: "10.0.0.3:8000"},
+	})
+	req := func(session, prefix string) *apiutils.Request {
+		return &apiutils.Request{
+			SessionID: session,
+			Prefix:    prefix,
+			LoadBalancing: v1.LoadBalancing{
+				Strategy:   v1.LeastLoadStrategy,
+				PrefixHash: v1.PrefixHash{MeanLoadPercentage: 125},
+			},
+		}
+	}
+	getAddr := func(r *apiutils.Request) (string, func()) {
+		addr, done, err := g.getBestAddr(context.Background(), r, false)
+		require.NoError(t, err)
+		return addr, done
+	}
+
+	for _, session := range []string{"a", "b", "c", "d"} {
+		first, done := getAddr(req(session, "first turn"))
+		done()
+		for i := 0; i < 10; i++ {
+			addr, done := getAddr(req(session, rand.String(8)))
+			done()
+			require.Equal(t, first, addr, "session %q should stick to its endpoint", session)
+		}
+	}
+
+	// The endpoint of a session is not used if it is overloaded.
+	first, done := getAddr(req("a", ""))
+	done()
+	for _, ep := range g.endpoints {
+		if ep.address == first {
+			g.addInFlight(ep.inFlight, 10)
+		}
+	}
+	addr, done := getAddr(req("a", ""))
+	done()
+	require.NotEqual(t, first, addr)
+}
+
+func TestZoneAffinity(t *testing.T) {
+	metricstest.Init(t)
+
+	g := newEndpointGroup(v1.LoadBalancing{}, config.RequestQueue{}, config.OutlierDetection{})
+	g.localZone = func() string { return "zone-a" }
+	g.reconcileEndpoints(map[string]endpoint{
+		"pod1": {address: "10.0.0.1:8000", zone: "zone-a"},
+		"pod2": {address: "10.0.0.2:8000", zone: "zone-b"},
+		"pod3": {address: "10.0.0.3:8000", zone: "zone-b"},
+	})
+	req := &apiutils.Request{
+		LoadBalancing: v1.LoadBalancing{
+			Strategy:     v1.LeastLoadStrategy,
+			ZoneAffinity: v1.ZoneAffinity{Enabled: true, MaxInFlightRequests: 2},
+		},
+	}
+	getAddr := func() string {
+		addr, _, err := g.getBestAddr(context.Background(), req, false)
+		require.NoError(t, err)
+		return addr
+	}
+
+	require.Equal(t, "10.0.0.1:8000", getAddr())
+	require.Equal(t, "10.0.0.1:8000", getAddr(), "same-zone endpoint should be preferred over idle endpoints in other zones")
+	require.NotEqual(t, "10.0.0.1:8000", getAddr(), "busy same-zone endpoint should spill over")
+
+	g.localZone = func() string { return "" }
+	require.NotEqual(t, "10.0.0.1:8000", getAddr(), "unknown local zone should not restrict endpoints")
+}
+
+func TestEndpointWeights(t *testing.T) {
+	metricstest.Init(t)
+
+	g := newEndpointGroup(v1.LoadBalancing{PrefixHash: v1.PrefixHash{Replication: 256}}, config.RequestQueue{}, config.OutlierDetection{})
+	g.reconcileEndpoints(map[string]endpoint{
+		"pod1": {address: "10.0.0.1:8000", weight: 1},
+		"pod2": {address: "10.0.0.2:8000", weight: 3},
+		"pod3": {address: "10.0.0.3:8000"},
+	})
+	require.Equal(t, 1.0, g.endpoints["pod3"].weight, "weight should default to 1")
+	require.Equal(t, 5.0, g.totalWeight)
+
+	counts := map[string]int{}
+	for i := 0; i < 50; i++ {
+		addr, _, err := g.getBestAddr(context.Background(), &apiutils.Request{
+			LoadBalancing: v1.LoadBalancing{Strategy: v1.LeastLoadStrategy},
+		}, false)
+		require.NoError(t, err)
+		counts[addr]++
+	}
+	require.Equal(t, map[string]int{"10.0.0.1:8000": 10, "10.0.0.2:8000": 30, "10.0.0.3:8000": 10}, counts)
+
+	// Threshold of the bounded load scales with the weight.
+	require.True(t, chwblLoadOK(12, 39, 3, 5, 1.25))
+	require.False(t, chwblLoadOK(12, 39, 1, 5, 1.25))
+}
+
+func TestMaxConcurrencyPerEndpoint(t *testing.T) {
+	metricstest.Init(t)
+
+	g := newEndpointGroup(v1.LoadBalancing{PrefixHash: v1.PrefixHash{Replication: 256}}, config.RequestQueue{}, config.OutlierDetection{})
+	g.reconcileEndpoints(map[string]endpoint{
+		"pod1": {address: "10.0.0.1:8000"},
+		"pod2": {address: "10.0.0.2:8000", weight: 2},
+	})
+
+	for _, strategy := range []v1.LoadBalancingStrategy{v1.LeastLoadStrategy, v1.PrefixHashStrategy, v1.PowerOfTwoStrategy} {
+		t.Run(string(strategy), func(t *testing.T) {
+			req := &apiutils.Request{
+				Prefix: "same prefix",
+				LoadBalancing: v1.LoadBalancing{
+					Strategy:                  strategy,
+					PrefixHash:                v1.PrefixHash{MeanLoadPercentage: 125},
+					MaxConcurrencyPerEndpoint: 1,
+				},
+			}
+
+			counts := map[string]int{}
+			var pod1Done func()
+			for i := 0; i < 3; i++ {
+				addr, done, err := g.getBestAddr(context.Background(), req, false)
+				require.NoError(t, err)
+				counts[addr]++
+				if addr == "10.0.0.1:8000" {
+					pod1Done = done
+				} else {
+					defer done()
+				}
+			}
+			require.Equal(t, map[string]int{"10.0.0.1:8000": 1, "10.0.0.2:8000": 2}, counts, "limit should scale with weight")
+
+			result := make(chan string)
+			go func() {
+				addr, done, err := g.getBestAddr(context.Background(), req, false)
+				assert.NoError(t, err)
+				done()
+				result <- addr
+			}()
+			require.Eventually(t, func() bool { return g.queue.len() == 1 }, time.Second, time.Millisecond, "request should be queued")
+
+			pod1Done()
+			select {
+			case addr := <-result:
+				require.Equal(t, "10.0.0.1:8000", addr)
+			case <-time.After(time.Second):
+				t.Fatal("queued request was not woken up by a completed request")
+			}
+		})
+	}
+
+	t.Run("concurrent requests", func(t *testing.T) {
+		req := &apiutils.Request{
+			LoadBalancing: v1.LoadBalancing{
+				Strategy:                  v1.PowerOfTwoStrategy,
+				MaxConcurrencyPerEndpoint: 2,
+			},
+		}
+		var wg sync.WaitGroup
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, done, err := g.getBestAddr(context.Background(), req, false)
+				assert.NoError(t, err)
+				assert.LessOrEqual(t, g.endpoints["pod1"].inFlight.Load(), int64(2))
+				assert.LessOrEqual(t, g.endpoints["pod2"].inFlight.Load(), int64(4))
+				time.Sleep(time.Millisecond)
+				done()
+			}()
+		}
+		wg.Wait()
+		require.Equal(t, int64(0), g.totalInFlight.Load())
+	})
+}
+
+func TestDrainingEndpoints(t *testing.T) {
+	metricstest.Init(t)
+
+	g := newEndpointGroup(v1.LoadBalancing{PrefixHash: v1.PrefixHash{Replication: 10}}, config.RequestQueue{}, config.OutlierDetection{})
+	g.reconcileEndpoints(map[string]endpoint{
+		"pod1": {address: "10.0.0.1:8000"},
+	})
+	req := &apiutils.Request{LoadBalancing: v1.LoadBalancing{Strategy: v1.LeastLoadStrategy}}
+
+	// pod1 has a long running request when it starts draining
+	// (while pod2 is added).
+	addr, done, err := g.getBestAddr(context.Background(), req, false)
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.1:8000", addr)
+	g.reconcileEndpoints(map[string]endpoint{
+		"pod1": {address: "10.0.0.1:8000", draining: true},
+		"pod2": {address: "10.0.0.2:8000"},
+	})
+	require.Equal(t, []string{"10.0.0.2:8000"}, g.getAllAddrs())
+	require.Equal(t, 1.0, g.totalWeight)
+
+	for _, strategy := range []v1.LoadBalancingStrategy{v1.LeastLoadStrategy, v1.PrefixHashStrategy, v1.PowerOfTwoStrategy} {
+		for i := 0; i < 10; i++ {
+			addr, done, err := g.getBestAddr(context.Background(), &apiutils.Request{
+				Prefix:        string(rune('a' + i)),
+				LoadBalancing: v1.LoadBalancing{Strategy: strategy, PrefixHash: v1.PrefixHash{MeanLoadPercentage: 125}},
+			}, false)
+			require.NoError(t, err)
+			done()
+			require.Equal(t, "10.0.0.2:8000", addr, "draining endpoint selected with %s strategy", strategy)
+		}
+	}
+
+	require.Equal(t, int64(1), g.getInFlight("pod1"))
+	done()
+	require.Equal(t, int64(0), g.getInFlight("pod1"))
+	require.Equal(t, int64(0), g.getInFlight("does-not-exist"))
+}
+
+func TestAdapterNotLoaded(t *testing.T) {
+	metricstest.Init(t)
+	defer func(d time.Duration) { adapterLoadWait = d }(adapterLoadWait)
+	adapterLoadWait = 50 * time.Millisecond
+
+	g := newEndpointGroup(v1.LoadBalancing{}, config.RequestQueue{}, config.OutlierDetection{})
+	g.reconcileEndpoints(map[string]endpoint{
+		"pod1": {address: "10.0.0.1:8000", adapters: map[string]struct{}{"other": {}}},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Requests for adapters that are loaded eagerly keep waiting.
+	shortCtx, shortCancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer shortCancel()
+	_, _, err := g.getBestAddr(shortCtx, &apiutils.Request{
+		Adapter:       "my-adapter",
+		LoadBalancing: v1.LoadBalancing{Strategy: v1.LeastLoadStrategy},
+	}, false)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	req := &apiutils.Request{
+		Adapter:         "my-adapter",
+		AdapterOnDemand: true,
+		LoadBalancing:   v1.LoadBalancing{Strategy: v1.LeastLoadStrategy},
+	}
+	_, _, err = g.getBestAddr(ctx, req, false)
+	require.ErrorIs(t, err, ErrAdapterNotLoaded)
+
+	// Adapters that are loaded while the request waits are used.
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		g.reconcileEndpoints(map[string]endpoint{
+			"pod1": {address: "10.0.0.1:8000", adapters: map[string]struct{}{"my-adapter": {}}},
+		})
+	}()
+	addr, done, err := g.getBestAddr(ctx, req, false)
+	require.NoError(t, err)
+	done()
+	require.Equal(t, "10.0.0.1:8000", addr)
+}
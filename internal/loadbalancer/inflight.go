@@ -0,0 +1,59 @@
+package loadbalancer
+
+import (
+	"context"
+
+	"github.com/kubeai-project/kubeai/internal/metrics"
+)
+
+// ClusterInFlightCounter counts the requests that all KubeAI replicas have in
+// flight to an endpoint. Every replica only knows about the requests that its
+// own proxy sent, so the counter scrapes the in-flight metric of every replica.
+type ClusterInFlightCounter struct {
+	lb          *LoadBalancer
+	selfMetrics *SelfMetricsScraper
+}
+
+func NewClusterInFlightCounter(lb *LoadBalancer, selfMetrics *SelfMetricsScraper) *ClusterInFlightCounter {
+	return &ClusterInFlightCounter{
+		lb:          lb,
+		selfMetrics: selfMetrics,
+	}
+}
+
+// InFlightRequests returns the number of requests that all KubeAI replicas
+// have in flight to the given Pod ("<namespace>/<name>") of a model. If adapter
+// is not empty, only the requests for that adapter are counted.
+// If the addresses of the replicas are unknown, the requests of this replica
+// to the Pod are counted (including the requests of all adapters).
+// An error is returned if any replica could not be scraped, because the
+// requests of that replica would be missing from the count.
+func (c *ClusterInFlightCounter) InFlightRequests(ctx context.Context, model, pod, adapter string) (int64, error) {
+	if len(c.selfMetrics.Addrs()) == 0 {
+		return c.lb.InFlightRequests(model, pod), nil
+	}
+
+	results, err := c.selfMetrics.Scrape(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var sum float64
+	for _, metricFamilies := range results {
+		fam, ok := metricFamilies[metrics.OtelNameToPromName(metrics.EndpointRequestsInFlightMetricName)]
+		if !ok {
+			// The replica did not send any requests yet.
+			continue
+		}
+		for _, m := range fam.Metric {
+			if labelValue(m, metrics.OtelAttrToPromLabel(metrics.AttrRequestModel)) != model ||
+				labelValue(m, metrics.OtelAttrToPromLabel(metrics.AttrEndpoint)) != pod {
+				continue
+			}
+			if adapter != "" && labelValue(m, metrics.OtelAttrToPromLabel(metrics.AttrRequestAdapter)) != adapter {
+				continue
+			}
+			sum += m.GetGauge().GetValue()
+		}
+	}
+	return int64(sum), nil
+}
@@ -0,0 +1,71 @@
+package loadbalancer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	v1 "github.com/kubeai-project/kubeai/api/k8s/v1"
+	"github.com/stretchr/testify/require"
+)
+
+const testReplicaMetrics = `# HELP kubeai_endpoint_requests_inflight The number of requests that this KubeAI replica has in flight by endpoint, model and adapter (used to drain endpoints)
+# TYPE kubeai_endpoint_requests_inflight gauge
+kubeai_endpoint_requests_inflight{endpoint="default/pod1",request_adapter="",request_model="my-model"} 2
+kubeai_endpoint_requests_inflight{endpoint="default/pod1",request_adapter="a",request_model="my-model"} 1
+kubeai_endpoint_requests_inflight{endpoint="default/pod2",request_adapter="",request_model="my-model"} 4
+kubeai_endpoint_requests_inflight{endpoint="default/pod1",request_adapter="",request_model="other-model"} 8
+`
+
+func TestClusterInFlightCounter(t *testing.T) {
+	ctx := context.Background()
+	newReplica := func(body string) *httptest.Server {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "/metrics", r.URL.Path)
+			_, _ = w.Write([]byte(body))
+		}))
+		t.Cleanup(srv.Close)
+		return srv
+	}
+	replica1 := newReplica(testReplicaMetrics)
+	replica2 := newReplica(testReplicaMetrics)
+	// Replicas that did not send any requests do not report the metric.
+	replica3 := newReplica("")
+
+	selfMetrics := NewSelfMetricsScraper(&LoadBalancer{}, 0, []string{
+		replica1.Listener.Addr().String(),
+		replica2.Listener.Addr().String(),
+		replica3.Listener.Addr().String(),
+	})
+	c := NewClusterInFlightCounter(&LoadBalancer{}, selfMetrics)
+	n, err := c.InFlightRequests(ctx, "my-model", "default/pod1", "")
+	require.NoError(t, err)
+	require.Equal(t, int64(6), n)
+	n, err = c.InFlightRequests(ctx, "my-model", "default/pod1", "a")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+	n, err = c.InFlightRequests(ctx, "my-model", "default/pod3", "")
+	require.NoError(t, err)
+	require.Equal(t, int64(0), n)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	selfMetrics.fixedAddrs = append(selfMetrics.fixedAddrs, failing.Listener.Addr().String())
+	_, err = c.InFlightRequests(ctx, "my-model", "default/pod1", "")
+	require.Error(t, err, "requests of unreachable replicas must not be ignored")
+}
+
+func TestClusterInFlightCounterWithoutReplicaAddresses(t *testing.T) {
+	lb := &LoadBalancer{groups: map[string]*group{}}
+	g := lb.getOrCreateEndpointGroup("my-model", v1.LoadBalancing{})
+	g.reconcileEndpoints(map[string]endpoint{"default/pod1": {address: "10.0.0.1:8000"}})
+	g.endpoints["default/pod1"].inFlight.Store(3)
+
+	c := NewClusterInFlightCounter(lb, NewSelfMetricsScraper(lb, 8080, nil))
+	n, err := c.InFlightRequests(context.Background(), "my-model", "default/pod1", "a")
+	require.NoError(t, err)
+	require.Equal(t, int64(3), n, "the local count should be used")
+}
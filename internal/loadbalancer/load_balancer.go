@@ -4,29 +4,44 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	v1 "github.com/kubeai-project/kubeai/api/k8s/v1"
 	"github.com/kubeai-project/kubeai/internal/apiutils"
+	"github.com/kubeai-project/kubeai/internal/config"
 	"github.com/kubeai-project/kubeai/internal/k8sutils"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func New(mgr ctrl.Manager) (*LoadBalancer, error) {
+// New creates a LoadBalancer. The podName is the name of the Pod of the KubeAI
+// replica that it runs in (used to determine its zone).
+func New(mgr ctrl.Manager, podName string, queueCfg config.RequestQueue, outlierCfg config.OutlierDetection, scrapeCfg config.EngineMetricsScraping) (*LoadBalancer, error) {
 	r := &LoadBalancer{}
+	r.podName = podName
+	r.queueCfg = queueCfg
+	r.outlierCfg = outlierCfg
+	r.scrapeCfg = scrapeCfg
 	r.Client = mgr.GetClient()
+	r.recorder = mgr.GetEventRecorderFor("kubeai-loadbalancer")
 	r.groups = map[string]*group{}
 	r.ExcludePods = map[string]struct{}{}
 	if err := r.SetupWithManager(mgr); err != nil {
 		return nil, err
 	}
+	if err := mgr.Add(r); err != nil {
+		return nil, fmt.Errorf("adding engine metrics scraper: %w", err)
+	}
 	return r, nil
 }
 
@@ -40,6 +55,20 @@ type LoadBalancer struct {
 	selfIPsMtx sync.RWMutex
 	selfIPs    []string
 
+	// podName is the name of the Pod of this KubeAI replica.
+	podName     string
+	selfZoneMtx sync.RWMutex
+	selfZone    string
+
+	// queueCfg configures the queue of each endpoint group.
+	queueCfg config.RequestQueue
+	// outlierCfg configures the passive health checking of endpoints.
+	outlierCfg config.OutlierDetection
+	// scrapeCfg configures the scraping of engine metrics.
+	scrapeCfg config.EngineMetricsScraping
+	// recorder is optional.
+	recorder record.EventRecorder
+
 	ExcludePods map[string]struct{}
 }
 
@@ -87,6 +116,19 @@ func (r *LoadBalancer) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Re
 		return ctrl.Result{}, nil
 	}
 
+	var model v1.Model
+	if err := r.Client.Get(ctx, client.ObjectKey{Name: modelName, Namespace: pod.Namespace}, &model); err != nil {
+		if apierrors.IsNotFound(err) {
+			// Model must have been deleted.
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, fmt.Errorf("getting model %s: %w", modelName, err)
+	}
+	zoneAffinity := model.Spec.LoadBalancing.ZoneAffinity.Enabled
+	if zoneAffinity {
+		r.resolveSelfZone(ctx, pod.Namespace)
+	}
+
 	var podList corev1.PodList
 	if err := r.List(ctx, &podList, client.InNamespace(pod.Namespace), client.MatchingLabels{v1.PodModelLabel: modelName}); err != nil {
 		return ctrl.Result{}, fmt.Errorf("listing matching pods: %w", err)
@@ -100,6 +142,10 @@ func (r *LoadBalancer) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Re
 		if !k8sutils.PodIsReady(&pod) {
 			continue
 		}
+		if getPodAnnotation(pod, v1.ModelPodWarmingUpAnnotation) != "" {
+			// The Model controller removes the annotation once the warmup completed.
+			continue
+		}
 
 		// The Model controller should always set the port annotation in the Pods it creates
 		// to communicate the port that the given backend listens on.
@@ -120,21 +166,26 @@ func (r *LoadBalancer) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Re
 			continue
 		}
 
+		var zone string
+		if zoneAffinity {
+			zone = r.getNodeZone(ctx, pod.Spec.NodeName)
+		}
+
 		observedEndpoints[pod.Namespace+"/"+pod.Name] = endpoint{
 			address:  ip + ":" + port,
+			zone:     zone,
+			weight:   getEndpointWeight(pod),
+			draining: getPodAnnotation(pod, v1.ModelPodDrainingAnnotation) != "",
 			adapters: getEndpointAdapters(pod),
 		}
 	}
 
-	var model v1.Model
-	if err := r.Client.Get(ctx, client.ObjectKey{Name: modelName, Namespace: pod.Namespace}, &model); err != nil {
-		if apierrors.IsNotFound(err) {
-			// Model must have been deleted.
-			return ctrl.Result{}, nil
-		}
-		return ctrl.Result{}, fmt.Errorf("getting model %s: %w", modelName, err)
+	g := r.getOrCreateEndpointGroup(modelName, model.Spec.LoadBalancing)
+	if model.Spec.LoadBalancing.Strategy == v1.EngineMetricsStrategy {
+		// Start scraping before the first request arrives.
+		g.engineMetricsUsed.Store(true)
 	}
-	r.getOrCreateEndpointGroup(modelName, model.Spec.LoadBalancing).reconcileEndpoints(observedEndpoints)
+	g.reconcileEndpoints(observedEndpoints)
 
 	return ctrl.Result{}, nil
 }
@@ -151,6 +202,21 @@ func getEndpointAdapters(pod corev1.Pod) map[string]struct{} {
 	return adapters
 }
 
+// getEndpointWeight returns the relative serving capacity of a Pod
+// (defaults to 1).
+func getEndpointWeight(pod corev1.Pod) float64 {
+	val := getPodAnnotation(pod, v1.ModelPodWeightAnnotation)
+	if val == "" {
+		return 1
+	}
+	w, err := strconv.ParseFloat(val, 64)
+	if err != nil || w <= 0 {
+		log.Printf("ERROR: Invalid weight annotation %q=%q for pod %s, using 1", v1.ModelPodWeightAnnotation, val, pod.Name)
+		return 1
+	}
+	return w
+}
+
 func getPodAnnotation(pod corev1.Pod, key string) string {
 	if ann := pod.GetAnnotations(); ann != nil {
 		return ann[key]
@@ -158,6 +224,44 @@ func getPodAnnotation(pod corev1.Pod, key string) string {
 	return ""
 }
 
+// getNodeZone returns the zone of the given node (empty if unknown).
+func (r *LoadBalancer) getNodeZone(ctx context.Context, nodeName string) string {
+	if nodeName == "" {
+		return ""
+	}
+	// Only the metadata of Nodes is needed (and cached).
+	var node metav1.PartialObjectMetadata
+	node.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Node"))
+	if err := r.Get(ctx, client.ObjectKey{Name: nodeName}, &node); err != nil {
+		log.Printf("Unable to get zone of node %q: %v", nodeName, err)
+		return ""
+	}
+	return node.GetLabels()[corev1.LabelTopologyZone]
+}
+
+// resolveSelfZone determines the zone of this KubeAI replica from the node
+// that its Pod is scheduled on (once).
+func (r *LoadBalancer) resolveSelfZone(ctx context.Context, namespace string) {
+	if r.podName == "" || r.getSelfZone() != "" {
+		return
+	}
+	var pod corev1.Pod
+	if err := r.Get(ctx, client.ObjectKey{Namespace: namespace, Name: r.podName}, &pod); err != nil {
+		log.Printf("Unable to get own pod %q to determine zone: %v", r.podName, err)
+		return
+	}
+	zone := r.getNodeZone(ctx, pod.Spec.NodeName)
+	r.selfZoneMtx.Lock()
+	r.selfZone = zone
+	r.selfZoneMtx.Unlock()
+}
+
+func (r *LoadBalancer) getSelfZone() string {
+	r.selfZoneMtx.RLock()
+	defer r.selfZoneMtx.RUnlock()
+	return r.selfZone
+}
+
 // getOrCreateEndpointGroup returns the endpoint group for the given model.
 // If the group does not exist, it is created.
 // This assumes that the existance of the model is already checked.
@@ -165,7 +269,9 @@ func (r *LoadBalancer) getOrCreateEndpointGroup(modelName string, lb v1.LoadBala
 	r.endpointsMtx.Lock()
 	g, ok := r.groups[modelName]
 	if !ok {
-		g = newEndpointGroup(lb)
+		g = newEndpointGroup(lb, r.queueCfg, r.outlierCfg)
+		g.onEject = r.recordEjection
+		g.localZone = r.getSelfZone
 		r.groups[modelName] = g
 	}
 	r.endpointsMtx.Unlock()
@@ -192,6 +298,47 @@ func (r *LoadBalancer) AwaitBestAddress(ctx context.Context, req *apiutils.Reque
 	return r.getOrCreateEndpointGroup(req.Model, req.LoadBalancing).getBestAddr(ctx, req, false)
 }
 
+// RecordOutcome reports the outcome of a request that was sent to the given
+// address of a model. Failing endpoints are temporarily ejected from load balancing.
+func (r *LoadBalancer) RecordOutcome(model, address string, failed bool, latency time.Duration) {
+	grp, ok := r.getEndpointGroup(model)
+	if !ok {
+		return
+	}
+	grp.recordOutcome(model, address, failed, latency)
+}
+
+// recordEjection records an Event on the Pod of an ejected endpoint.
+func (r *LoadBalancer) recordEjection(name, reason string, d time.Duration) {
+	if r.recorder == nil {
+		return
+	}
+	namespace, podName, ok := strings.Cut(name, "/")
+	if !ok {
+		return
+	}
+	// Avoid blocking the load balancer on the API server.
+	go func() {
+		var pod corev1.Pod
+		if err := r.Get(context.Background(), client.ObjectKey{Namespace: namespace, Name: podName}, &pod); err != nil {
+			log.Printf("Unable to get ejected pod %q: %v", name, err)
+			return
+		}
+		r.recorder.Eventf(&pod, corev1.EventTypeWarning, "EndpointEjected",
+			"Endpoint ejected from load balancing for %v: %v", d, reason)
+	}()
+}
+
+// InFlightRequests returns the number of requests that this KubeAI replica
+// has in flight to the given Pod ("<namespace>/<name>") of a model.
+func (r *LoadBalancer) InFlightRequests(model, pod string) int64 {
+	grp, ok := r.getEndpointGroup(model)
+	if !ok {
+		return 0
+	}
+	return grp.getInFlight(pod)
+}
+
 // GetAllHosts retrieves the list of all hosts for a given model.
 func (r *LoadBalancer) GetAllAddresses(model string) []string {
 	grp, ok := r.getEndpointGroup(model)
@@ -14,6 +14,13 @@ import (
 	"github.com/kubeai-project/kubeai/internal/metrics/metricstest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
 func TestAwaitBestHostBehavior(t *testing.T) {
@@ -39,6 +46,8 @@ func TestAwaitBestHostBehavior(t *testing.T) {
 			strategies: []v1.LoadBalancingStrategy{
 				v1.LeastLoadStrategy,
 				v1.PrefixHashStrategy,
+				v1.PowerOfTwoStrategy,
+				v1.EngineMetricsStrategy,
 			},
 			expAddr: myAddrWithoutAdapter,
 			endpoints: map[string]endpoint{
@@ -61,6 +70,8 @@ func TestAwaitBestHostBehavior(t *testing.T) {
 			strategies: []v1.LoadBalancingStrategy{
 				v1.LeastLoadStrategy,
 				v1.PrefixHashStrategy,
+				v1.PowerOfTwoStrategy,
+				v1.EngineMetricsStrategy,
 			},
 			expAddr: myAddrWithAdapter,
 		},
@@ -72,6 +83,8 @@ func TestAwaitBestHostBehavior(t *testing.T) {
 			strategies: []v1.LoadBalancingStrategy{
 				v1.LeastLoadStrategy,
 				v1.PrefixHashStrategy,
+				v1.PowerOfTwoStrategy,
+				v1.EngineMetricsStrategy,
 			},
 			expErr: context.DeadlineExceeded,
 		},
@@ -84,6 +97,8 @@ func TestAwaitBestHostBehavior(t *testing.T) {
 			strategies: []v1.LoadBalancingStrategy{
 				v1.LeastLoadStrategy,
 				v1.PrefixHashStrategy,
+				v1.PowerOfTwoStrategy,
+				v1.EngineMetricsStrategy,
 			},
 			expErr: context.DeadlineExceeded,
 		},
@@ -524,3 +539,99 @@ func percentDifference(a, b float64) float64 {
 	avg := (a + b) / 2.0
 	return (diff / avg) * 100.0
 }
+
+func TestReconcileZones(t *testing.T) {
+	metricstest.Init(t)
+
+	const ns = "kubeai"
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1.AddToScheme(scheme))
+
+	node := func(name, zone string) *corev1.Node {
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{corev1.LabelTopologyZone: zone}}}
+	}
+	pod := func(name, nodeName, ip string, labels map[string]string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   ns,
+				Labels:      labels,
+				Annotations: map[string]string{v1.ModelPodPortAnnotation: "8000"},
+			},
+			Spec: corev1.PodSpec{NodeName: nodeName},
+			Status: corev1.PodStatus{
+				PodIP:      ip,
+				Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+			},
+		}
+	}
+	modelLabels := map[string]string{v1.PodModelLabel: "my-model"}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		node("node-a", "zone-a"),
+		node("node-b", "zone-b"),
+		pod("kubeai-0", "node-b", "10.0.0.100", map[string]string{"app.kubernetes.io/name": "kubeai"}),
+		pod("model-1", "node-a", "10.0.0.1", modelLabels),
+		pod("model-2", "node-b", "10.0.0.2", modelLabels),
+		&v1.Model{
+			ObjectMeta: metav1.ObjectMeta{Name: "my-model", Namespace: ns},
+			Spec: v1.ModelSpec{LoadBalancing: v1.LoadBalancing{
+				Strategy:     v1.LeastLoadStrategy,
+				ZoneAffinity: v1.ZoneAffinity{Enabled: true, MaxInFlightRequests: 1},
+			}},
+		},
+	).Build()
+
+	lb := &LoadBalancer{Client: c, podName: "kubeai-0", groups: map[string]*group{}}
+	_, err := lb.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKey{Namespace: ns, Name: "model-1"}})
+	require.NoError(t, err)
+
+	require.Equal(t, "zone-b", lb.getSelfZone())
+	g, ok := lb.getEndpointGroup("my-model")
+	require.True(t, ok)
+	require.Equal(t, "zone-a", g.endpoints[ns+"/model-1"].zone)
+	require.Equal(t, "zone-b", g.endpoints[ns+"/model-2"].zone)
+	require.Equal(t, "zone-b", g.localZone())
+}
+
+func TestReconcilePodAnnotations(t *testing.T) {
+	metricstest.Init(t)
+
+	const ns = "kubeai"
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1.AddToScheme(scheme))
+
+	pod := func(name, ip string, annotations map[string]string) *corev1.Pod {
+		annotations[v1.ModelPodPortAnnotation] = "8000"
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   ns,
+				Labels:      map[string]string{v1.PodModelLabel: "my-model"},
+				Annotations: annotations,
+			},
+			Status: corev1.PodStatus{
+				PodIP:      ip,
+				Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+			},
+		}
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		pod("serving", "10.0.0.1", map[string]string{}),
+		pod("warming-up", "10.0.0.2", map[string]string{v1.ModelPodWarmingUpAnnotation: "true"}),
+		pod("draining", "10.0.0.3", map[string]string{v1.ModelPodDrainingAnnotation: "2024-01-01T00:00:00Z"}),
+		&v1.Model{ObjectMeta: metav1.ObjectMeta{Name: "my-model", Namespace: ns}},
+	).Build()
+
+	lb := &LoadBalancer{Client: c, groups: map[string]*group{}}
+	_, err := lb.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKey{Namespace: ns, Name: "serving"}})
+	require.NoError(t, err)
+
+	g, ok := lb.getEndpointGroup("my-model")
+	require.True(t, ok)
+	require.Len(t, g.endpoints, 2, "pods that are warming up should not be added")
+	require.False(t, g.endpoints[ns+"/serving"].draining)
+	require.True(t, g.endpoints[ns+"/draining"].draining)
+	require.Equal(t, []string{"10.0.0.1:8000"}, lb.GetAllAddresses("my-model"))
+}
@@ -0,0 +1,144 @@
+package loadbalancer
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/kubeai-project/kubeai/internal/metrics"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Reasons for ejecting an endpoint.
+const (
+	ejectionReasonConsecutiveFailures = "ConsecutiveFailures"
+	ejectionReasonFailureRate         = "FailureRate"
+)
+
+// endpointHealth tracks the outcomes of requests that were sent to an
+// endpoint (passive health checking). Endpoints that fail are ejected
+// from load balancing for a duration that grows with every consecutive
+// ejection.
+type endpointHealth struct {
+	// ejectedUntil is the time (in Unix nanoseconds) until which the
+	// endpoint is ejected. It is read without holding a lock while
+	// selecting an endpoint.
+	ejectedUntil atomic.Int64
+
+	// The following fields are guarded by group.hmtx.
+
+	consecutiveFailures int
+	intervalStart       time.Time
+	requests            int
+	failures            int
+	// ejections is the number of consecutive ejections (used for backoff).
+	ejections int
+}
+
+func (h *endpointHealth) ejected(now time.Time) bool {
+	return now.UnixNano() < h.ejectedUntil.Load()
+}
+
+// recordOutcome updates the health of the endpoint with the given address
+// with the outcome of a request and ejects the endpoint if it is an outlier.
+func (g *group) recordOutcome(model, address string, failed bool, latency time.Duration) {
+	cfg := g.outlierDetection
+	if !cfg.Enabled() {
+		return
+	}
+	if cfg.SlowResponseThreshold.Duration > 0 && latency > cfg.SlowResponseThreshold.Duration {
+		failed = true
+	}
+
+	g.mtx.RLock()
+	defer g.mtx.RUnlock()
+
+	var (
+		name  string
+		ep    endpoint
+		found bool
+	)
+	for n, e := range g.endpoints {
+		if e.address == address {
+			name, ep, found = n, e, true
+			break
+		}
+	}
+	if !found {
+		// The endpoint was removed in the meantime.
+		return
+	}
+
+	g.hmtx.Lock()
+	defer g.hmtx.Unlock()
+
+	now := g.now()
+	h := ep.health
+	if now.Sub(h.intervalStart) >= cfg.Interval.Duration {
+		h.intervalStart = now
+		h.requests = 0
+		h.failures = 0
+	}
+	h.requests++
+	if failed {
+		h.failures++
+		h.consecutiveFailures++
+	} else {
+		h.consecutiveFailures = 0
+		// Forget about past ejections once the endpoint has been
+		// healthy for a while.
+		if h.ejections > 0 && now.Sub(time.Unix(0, h.ejectedUntil.Load())) > cfg.MaxEjectionTime.Duration {
+			h.ejections = 0
+		}
+	}
+
+	if !failed || h.ejected(now) {
+		return
+	}
+
+	var reason string
+	switch {
+	case cfg.ConsecutiveFailures > 0 && h.consecutiveFailures >= cfg.ConsecutiveFailures:
+		reason = ejectionReasonConsecutiveFailures
+	case cfg.FailureRatePercent > 0 && h.requests >= cfg.MinRequests && h.failures*100 >= cfg.FailureRatePercent*h.requests:
+		reason = ejectionReasonFailureRate
+	default:
+		return
+	}
+
+	var ejected int
+	for _, e := range g.endpoints {
+		if e.health.ejected(now) {
+			ejected++
+		}
+	}
+	if (ejected+1)*100 > len(g.endpoints)*cfg.MaxEjectionPercent {
+		log.Printf("Not ejecting endpoint %q of model %q (%v): max ejection percent reached", name, model, reason)
+		return
+	}
+
+	h.ejections++
+	d := cfg.BaseEjectionTime.Duration << (h.ejections - 1)
+	if d <= 0 || d > cfg.MaxEjectionTime.Duration {
+		d = cfg.MaxEjectionTime.Duration
+	}
+	h.ejectedUntil.Store(now.Add(d).UnixNano())
+	h.consecutiveFailures = 0
+	h.requests = 0
+	h.failures = 0
+
+	log.Printf("Ejecting endpoint %q of model %q for %v: %v", name, model, d, reason)
+	metrics.EndpointEjections.Add(context.Background(), 1, metric.WithAttributeSet(attribute.NewSet(
+		metrics.AttrRequestModel.String(model),
+		metrics.AttrEndpoint.String(name),
+		metrics.AttrEjectionReason.String(reason),
+	)))
+	if g.onEject != nil {
+		g.onEject(name, reason, d)
+	}
+	// Requests might be waiting for the ejected endpoint (i.e. if it is the
+	// only endpoint with a given adapter).
+	time.AfterFunc(d, g.broadcastEndpoints)
+}
@@ -0,0 +1,151 @@
+package loadbalancer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "github.com/kubeai-project/kubeai/api/k8s/v1"
+	"github.com/kubeai-project/kubeai/internal/apiutils"
+	"github.com/kubeai-project/kubeai/internal/config"
+	"github.com/kubeai-project/kubeai/internal/metrics/metricstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutlierDetection(t *testing.T) {
+	const (
+		myModel = "my-model"
+		addr1   = "10.0.0.1:8000"
+		addr2   = "10.0.0.2:8000"
+		addr3   = "10.0.0.3:8000"
+	)
+	baseCfg := config.OutlierDetection{
+		ConsecutiveFailures: 3,
+		MinRequests:         10,
+		Interval:            config.Duration{Duration: time.Minute},
+		BaseEjectionTime:    config.Duration{Duration: 30 * time.Second},
+		MaxEjectionTime:     config.Duration{Duration: 90 * time.Second},
+		MaxEjectionPercent:  50,
+	}
+
+	setup := func(t *testing.T, cfg config.OutlierDetection) (*group, *time.Time, *[]string) {
+		metricstest.Init(t)
+		g := newEndpointGroup(v1.LoadBalancing{PrefixHash: v1.PrefixHash{Replication: 10}}, config.RequestQueue{}, cfg)
+		now := time.Now()
+		g.now = func() time.Time { return now }
+		var ejected []string
+		g.onEject = func(name, reason string, d time.Duration) {
+			ejected = append(ejected, name+":"+reason+":"+d.String())
+		}
+		g.reconcileEndpoints(map[string]endpoint{
+			"pod1": {address: addr1},
+			"pod2": {address: addr2},
+			"pod3": {address: addr3},
+		})
+		return g, &now, &ejected
+	}
+
+	// requireNotSelected asserts that the given address is never selected.
+	requireNotSelected := func(t *testing.T, g *group, addr string) {
+		t.Helper()
+		for _, strategy := range []v1.LoadBalancingStrategy{v1.LeastLoadStrategy, v1.PrefixHashStrategy} {
+			for i := 0; i < 20; i++ {
+				req := &apiutils.Request{
+					Model:  myModel,
+					Prefix: string(rune('a' + i)),
+					LoadBalancing: v1.LoadBalancing{
+						Strategy:   strategy,
+						PrefixHash: v1.PrefixHash{MeanLoadPercentage: 125},
+					},
+				}
+				got, done, err := g.getBestAddr(context.Background(), req, false)
+				require.NoError(t, err)
+				done()
+				require.NotEqual(t, addr, got, "ejected endpoint selected with %s strategy", strategy)
+			}
+		}
+	}
+
+	t.Run("consecutive failures with backoff", func(t *testing.T) {
+		g, now, ejected := setup(t, baseCfg)
+
+		g.recordOutcome(myModel, addr1, true, time.Second)
+		g.recordOutcome(myModel, addr1, true, time.Second)
+		g.recordOutcome(myModel, addr1, false, time.Second)
+		g.recordOutcome(myModel, addr1, true, time.Second)
+		g.recordOutcome(myModel, addr1, true, time.Second)
+		require.Empty(t, *ejected, "success should reset consecutive failures")
+
+		g.recordOutcome(myModel, addr1, true, time.Second)
+		require.Equal(t, []string{"pod1:ConsecutiveFailures:30s"}, *ejected)
+		requireNotSelected(t, g, addr1)
+
+		*now = now.Add(31 * time.Second)
+		require.False(t, g.endpoints["pod1"].health.ejected(*now))
+
+		for i := 0; i < 3; i++ {
+			g.recordOutcome(myModel, addr1, true, time.Second)
+		}
+		require.Equal(t, []string{"pod1:ConsecutiveFailures:30s", "pod1:ConsecutiveFailures:1m0s"}, *ejected, "ejection time should double")
+
+		*now = now.Add(61 * time.Second)
+		for i := 0; i < 3; i++ {
+			g.recordOutcome(myModel, addr1, true, time.Second)
+		}
+		require.Equal(t, "pod1:ConsecutiveFailures:1m30s", (*ejected)[2], "ejection time should be capped")
+
+		// Healthy for longer than the max ejection time.
+		*now = now.Add(4 * time.Minute)
+		g.recordOutcome(myModel, addr1, false, time.Second)
+		for i := 0; i < 3; i++ {
+			g.recordOutcome(myModel, addr1, true, time.Second)
+		}
+		require.Equal(t, "pod1:ConsecutiveFailures:30s", (*ejected)[3], "backoff should be reset")
+	})
+
+	t.Run("max ejection percent", func(t *testing.T) {
+		g, _, ejected := setup(t, baseCfg)
+
+		for _, addr := range []string{addr1, addr2} {
+			for i := 0; i < 3; i++ {
+				g.recordOutcome(myModel, addr, true, time.Second)
+			}
+		}
+		require.Equal(t, []string{"pod1:ConsecutiveFailures:30s"}, *ejected, "only 1 of 3 endpoints can be ejected")
+	})
+
+	t.Run("failure rate", func(t *testing.T) {
+		cfg := baseCfg
+		cfg.ConsecutiveFailures = 0
+		cfg.FailureRatePercent = 50
+		g, _, ejected := setup(t, cfg)
+
+		for i := 0; i < 9; i++ {
+			g.recordOutcome(myModel, addr2, i%2 == 0, time.Second)
+		}
+		require.Empty(t, *ejected, "too few requests")
+		g.recordOutcome(myModel, addr2, true, time.Second)
+		require.Equal(t, []string{"pod2:FailureRate:30s"}, *ejected)
+		requireNotSelected(t, g, addr2)
+	})
+
+	t.Run("slow responses", func(t *testing.T) {
+		cfg := baseCfg
+		cfg.SlowResponseThreshold = config.Duration{Duration: 10 * time.Second}
+		g, _, ejected := setup(t, cfg)
+
+		for i := 0; i < 3; i++ {
+			g.recordOutcome(myModel, addr3, false, time.Minute)
+		}
+		require.Equal(t, []string{"pod3:ConsecutiveFailures:30s"}, *ejected)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		g, _, ejected := setup(t, config.OutlierDetection{})
+
+		for i := 0; i < 100; i++ {
+			g.recordOutcome(myModel, addr1, true, time.Second)
+		}
+		require.Empty(t, *ejected)
+	})
+}
@@ -0,0 +1,136 @@
+package loadbalancer
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/kubeai-project/kubeai/internal/config"
+)
+
+var (
+	ErrQueueFull         = errors.New("request queue is full")
+	ErrQueueWaitExceeded = errors.New("max wait time in request queue exceeded")
+)
+
+// queue keeps track of the requests that are waiting for an endpoint of a group.
+// It bounds the number of waiting requests, shedding the ones with the lowest
+// priority first. When endpoints become available, waiters are woken one after
+// another in the order of the queue (see wakeHead and wakeNext) so that
+// requests with a higher priority pick an endpoint first.
+type queue struct {
+	maxDepth int
+	maxWait  time.Duration
+
+	mtx sync.Mutex
+	// waiters are sorted by descending priority (then by arrival).
+	waiters []*waiter
+}
+
+func newQueue(cfg config.RequestQueue) *queue {
+	return &queue{
+		maxDepth: cfg.MaxDepth,
+		maxWait:  cfg.MaxWait.Duration,
+	}
+}
+
+type waiter struct {
+	priority int32
+	// evicted is closed when the waiter was displaced by a request
+	// with a higher priority.
+	evicted chan struct{}
+	// wake is signalled when it is the turn of the waiter to look for
+	// an endpoint.
+	wake chan struct{}
+}
+
+func (w *waiter) signal() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+		// Already signalled.
+	}
+}
+
+// add adds a waiter to the queue. If the queue is full, the newest waiter with
+// a lower priority is evicted, otherwise ErrQueueFull is returned.
+func (q *queue) add(priority int32) (*waiter, error) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	if q.maxDepth > 0 && len(q.waiters) >= q.maxDepth {
+		last := q.waiters[len(q.waiters)-1]
+		if last.priority >= priority {
+			return nil, ErrQueueFull
+		}
+		close(last.evicted)
+		q.waiters = q.waiters[:len(q.waiters)-1]
+	}
+
+	w := &waiter{
+		priority: priority,
+		evicted:  make(chan struct{}),
+		wake:     make(chan struct{}, 1),
+	}
+	// Insert after all waiters with the same or a higher priority.
+	i := len(q.waiters)
+	for i > 0 && q.waiters[i-1].priority < priority {
+		i--
+	}
+	q.waiters = append(q.waiters, nil)
+	copy(q.waiters[i+1:], q.waiters[i:])
+	q.waiters[i] = w
+
+	return w, nil
+}
+
+// remove removes a waiter from the queue (if it was not already evicted).
+// If it was its turn, the turn is passed on to the next waiter.
+func (q *queue) remove(w *waiter) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	for i, qw := range q.waiters {
+		if qw == w {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			select {
+			case <-w.wake:
+				if i < len(q.waiters) {
+					q.waiters[i].signal()
+				}
+			default:
+			}
+			return
+		}
+	}
+}
+
+// wakeHead wakes the waiter with the highest priority.
+func (q *queue) wakeHead() {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	if len(q.waiters) > 0 {
+		q.waiters[0].signal()
+	}
+}
+
+// wakeNext passes the turn on to the waiter after w once w picked an
+// endpoint or found none.
+func (q *queue) wakeNext(w *waiter) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	for i, qw := range q.waiters {
+		if qw == w {
+			if i+1 < len(q.waiters) {
+				q.waiters[i+1].signal()
+			}
+			return
+		}
+	}
+}
+
+func (q *queue) len() int {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	return len(q.waiters)
+}
@@ -0,0 +1,116 @@
+package loadbalancer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	io_prometheus_client "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// metricsPath is the path that KubeAI and engines (vLLM) serve Prometheus metrics on.
+const metricsPath = "/metrics"
+
+// selfScrapeTimeout is the timeout for scraping the metrics of a KubeAI replica.
+const selfScrapeTimeout = 5 * time.Second
+
+// SelfMetricsScraper scrapes the Prometheus metrics of all KubeAI replicas.
+type SelfMetricsScraper struct {
+	lb          *LoadBalancer
+	metricsPort int
+	// fixedAddrs are the metrics addresses of the KubeAI replicas
+	// (overrides the addresses that are resolved from the load balancer).
+	fixedAddrs []string
+	httpClient *http.Client
+}
+
+func NewSelfMetricsScraper(lb *LoadBalancer, metricsPort int, fixedAddrs []string) *SelfMetricsScraper {
+	return &SelfMetricsScraper{
+		lb:          lb,
+		metricsPort: metricsPort,
+		fixedAddrs:  fixedAddrs,
+		httpClient:  &http.Client{Timeout: selfScrapeTimeout},
+	}
+}
+
+// Addrs returns the metrics addresses of the KubeAI replicas.
+func (s *SelfMetricsScraper) Addrs() []string {
+	if len(s.fixedAddrs) > 0 {
+		return s.fixedAddrs
+	}
+	var addrs []string
+	for _, ip := range s.lb.GetSelfIPs() {
+		addrs = append(addrs, fmt.Sprintf("%s:%d", ip, s.metricsPort))
+	}
+	return addrs
+}
+
+// Scrape concurrently scrapes the metrics of all KubeAI replicas and returns
+// the metric families by address. Replicas that could not be scraped are left
+// out of the result and reported in the returned error.
+func (s *SelfMetricsScraper) Scrape(ctx context.Context) (map[string]map[string]*io_prometheus_client.MetricFamily, error) {
+	var (
+		mtx     sync.Mutex
+		results = make(map[string]map[string]*io_prometheus_client.MetricFamily)
+		errs    error
+		wg      sync.WaitGroup
+	)
+	for _, addr := range s.Addrs() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			families, err := ScrapeMetrics(ctx, s.httpClient, addr)
+			mtx.Lock()
+			defer mtx.Unlock()
+			if err != nil {
+				errs = errors.Join(errs, fmt.Errorf("%s: %w", addr, err))
+				return
+			}
+			results[addr] = families
+		}()
+	}
+	wg.Wait()
+	return results, errs
+}
+
+// ScrapeMetrics scrapes the Prometheus metrics that are served at the given
+// address ("<ip>:<port>") and returns the metric families by name.
+func ScrapeMetrics(ctx context.Context, httpClient *http.Client, address string) (map[string]*io_prometheus_client.MetricFamily, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+address+metricsPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scrape metrics: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to scrape metrics: unexpected status code %d", resp.StatusCode)
+	}
+	return parseMetrics(resp.Body)
+}
+
+func parseMetrics(r io.Reader) (map[string]*io_prometheus_client.MetricFamily, error) {
+	// Use the expfmt library to parse the Prometheus metrics
+	parser := expfmt.TextParser{}
+	metricFamilies, err := parser.TextToMetricFamilies(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse metrics: %w", err)
+	}
+	return metricFamilies, nil
+}
+
+func labelValue(m *io_prometheus_client.Metric, name string) string {
+	for _, l := range m.Label {
+		if l.GetName() == name {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
@@ -20,6 +20,8 @@ import (
 	"k8s.io/utils/ptr"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
@@ -34,6 +36,7 @@ import (
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 
 	kubeaiv1 "github.com/kubeai-project/kubeai/api/k8s/v1"
+	"github.com/kubeai-project/kubeai/internal/apikeys"
 	"github.com/kubeai-project/kubeai/internal/leader"
 	"github.com/kubeai-project/kubeai/internal/loadbalancer"
 	"github.com/kubeai-project/kubeai/internal/messenger"
@@ -42,6 +45,7 @@ import (
 	"github.com/kubeai-project/kubeai/internal/modelcontroller"
 	"github.com/kubeai-project/kubeai/internal/modelproxy"
 	"github.com/kubeai-project/kubeai/internal/openaiserver"
+	"github.com/kubeai-project/kubeai/internal/ratelimit"
 	"github.com/kubeai-project/kubeai/internal/vllmclient"
 
 	// Pulling in these packages will register the gocloud implementations.
@@ -159,6 +163,12 @@ func Run(ctx context.Context, k8sCfg *rest.Config, cfg config.System) error {
 				// (this should also be enforced by Namespaced RBAC rules)
 				namespace: {},
 			},
+			ByObject: map[client.Object]cache.ByObject{
+				// Only Secrets that hold API keys are read through the cache.
+				&corev1.Secret{}: {
+					Label: labels.SelectorFromSet(labels.Set{apikeys.SecretLabel: "true"}),
+				},
+			},
 		},
 		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
 		// when the Manager ends. This requires the binary to immediately end when the
@@ -207,11 +217,18 @@ func Run(ctx context.Context, k8sCfg *rest.Config, cfg config.System) error {
 		cfg.LeaderElection.RetryPeriod.Duration,
 	)
 
-	loadBalancer, err := loadbalancer.New(mgr)
+	loadBalancer, err := loadbalancer.New(mgr, hostname, cfg.RequestQueue, cfg.OutlierDetection, cfg.EngineMetricsScraping)
 	if err != nil {
 		return fmt.Errorf("unable to setup model resolver: %w", err)
 	}
 
+	metricsPort, err := parsePortFromAddr(cfg.MetricsAddr)
+	if err != nil {
+		return fmt.Errorf("unable to parse metrics port: %w", err)
+	}
+
+	selfMetrics := loadbalancer.NewSelfMetricsScraper(loadBalancer, metricsPort, cfg.FixedSelfMetricAddrs)
+
 	modelReconciler := &modelcontroller.ModelReconciler{
 		Client:                  mgr.GetClient(),
 		RESTConfig:              mgr.GetConfig(),
@@ -226,6 +243,10 @@ func Run(ctx context.Context, k8sCfg *rest.Config, cfg config.System) error {
 		ModelServerPods:         cfg.ModelServerPods,
 		ModelLoaders:            cfg.ModelLoading,
 		ModelRollouts:           cfg.ModelRollouts,
+		ModelDraining:           cfg.ModelDraining,
+		InFlightCounter:         loadbalancer.NewClusterInFlightCounter(loadBalancer, selfMetrics),
+		Hostname:                hostname,
+		Recorder:                mgr.GetEventRecorderFor("kubeai-model-controller"),
 		VLLMClient: &vllmclient.Client{
 			HTTPClient: &http.Client{Timeout: 10 * time.Second},
 		},
@@ -242,12 +263,7 @@ func Run(ctx context.Context, k8sCfg *rest.Config, cfg config.System) error {
 		return fmt.Errorf("unable to set up ready check: %w", err)
 	}
 
-	modelClient := modelclient.NewModelClient(mgr.GetClient(), namespace)
-
-	metricsPort, err := parsePortFromAddr(cfg.MetricsAddr)
-	if err != nil {
-		return fmt.Errorf("unable to parse metrics port: %w", err)
-	}
+	modelClient := modelclient.NewModelClient(mgr.GetClient(), namespace, cfg.ResourceProfiles)
 
 	modelAutoscaler, err := modelautoscaler.New(
 		ctx,
@@ -255,17 +271,27 @@ func Run(ctx context.Context, k8sCfg *rest.Config, cfg config.System) error {
 		leaderElection,
 		modelClient,
 		loadBalancer,
+		mgr.GetEventRecorderFor("kubeai-autoscaler"),
 		cfg.ModelAutoscaling,
-		metricsPort,
+		cfg.ResourceProfiles,
+		selfMetrics,
 		types.NamespacedName{Name: cfg.ModelAutoscaling.StateConfigMapName, Namespace: namespace},
-		cfg.FixedSelfMetricAddrs,
 	)
 	if err != nil {
 		return fmt.Errorf("unable to create model autoscaler: %w", err)
 	}
 
-	modelProxy := modelproxy.NewHandler(modelClient, loadBalancer, 3, nil)
-	openaiHandler := openaiserver.NewHandler(mgr.GetClient(), modelProxy)
+	rateLimiter := ratelimit.NewLimiter(cfg.RateLimiting, loadBalancer)
+	modelProxy := modelproxy.NewHandler(modelClient, loadBalancer, 3, nil, cfg.ModelProxy, rateLimiter, modelReconciler)
+	var authenticator openaiserver.Authenticator
+	if cfg.APIKeys.Enabled {
+		apiKeys := apikeys.NewAuthenticator(mgr.GetClient(), namespace)
+		if err := apiKeys.SetupWithManager(mgr); err != nil {
+			return fmt.Errorf("unable to set up API key authenticator: %w", err)
+		}
+		authenticator = apiKeys
+	}
+	openaiHandler := openaiserver.NewHandler(mgr.GetClient(), modelProxy, authenticator)
 	mux := http.NewServeMux()
 	mux.Handle("/openai/", openaiHandler)
 	apiServer := &http.Server{
@@ -293,6 +319,7 @@ func Run(ctx context.Context, k8sCfg *rest.Config, cfg config.System) error {
 			cfg.Messaging.ErrorMaxBackoff.Duration,
 			modelClient,
 			loadBalancer,
+			modelReconciler,
 			httpClient,
 		)
 		if err != nil {
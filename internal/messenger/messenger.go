@@ -15,7 +15,9 @@ import (
 
 	v1 "github.com/kubeai-project/kubeai/api/k8s/v1"
 	"github.com/kubeai-project/kubeai/internal/apiutils"
+	"github.com/kubeai-project/kubeai/internal/loadbalancer"
 	"github.com/kubeai-project/kubeai/internal/metrics"
+	"github.com/kubeai-project/kubeai/internal/modelclient"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 	"gocloud.dev/pubsub"
@@ -24,6 +26,8 @@ import (
 type Messenger struct {
 	modelClient  ModelClient
 	loadBalancer LoadBalancer
+	// adapterLoader is optional.
+	adapterLoader AdapterLoader
 
 	HTTPC *http.Client
 
@@ -46,6 +50,7 @@ func NewMessenger(
 	errorMaxBackoff time.Duration,
 	modelClient ModelClient,
 	lb LoadBalancer,
+	adapterLoader AdapterLoader,
 	httpClient *http.Client,
 ) (*Messenger, error) {
 	requests, err := pubsub.OpenSubscription(ctx, requestsURL)
@@ -61,6 +66,7 @@ func NewMessenger(
 	return &Messenger{
 		modelClient:     modelClient,
 		loadBalancer:    lb,
+		adapterLoader:   adapterLoader,
 		HTTPC:           httpClient,
 		requestsURL:     requestsURL,
 		requests:        requests,
@@ -72,11 +78,18 @@ func NewMessenger(
 
 type ModelClient interface {
 	LookupModel(ctx context.Context, model, adapter string, selectors []string) (*v1.Model, error)
+	LookupModelAlias(ctx context.Context, name string, selectors []string) (*v1.ModelAlias, error)
 	ScaleAtLeastOneReplica(ctx context.Context, model string) error
 }
 
 type LoadBalancer interface {
 	AwaitBestAddress(ctx context.Context, req *apiutils.Request) (string, func(), error)
+	RecordOutcome(model, address string, failed bool, latency time.Duration)
+	GetAllAddresses(model string) []string
+}
+
+type AdapterLoader interface {
+	LoadAdapter(ctx context.Context, model, adapter string) error
 }
 
 func (m *Messenger) Start(ctx context.Context) error {
@@ -213,11 +226,32 @@ func (m *Messenger) handleRequest(ctx context.Context, msg *pubsub.Message) {
 	defer metrics.InferenceRequestsActive.Add(ctx, -1, metricAttrs)
 
 	// Ensure the backend is scaled to at least one Pod.
-	m.modelClient.ScaleAtLeastOneReplica(ctx, mr.Model)
+	var scheduled *modelclient.ScheduledToZeroError
+	if err := m.modelClient.ScaleAtLeastOneReplica(ctx, mr.Model); errors.As(err, &scheduled) &&
+		len(m.loadBalancer.GetAllAddresses(mr.Model)) == 0 {
+		m.sendResponse(mr, m.jsonError("%v", err), http.StatusServiceUnavailable)
+		return
+	}
+
+	if mr.Adapter != "" && m.adapterLoader != nil {
+		if err := m.adapterLoader.LoadAdapter(ctx, mr.Model, mr.Adapter); err != nil {
+			m.sendResponse(mr, m.jsonError("error loading adapter: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
 
 	log.Printf("Awaiting host for message %s", msg.LoggableID)
 
 	host, completeFunc, err := m.loadBalancer.AwaitBestAddress(ctx, mr.Request)
+	for errors.Is(err, loadbalancer.ErrAdapterNotLoaded) && m.adapterLoader != nil {
+		// The adapter was unloaded to make room for another adapter
+		// while the message was waiting.
+		if err := m.adapterLoader.LoadAdapter(ctx, mr.Model, mr.Adapter); err != nil {
+			m.sendResponse(mr, m.jsonError("error loading adapter: %v", err), http.StatusInternalServerError)
+			return
+		}
+		host, completeFunc, err = m.loadBalancer.AwaitBestAddress(ctx, mr.Request)
+	}
 	if err != nil {
 		m.sendResponse(mr, m.jsonError("error awaiting host for backend: %v", err), http.StatusBadGateway)
 		return
@@ -226,15 +260,41 @@ func (m *Messenger) handleRequest(ctx context.Context, msg *pubsub.Message) {
 
 	url := fmt.Sprintf("http://%s%s", host, mr.path)
 	log.Printf("Sending request to backend for message %s: %s", msg.LoggableID, url)
-	respPayload, respCode, err := m.sendBackendRequest(ctx, url, mr.Body)
+	respPayload, respCode, latency, err := m.sendBackendRequest(ctx, url, mr.Body)
+	if ctx.Err() == nil {
+		m.loadBalancer.RecordOutcome(mr.Model, host, err != nil || respCode >= 500, latency)
+	}
 	if err != nil {
 		m.sendResponse(mr, m.jsonError("error sending request to backend: %v", err), http.StatusBadGateway)
 		return
 	}
 
+	if respCode == http.StatusOK && apiutils.ReportsUsage(mr.path) {
+		m.recordUsage(ctx, mr, respPayload)
+	}
+
 	m.sendResponse(mr, respPayload, respCode)
 }
 
+func (m *Messenger) recordUsage(ctx context.Context, mr *msgRequest, payload []byte) {
+	usage, err := apiutils.ReadUsage(mr.path, bytes.NewReader(payload))
+	if err != nil {
+		log.Printf("Unable to read usage from response for message %s: %v", mr.msg.LoggableID, err)
+		return
+	}
+	if usage == nil {
+		return
+	}
+	metricAttrs := metric.WithAttributeSet(attribute.NewSet(
+		metrics.AttrRequestModel.String(mr.Model),
+		metrics.AttrRequestAdapter.String(mr.Adapter),
+		metrics.AttrRequestCaller.String(""),
+		metrics.AttrRequestType.String(metrics.AttrRequestTypeMessage),
+	))
+	metrics.InferenceTokensPrompt.Add(ctx, int64(usage.PromptTokens), metricAttrs)
+	metrics.InferenceTokensCompletion.Add(ctx, int64(usage.CompletionTokens), metricAttrs)
+}
+
 func (m *Messenger) Stop(ctx context.Context) error {
 	return m.requests.Shutdown(ctx)
 }
@@ -282,27 +342,32 @@ func (m *Messenger) parseMsgRequest(ctx context.Context, msg *pubsub.Message) (*
 	return req, nil
 }
 
-func (m *Messenger) sendBackendRequest(ctx context.Context, url string, body []byte) ([]byte, int, error) {
+// sendBackendRequest sends the request to the backend and returns the response
+// payload and status code along with the time to the response headers (which
+// is what the load balancer compares with the slow response threshold).
+func (m *Messenger) sendBackendRequest(ctx context.Context, url string, body []byte) ([]byte, int, time.Duration, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, 0, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
 
+	start := time.Now()
 	resp, err := m.HTTPC.Do(req)
+	latency := time.Since(start)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, latency, err
 	}
 	defer resp.Body.Close()
 
 	payload, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, latency, err
 	}
 
-	return payload, resp.StatusCode, nil
+	return payload, resp.StatusCode, latency, nil
 }
 
 func (m *Messenger) sendResponse(req *msgRequest, body []byte, statusCode int) {
@@ -16,6 +16,10 @@ const (
 var (
 	InferenceRequestsActiveMetricName               = "kubeai.inference.requests.active"
 	InferenceRequestsActive                         metric.Int64UpDownCounter
+	InferenceRequestsQueuedMetricName               = "kubeai.inference.requests.queued"
+	InferenceRequestsQueued                         metric.Int64UpDownCounter
+	InferenceRequestsQueueWaitMetricName            = "kubeai.inference.requests.queue.wait"
+	InferenceRequestsQueueWait                      metric.Float64Histogram
 	InferenceRequestsHashLookupIterationsMetricName = "kubeai.inference.requests.hash.lookup.iterations"
 	InferenceRequestsHashLookupIterations           metric.Int64Histogram
 	InferenceRequestsHashLookupInitialMetricName    = "kubeai.inference.requests.hash.lookup.initial"
@@ -26,17 +30,64 @@ var (
 	InferenceRequestsHashLookupDefault              metric.Int64Counter
 )
 
+// Metrics used to account for token usage:
+var (
+	InferenceTokensPromptMetricName     = "kubeai.inference.tokens.prompt"
+	InferenceTokensPrompt               metric.Int64Counter
+	InferenceTokensCompletionMetricName = "kubeai.inference.tokens.completion"
+	InferenceTokensCompletion           metric.Int64Counter
+)
+
+// Metrics used to track failures:
+var (
+	InferenceStreamsInterruptedMetricName = "kubeai.inference.streams.interrupted"
+	InferenceStreamsInterrupted           metric.Int64Counter
+	InferenceRequestsFallbackMetricName   = "kubeai.inference.requests.fallback"
+	InferenceRequestsFallback             metric.Int64Counter
+	EndpointEjectionsMetricName           = "kubeai.endpoint.ejections"
+	EndpointEjections                     metric.Int64Counter
+)
+
+// Metrics of the autoscaler:
+var (
+	ModelReplicasShortfallMetricName = "kubeai.model.replicas.shortfall"
+	ModelReplicasShortfall           metric.Int64Gauge
+	ModelReplicasDesiredMetricName   = "kubeai.model.replicas.desired"
+	ModelReplicasDesired             metric.Int64Gauge
+)
+
+// Metrics of model Pods:
+var (
+	EndpointWarmupDurationMetricName   = "kubeai.endpoint.warmup.duration"
+	EndpointWarmupDuration             metric.Float64Histogram
+	EndpointRequestsInFlightMetricName = "kubeai.endpoint.requests.inflight"
+	EndpointRequestsInFlight           metric.Int64UpDownCounter
+)
+
 // Attributes:
 var (
-	AttrRequestModel = attribute.Key("request.model")
-	AttrRequestType  = attribute.Key("request.type")
-	AttrEndpoint     = attribute.Key("endpoint")
+	AttrRequestModel    = attribute.Key("request.model")
+	AttrRequestAdapter  = attribute.Key("request.adapter")
+	AttrRequestType     = attribute.Key("request.type")
+	AttrRequestCaller   = attribute.Key("request.caller")
+	AttrEndpoint        = attribute.Key("endpoint")
+	AttrFallbackModel   = attribute.Key("fallback.model")
+	AttrFallbackReason  = attribute.Key("fallback.reason")
+	AttrEjectionReason  = attribute.Key("ejection.reason")
+	AttrAutoscalingMode = attribute.Key("autoscaling.mode")
 )
 
 // Attribute values:
 const (
 	AttrRequestTypeHTTP    = "http"
 	AttrRequestTypeMessage = "message"
+
+	// AttrFallbackReasonUnavailable is used when the requested model
+	// had no ready replicas.
+	AttrFallbackReasonUnavailable = "unavailable"
+	// AttrFallbackReasonErrors is used when requests to the requested
+	// model repeatedly failed.
+	AttrFallbackReasonErrors = "errors"
 )
 
 // Init sets up global metric variables.
@@ -48,6 +99,20 @@ func Init(meter metric.Meter) error {
 	if err != nil {
 		return fmt.Errorf("%s: %w", InferenceRequestsActiveMetricName, err)
 	}
+	InferenceRequestsQueued, err = meter.Int64UpDownCounter(InferenceRequestsQueuedMetricName,
+		metric.WithDescription("The number of requests waiting for an endpoint by model"),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", InferenceRequestsQueuedMetricName, err)
+	}
+	InferenceRequestsQueueWait, err = meter.Float64Histogram(InferenceRequestsQueueWaitMetricName,
+		metric.WithDescription("The time that requests waited for an endpoint by model"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 120, 300),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", InferenceRequestsQueueWaitMetricName, err)
+	}
 	InferenceRequestsHashLookupIterations, err = meter.Int64Histogram(InferenceRequestsHashLookupIterationsMetricName,
 		metric.WithDescription("The number of vnodes considered while searching for the best endpoint for a request"),
 		metric.WithExplicitBucketBoundaries(1, 2, 4, 8, 16, 32, 64, 128, 256, 512, 1024),
@@ -75,6 +140,66 @@ func Init(meter metric.Meter) error {
 		return fmt.Errorf("%s: %w", InferenceRequestsHashLookupDefaultMetricName, err)
 	}
 
+	InferenceTokensPrompt, err = meter.Int64Counter(InferenceTokensPromptMetricName,
+		metric.WithDescription("The number of prompt tokens processed by model, adapter and caller"),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", InferenceTokensPromptMetricName, err)
+	}
+	InferenceTokensCompletion, err = meter.Int64Counter(InferenceTokensCompletionMetricName,
+		metric.WithDescription("The number of completion tokens generated by model, adapter and caller"),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", InferenceTokensCompletionMetricName, err)
+	}
+
+	InferenceStreamsInterrupted, err = meter.Int64Counter(InferenceStreamsInterruptedMetricName,
+		metric.WithDescription("The number of streamed responses that were interrupted by a backend failure by model"),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", InferenceStreamsInterruptedMetricName, err)
+	}
+	InferenceRequestsFallback, err = meter.Int64Counter(InferenceRequestsFallbackMetricName,
+		metric.WithDescription("The number of requests that were served by a fallback model by model, fallback model and reason"),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", InferenceRequestsFallbackMetricName, err)
+	}
+	EndpointEjections, err = meter.Int64Counter(EndpointEjectionsMetricName,
+		metric.WithDescription("The number of times an endpoint was ejected from load balancing by model, endpoint and reason"),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", EndpointEjectionsMetricName, err)
+	}
+
+	ModelReplicasShortfall, err = meter.Int64Gauge(ModelReplicasShortfallMetricName,
+		metric.WithDescription("The number of replicas that the autoscaler could not allocate because the budget of the resource profile was exhausted by model"),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", ModelReplicasShortfallMetricName, err)
+	}
+	ModelReplicasDesired, err = meter.Int64Gauge(ModelReplicasDesiredMetricName,
+		metric.WithDescription("The number of replicas that the autoscaler scaled to (or would scale to in Recommend mode) by model"),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", ModelReplicasDesiredMetricName, err)
+	}
+
+	EndpointWarmupDuration, err = meter.Float64Histogram(EndpointWarmupDurationMetricName,
+		metric.WithDescription("The time it took to warm up a new endpoint by model"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(1, 2, 5, 10, 20, 30, 60, 120, 300, 600),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", EndpointWarmupDurationMetricName, err)
+	}
+	EndpointRequestsInFlight, err = meter.Int64UpDownCounter(EndpointRequestsInFlightMetricName,
+		metric.WithDescription("The number of requests that this KubeAI replica has in flight by endpoint, model and adapter (used to drain endpoints)"),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", EndpointRequestsInFlightMetricName, err)
+	}
+
 	return nil
 }
 
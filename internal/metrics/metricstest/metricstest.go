@@ -19,7 +19,7 @@ var (
 // Init should be called at the beginning of a test to wire up all global metrics
 // to a test reader. Test case should not be running in parallel with any other
 // part of the program that interacts with metrics.
-func Init(t *testing.T) {
+func Init(t testing.TB) {
 	testReader = metric.NewManualReader()
 	mp := metric.NewMeterProvider(
 		metric.WithReader(testReader),
@@ -56,6 +56,52 @@ func RequireActiveRequestsMetric(t *testing.T, mets metricdata.ResourceMetrics,
 	)
 }
 
+func RequireStreamsInterruptedMetric(t *testing.T, mets metricdata.ResourceMetrics, model string, val int64) {
+	met := requireMetricExists(t, mets, metrics.MeterName, metrics.InferenceStreamsInterruptedMetricName)
+	metricdatatest.AssertAggregationsEqual(t,
+		metricdata.Sum[int64]{
+			Temporality: metricdata.CumulativeTemporality,
+			IsMonotonic: true,
+			DataPoints: []metricdata.DataPoint[int64]{
+				{
+					Attributes: attribute.NewSet(
+						metrics.AttrRequestModel.String(model),
+						metrics.AttrRequestType.String(metrics.AttrRequestTypeHTTP),
+					),
+					Value: val,
+				},
+			},
+		},
+		met.Data,
+		metricdatatest.IgnoreExemplars(),
+		metricdatatest.IgnoreTimestamp(),
+	)
+}
+
+func RequireFallbackMetric(t *testing.T, mets metricdata.ResourceMetrics, model, fallback, reason string, val int64) {
+	met := requireMetricExists(t, mets, metrics.MeterName, metrics.InferenceRequestsFallbackMetricName)
+	metricdatatest.AssertAggregationsEqual(t,
+		metricdata.Sum[int64]{
+			Temporality: metricdata.CumulativeTemporality,
+			IsMonotonic: true,
+			DataPoints: []metricdata.DataPoint[int64]{
+				{
+					Attributes: attribute.NewSet(
+						metrics.AttrRequestModel.String(model),
+						metrics.AttrFallbackModel.String(fallback),
+						metrics.AttrFallbackReason.String(reason),
+						metrics.AttrRequestType.String(metrics.AttrRequestTypeHTTP),
+					),
+					Value: val,
+				},
+			},
+		},
+		met.Data,
+		metricdatatest.IgnoreExemplars(),
+		metricdatatest.IgnoreTimestamp(),
+	)
+}
+
 func requireMetricExists(t *testing.T, mets metricdata.ResourceMetrics, scope, name string) metricdata.Metrics {
 	for _, sm := range mets.ScopeMetrics {
 		if sm.Scope.Name == scope {
@@ -69,3 +115,41 @@ func requireMetricExists(t *testing.T, mets metricdata.ResourceMetrics, scope, n
 	t.Fatalf("metric %q not found in scope %q", name, scope)
 	return metricdata.Metrics{}
 }
+
+func RequireTokenUsageMetrics(t *testing.T, mets metricdata.ResourceMetrics, model, adapter, caller string, prompt, completion int64) {
+	attrs := attribute.NewSet(
+		metrics.AttrRequestModel.String(model),
+		metrics.AttrRequestAdapter.String(adapter),
+		metrics.AttrRequestCaller.String(caller),
+		metrics.AttrRequestType.String(metrics.AttrRequestTypeHTTP),
+	)
+	for name, val := range map[string]int64{
+		metrics.InferenceTokensPromptMetricName:     prompt,
+		metrics.InferenceTokensCompletionMetricName: completion,
+	} {
+		met := requireMetricExists(t, mets, metrics.MeterName, name)
+		metricdatatest.AssertAggregationsEqual(t,
+			metricdata.Sum[int64]{
+				Temporality: metricdata.CumulativeTemporality,
+				IsMonotonic: true,
+				DataPoints: []metricdata.DataPoint[int64]{
+					{Attributes: attrs, Value: val},
+				},
+			},
+			met.Data,
+			metricdatatest.IgnoreExemplars(),
+			metricdatatest.IgnoreTimestamp(),
+		)
+	}
+}
+
+// RequireWarmupDurationMetric asserts the number of warmups that were recorded for a model.
+func RequireWarmupDurationMetric(t *testing.T, mets metricdata.ResourceMetrics, model string, count uint64) {
+	met := requireMetricExists(t, mets, metrics.MeterName, metrics.EndpointWarmupDurationMetricName)
+	hist, ok := met.Data.(metricdata.Histogram[float64])
+	require.True(t, ok, "unexpected metric data type %T", met.Data)
+	require.Len(t, hist.DataPoints, 1)
+	dp := hist.DataPoints[0]
+	require.Equal(t, attribute.NewSet(metrics.AttrRequestModel.String(model)), dp.Attributes)
+	require.Equal(t, count, dp.Count)
+}
@@ -8,12 +8,14 @@ import (
 	"sync"
 	"time"
 
+	kubeaiv1 "github.com/kubeai-project/kubeai/api/k8s/v1"
 	"github.com/kubeai-project/kubeai/internal/config"
 	"github.com/kubeai-project/kubeai/internal/leader"
 	"github.com/kubeai-project/kubeai/internal/loadbalancer"
 	"github.com/kubeai-project/kubeai/internal/modelclient"
 	"github.com/kubeai-project/kubeai/internal/movingaverage"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -23,21 +25,24 @@ func New(
 	leaderElection *leader.Election,
 	modelClient *modelclient.ModelClient,
 	resolver *loadbalancer.LoadBalancer,
+	recorder record.EventRecorder,
 	cfg config.ModelAutoscaling,
-	metricsPort int,
+	resourceProfiles map[string]config.ResourceProfile,
+	selfMetrics *loadbalancer.SelfMetricsScraper,
 	stateConfigMapRef types.NamespacedName,
-	fixedSelfMetricAddrs []string,
 ) (*Autoscaler, error) {
 	a := &Autoscaler{
-		k8sClient:            k8sClient,
-		leaderElection:       leaderElection,
-		modelClient:          modelClient,
-		resolver:             resolver,
-		movingAvgByModel:     map[string]*movingaverage.Simple{},
-		cfg:                  cfg,
-		metricsPort:          metricsPort,
-		stateConfigMapRef:    stateConfigMapRef,
-		fixedSelfMetricAddrs: fixedSelfMetricAddrs,
+		k8sClient:         k8sClient,
+		leaderElection:    leaderElection,
+		modelClient:       modelClient,
+		resolver:          resolver,
+		recorder:          recorder,
+		movingAvgByModel:  map[string]movingaverage.Average{},
+		replicaHistory:    map[string][]int32{},
+		cfg:               cfg,
+		resourceProfiles:  resourceProfiles,
+		selfMetrics:       selfMetrics,
+		stateConfigMapRef: stateConfigMapRef,
 	}
 
 	// Load preloaded moving averages from the last known state.
@@ -56,14 +61,9 @@ func New(
 		return nil, fmt.Errorf("loading last state of models: %w", err)
 	}
 	log.Printf("Loaded last state of models: %d total, last calculated on %s", len(lastModelState.Models), lastModelState.LastCalculationTime)
-	for m, s := range lastModelState.Models {
-		// Preload moving averages with the last known state.
-		// If the last known state was 5.5, the preloaded moving average
-		// would look like [5.5, 5.5, 5.5, ...].
-		preloaded := newPrefilledFloat64Slice(a.cfg.AverageWindowCount(), s.AverageActiveRequests)
-		a.movingAvgByModel[m] = movingaverage.NewSimple(preloaded)
-		log.Printf("Preloaded moving average for model %q with %v", m, preloaded)
-	}
+	// The moving averages are restored when they are first used because
+	// the algorithm of each model is not known yet.
+	a.lastModelState = lastModelState
 
 	return a, nil
 }
@@ -80,15 +80,28 @@ type Autoscaler struct {
 
 	modelClient *modelclient.ModelClient
 	resolver    *loadbalancer.LoadBalancer
+	// recorder is optional.
+	recorder record.EventRecorder
 
-	cfg config.ModelAutoscaling
+	cfg              config.ModelAutoscaling
+	resourceProfiles map[string]config.ResourceProfile
 
-	metricsPort int
+	selfMetrics *loadbalancer.SelfMetricsScraper
 
 	movingAvgByModelMtx sync.Mutex
-	movingAvgByModel    map[string]*movingaverage.Simple
+	// movingAvgByModel holds the moving averages of the active requests by
+	// model and of the scaling metrics by scalingMetricKey().
+	movingAvgByModel map[string]movingaverage.Average
+	// lastModelState is the state that the moving averages are restored from.
+	lastModelState totalModelState
+	// replicaHistory holds the replicas of each model over the last
+	// autoscaling time window (see replicasAtWindowStart).
+	replicaHistory map[string][]int32
 
-	fixedSelfMetricAddrs []string
+	// lastCounters are the counters of the previous iteration that are
+	// used to calculate the rates of the scaling metrics.
+	lastCounters     map[counterKey]float64
+	lastCountersTime time.Time
 }
 
 func (a *Autoscaler) Start(ctx context.Context) {
@@ -115,27 +128,32 @@ func (a *Autoscaler) Start(ctx context.Context) {
 
 		nextModelState := newTotalModelState()
 
-		var selfAddrs []string
-		if len(a.fixedSelfMetricAddrs) > 0 {
-			selfAddrs = a.fixedSelfMetricAddrs
-		} else {
-			selfIPs := a.resolver.GetSelfIPs()
-			for _, ip := range selfIPs {
-				selfAddrs = append(selfAddrs, fmt.Sprintf("%s:%d", ip, a.metricsPort))
-			}
-		}
+		selfAddrs := a.selfMetrics.Addrs()
 		if len(selfAddrs) == 0 {
 			log.Println("Unable to resolve KubeAI addresses, skipping")
 			continue
 		}
 
 		log.Printf("Aggregating metrics from KubeAI addresses %v", selfAddrs)
-		agg := newMetricsAggregation()
-		if err := aggregateAllMetrics(agg, selfAddrs, "/metrics"); err != nil {
+		results, err := a.selfMetrics.Scrape(ctx)
+		if err != nil {
 			log.Printf("Failed to aggregate metrics: %v", err)
 			continue
 		}
+		agg := newMetricsAggregation()
+		for addr, metricFamilies := range results {
+			agg.aggregate(addr, metricFamilies)
+		}
+
+		now := time.Now()
+		increases := counterIncreases(a.lastCounters, agg.counters)
+		var elapsed time.Duration
+		if !a.lastCountersTime.IsZero() {
+			elapsed = now.Sub(a.lastCountersTime)
+		}
+		a.lastCounters, a.lastCountersTime = agg.counters, now
 
+		var decisions []*scaleDecision
 		for _, m := range models {
 			if m.Spec.AutoscalingDisabled {
 				log.Printf("Model %q has autoscaling disabled, skipping", m.Name)
@@ -148,18 +166,77 @@ func (a *Autoscaler) Start(ctx context.Context) {
 				activeRequestSum += req
 			}
 
-			avg := a.getMovingAvgActiveReqPerModel(m.Name)
+			avg := a.getMovingAvg(&m, "")
 			avg.Next(float64(activeRequestSum))
 			avgActiveRequests := avg.Calculate()
 			normalized := avgActiveRequests / float64(*m.Spec.TargetRequests)
 			ceil := math.Ceil(normalized)
-			log.Printf("Calculated target replicas for model %q: ceil(%v/%v) = %v, current requests: sum(%v) = %v, history: %v",
-				m.Name, avgActiveRequests, *m.Spec.TargetRequests, ceil, activeRequests, activeRequestSum, avg.History())
-			a.modelClient.Scale(ctx, &m, int32(ceil), a.cfg.RequiredConsecutiveScaleDowns(*m.Spec.ScaleDownDelaySeconds))
+			avgState := avg.State()
+			log.Printf("Calculated target replicas for model %q: ceil(%v/%v) = %v, current requests: sum(%v) = %v, moving average: %+v",
+				m.Name, avgActiveRequests, *m.Spec.TargetRequests, ceil, activeRequests, activeRequestSum, avgState)
 
-			nextModelState.Models[m.Name] = modelState{
+			d := &scaleDecision{model: &m}
+			d.addMetric(activeRequestsMetricName, avgActiveRequests, int64(*m.Spec.TargetRequests), ceil)
+
+			state := modelState{
 				AverageActiveRequests: avgActiveRequests,
+				ActiveRequests:        &avgState,
+			}
+			windowStartReplicas := a.replicasAtWindowStart(&m)
+			for _, sm := range m.Spec.ScalingMetrics {
+				val, ok, err := a.scalingMetricValue(ctx, &m, sm.Type, increases, elapsed)
+				if err != nil {
+					log.Printf("Failed to get %s of model %q: %v", sm.Type, m.Name, err)
+					continue
+				}
+				if !ok {
+					continue
+				}
+				avg := a.getMovingAvg(&m, sm.Type)
+				avg.Next(val)
+				avgVal := avg.Calculate()
+				value, base := avgVal, currentReplicas(&m)
+				if sm.Type == kubeaiv1.QueueWaitMillisecondsScalingMetric {
+					// The moving average of the wait time lags behind
+					// scaling, so applying it to the current replicas
+					// would scale them by the same ratio on every interval.
+					value, base = val, windowStartReplicas
+				}
+				replicas := scalingMetricReplicas(sm, value, base)
+				log.Printf("Calculated target replicas for model %q from %s: %v (target %v), current value: %v",
+					m.Name, sm.Type, replicas, sm.Target, val)
+				d.addMetric(string(sm.Type), avgVal, sm.Target, replicas)
+				ceil = max(ceil, replicas)
+				if state.AverageScalingMetrics == nil {
+					state.AverageScalingMetrics = make(map[kubeaiv1.ScalingMetricType]float64)
+					state.ScalingMetrics = make(map[kubeaiv1.ScalingMetricType]movingaverage.State)
+				}
+				state.AverageScalingMetrics[sm.Type] = avgVal
+				state.ScalingMetrics[sm.Type] = avg.State()
+			}
+
+			d.desired = int32(ceil)
+			decisions = append(decisions, d)
+
+			nextModelState.Models[m.Name] = state
+		}
+
+		a.allocateBudgets(ctx, models, decisions, now)
+		for _, d := range decisions {
+			required := a.cfg.RequiredConsecutiveScaleDowns(*d.model.Spec.ScaleDownDelaySeconds)
+			var result modelclient.ScaleResult
+			if autoscalingMode(d.model) == kubeaiv1.RecommendAutoscalingMode {
+				result = a.modelClient.Recommend(d.model, d.replicas(), required)
+				log.Printf("Recommending %d replicas for model %q (current %d)", result.Replicas, d.model.Name, result.PreviousReplicas)
+			} else {
+				var err error
+				result, err = a.modelClient.Scale(ctx, d.model, d.replicas(), required)
+				if err != nil {
+					log.Printf("Failed to scale model %q: %v", d.model.Name, err)
+					continue
+				}
 			}
+			a.recordDecision(ctx, d, result, required, now)
 		}
 
 		if err := a.saveTotalModelState(ctx, nextModelState); err != nil {
@@ -168,21 +245,139 @@ func (a *Autoscaler) Start(ctx context.Context) {
 	}
 }
 
-func (a *Autoscaler) getMovingAvgActiveReqPerModel(model string) *movingaverage.Simple {
+// getMovingAvg returns the moving average of the active requests of the
+// model (if t is empty) or of one of its scaling metrics.
+func (a *Autoscaler) getMovingAvg(m *kubeaiv1.Model, t kubeaiv1.ScalingMetricType) movingaverage.Average {
+	key := m.Name
+	if t != "" {
+		key = scalingMetricKey(m.Name, t)
+	}
+	algorithm := movingAverageAlgorithm(m)
+	size := a.cfg.AverageWindowCount()
+
 	a.movingAvgByModelMtx.Lock()
-	avg, ok := a.movingAvgByModel[model]
-	if !ok {
-		avg = movingaverage.NewSimple(make([]float64, a.cfg.AverageWindowCount()))
-		a.movingAvgByModel[model] = avg
+	defer a.movingAvgByModelMtx.Unlock()
+	avg, ok := a.movingAvgByModel[key]
+	switch {
+	case ok && avg.Algorithm() == algorithm:
+		return avg
+	case ok:
+		log.Printf("Moving average algorithm of model %q changed to %s, preloading with %v", m.Name, algorithm, avg.Calculate())
+		avg = movingaverage.NewPrefilled(algorithm, size, avg.Calculate())
+	default:
+		avg = a.restoreMovingAvg(m.Name, t, algorithm, size)
 	}
-	a.movingAvgByModelMtx.Unlock()
+	a.movingAvgByModel[key] = avg
 	return avg
 }
 
-func newPrefilledFloat64Slice(length int, value float64) []float64 {
-	s := make([]float64, length)
-	for i := range s {
-		s[i] = value
+// restoreMovingAvg restores a moving average from the last known state.
+func (a *Autoscaler) restoreMovingAvg(model string, t kubeaiv1.ScalingMetricType, algorithm movingaverage.Algorithm, size int) movingaverage.Average {
+	s, ok := a.lastModelState.Models[model]
+	if !ok {
+		return movingaverage.New(algorithm, size)
+	}
+	state, last := s.ActiveRequests, s.AverageActiveRequests
+	if t != "" {
+		if st, ok := s.ScalingMetrics[t]; ok {
+			state = &st
+		} else {
+			state = nil
+		}
+		if last, ok = s.AverageScalingMetrics[t]; !ok {
+			return movingaverage.New(algorithm, size)
+		}
+	}
+
+	if state != nil && state.Algorithm == algorithm {
+		log.Printf("Restored moving average of %s for model %q with %+v", metricName(t), model, *state)
+		return movingaverage.Restore(*state, size)
+	}
+	// Preload moving averages with the last known average.
+	// If the last known average was 5.5, the preloaded simple moving average
+	// would look like [5.5, 5.5, 5.5, ...].
+	log.Printf("Preloaded moving average of %s for model %q with %v", metricName(t), model, last)
+	return movingaverage.NewPrefilled(algorithm, size, last)
+}
+
+// movingAverageAlgorithm returns the moving average algorithm of the Model
+// (Simple if not set).
+func movingAverageAlgorithm(m *kubeaiv1.Model) movingaverage.Algorithm {
+	if m.Spec.MovingAverage == "" {
+		return movingaverage.SimpleAlgorithm
+	}
+	return movingaverage.Algorithm(m.Spec.MovingAverage)
+}
+
+func metricName(t kubeaiv1.ScalingMetricType) string {
+	if t == "" {
+		return activeRequestsMetricName
+	}
+	return string(t)
+}
+
+// scalingMetricValue returns the current value of a scaling metric of the
+// model. It returns false if the value is not available yet (rates are only
+// available after the second iteration).
+func (a *Autoscaler) scalingMetricValue(ctx context.Context, m *kubeaiv1.Model, t kubeaiv1.ScalingMetricType, increases map[counterKey]float64, elapsed time.Duration) (float64, bool, error) {
+	switch t {
+	case kubeaiv1.TokensPerSecondScalingMetric:
+		if elapsed <= 0 {
+			return 0, false, nil
+		}
+		return increases[counterKey{metric: counterTokens, model: m.Name}] / elapsed.Seconds(), true, nil
+	case kubeaiv1.QueueWaitMillisecondsScalingMetric:
+		if elapsed <= 0 {
+			return 0, false, nil
+		}
+		count := increases[counterKey{metric: counterQueueWaitCount, model: m.Name}]
+		if count == 0 {
+			return 0, true, nil
+		}
+		return increases[counterKey{metric: counterQueueWaitSum, model: m.Name}] / count * 1000, true, nil
+	case kubeaiv1.VLLMRequestsWaitingScalingMetric:
+		waiting, err := scrapeEngineRequestsWaiting(ctx, a.resolver.GetAllAddresses(m.Name))
+		if err != nil {
+			return 0, false, err
+		}
+		return waiting, true, nil
+	default:
+		return 0, false, fmt.Errorf("unknown scaling metric type %q", t)
+	}
+}
+
+// scalingMetricReplicas returns the number of replicas that are needed to
+// reach the target of a scaling metric given its value.
+func scalingMetricReplicas(sm kubeaiv1.ScalingMetric, value float64, replicas int32) float64 {
+	switch sm.Type {
+	case kubeaiv1.QueueWaitMillisecondsScalingMetric:
+		// The wait time is not proportional to the load per replica, so the
+		// given replicas (at the start of the time window) are scaled by the
+		// ratio of the current wait time to the target.
+		return math.Ceil(float64(replicas) * value / float64(sm.Target))
+	default:
+		return math.Ceil(value / float64(sm.Target))
+	}
+}
+
+// replicasAtWindowStart records the current replicas of the model and
+// returns its replicas at the start of the autoscaling time window.
+func (a *Autoscaler) replicasAtWindowStart(m *kubeaiv1.Model) int32 {
+	h := append(a.replicaHistory[m.Name], currentReplicas(m))
+	if size := a.cfg.AverageWindowCount(); len(h) > size {
+		h = h[len(h)-size:]
+	}
+	a.replicaHistory[m.Name] = h
+	return h[0]
+}
+
+func scalingMetricKey(model string, t kubeaiv1.ScalingMetricType) string {
+	return model + "/" + string(t)
+}
+
+func currentReplicas(m *kubeaiv1.Model) int32 {
+	if m.Spec.Replicas == nil {
+		return 0
 	}
-	return s
+	return *m.Spec.Replicas
 }
@@ -0,0 +1,82 @@
+package modelautoscaler
+
+import (
+	"testing"
+	"time"
+
+	v1 "github.com/kubeai-project/kubeai/api/k8s/v1"
+	"github.com/kubeai-project/kubeai/internal/config"
+	"github.com/kubeai-project/kubeai/internal/movingaverage"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestGetMovingAvg(t *testing.T) {
+	model := &v1.Model{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-model"},
+		Spec:       v1.ModelSpec{MovingAverage: v1.MaxMovingAverage},
+	}
+	a := &Autoscaler{
+		cfg: config.ModelAutoscaling{
+			Interval:   config.Duration{Duration: time.Second},
+			TimeWindow: config.Duration{Duration: 3 * time.Second},
+		},
+		movingAvgByModel: map[string]movingaverage.Average{},
+		lastModelState: totalModelState{Models: map[string]modelState{
+			"my-model": {
+				AverageActiveRequests: 4,
+				ActiveRequests:        &movingaverage.State{Algorithm: movingaverage.MaxAlgorithm, Window: []float64{1, 7, 4}},
+				// State of a previous version without the states of the moving averages.
+				AverageScalingMetrics: map[v1.ScalingMetricType]float64{v1.TokensPerSecondScalingMetric: 500},
+			},
+		}},
+	}
+
+	// The state is restored if the algorithm did not change.
+	avg := a.getMovingAvg(model, "")
+	require.Equal(t, []float64{1, 7, 4}, avg.State().Window)
+	require.Equal(t, 7.0, avg.Calculate())
+
+	// The moving average is preloaded with the last average otherwise.
+	avg = a.getMovingAvg(model, v1.TokensPerSecondScalingMetric)
+	require.Equal(t, []float64{500, 500, 500}, avg.State().Window)
+
+	// Unknown metrics start at zero.
+	avg = a.getMovingAvg(model, v1.VLLMRequestsWaitingScalingMetric)
+	require.Equal(t, 0.0, avg.Calculate())
+
+	// A change of the algorithm preloads the new moving average with the
+	// current value.
+	model.Spec.MovingAverage = v1.ExponentialMovingAverage
+	avg = a.getMovingAvg(model, "")
+	require.Equal(t, movingaverage.ExponentialAlgorithm, avg.Algorithm())
+	require.Equal(t, 7.0, avg.Calculate())
+	require.Same(t, avg, a.getMovingAvg(model, ""))
+}
+
+func TestQueueWaitReplicasDoNotCompound(t *testing.T) {
+	model := &v1.Model{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-model"},
+		Spec:       v1.ModelSpec{Replicas: ptr.To[int32](2)},
+	}
+	a := &Autoscaler{
+		cfg: config.ModelAutoscaling{
+			Interval:   config.Duration{Duration: 10 * time.Second},
+			TimeWindow: config.Duration{Duration: 10 * time.Minute},
+		},
+		replicaHistory: map[string][]int32{},
+	}
+	sm := v1.ScalingMetric{Type: v1.QueueWaitMillisecondsScalingMetric, Target: 500}
+
+	// The wait time stays at twice the target while the added replicas start.
+	for tick := 0; tick < a.cfg.AverageWindowCount(); tick++ {
+		replicas := int32(scalingMetricReplicas(sm, 1000, a.replicasAtWindowStart(model)))
+		require.Equal(t, int32(4), replicas, "tick %d", tick)
+		model.Spec.Replicas = ptr.To(replicas)
+	}
+
+	// The ratio is applied to the scaled replicas once the replicas before
+	// scaling left the window.
+	require.Equal(t, 8.0, scalingMetricReplicas(sm, 1000, a.replicasAtWindowStart(model)))
+}
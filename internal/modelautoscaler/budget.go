@@ -0,0 +1,153 @@
+package modelautoscaler
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	kubeaiv1 "github.com/kubeai-project/kubeai/api/k8s/v1"
+	"github.com/kubeai-project/kubeai/internal/metrics"
+	"github.com/kubeai-project/kubeai/internal/modelclient"
+	"github.com/kubeai-project/kubeai/internal/schedule"
+	"go.opentelemetry.io/otel/metric"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// budgetRequest is the request of a Model for replicas from the budget of
+// its resource profile.
+type budgetRequest struct {
+	decision *scaleDecision
+	priority int32
+	// units is the number of units of the resource profile per replica.
+	units int64
+	// min and desired are the desired replicas within the replica bounds.
+	min, desired int32
+
+	allocated int32
+}
+
+// allocateBudgets limits the desired replicas of the Models to the budgets
+// of their resource profiles.
+func (a *Autoscaler) allocateBudgets(ctx context.Context, models []kubeaiv1.Model, decisions []*scaleDecision, now time.Time) {
+	// Recommendations do not change the replicas of Models, so they are not
+	// limited by the budget.
+	var scaled []*scaleDecision
+	decided := make(map[string]bool, len(decisions))
+	for _, d := range decisions {
+		if autoscalingMode(d.model) == kubeaiv1.RecommendAutoscalingMode {
+			continue
+		}
+		scaled = append(scaled, d)
+		decided[d.model.Name] = true
+	}
+	decisions = scaled
+
+	// The replicas of Models that are not autoscaled (or only get recommendations)
+	// use up the budget first.
+	used := map[string]int64{}
+	for _, m := range models {
+		if decided[m.Name] {
+			continue
+		}
+		profile, units, ok := modelclient.BudgetedProfile(&m, a.resourceProfiles)
+		if !ok {
+			continue
+		}
+		used[profile] += int64(currentReplicas(&m)) * units
+	}
+
+	priorities := map[string]int32{}
+	requests := map[string][]*budgetRequest{}
+	for _, d := range decisions {
+		profile, units, ok := modelclient.BudgetedProfile(d.model, a.resourceProfiles)
+		if !ok {
+			if d.model.Status.Budget != nil {
+				// The budget was removed from the profile.
+				metrics.ModelReplicasShortfall.Record(ctx, 0, metric.WithAttributes(
+					metrics.AttrRequestModel.String(d.model.Name),
+				))
+			}
+			continue
+		}
+		className := d.model.Spec.PriorityClassName
+		priority, ok := priorities[className]
+		if !ok {
+			priority = a.getPriority(ctx, className)
+			priorities[className] = priority
+		}
+		min, max := schedule.ReplicaBounds(d.model, now)
+		desired := d.desired
+		if max != nil && desired > *max {
+			desired = *max
+		}
+		if desired < min {
+			desired = min
+		}
+		requests[profile] = append(requests[profile], &budgetRequest{
+			decision: d,
+			priority: priority,
+			units:    units,
+			min:      min,
+			desired:  desired,
+		})
+	}
+
+	for profile, reqs := range requests {
+		budget := a.resourceProfiles[profile].Budget
+		allocateBudget(budget, used[profile], reqs)
+		for _, r := range reqs {
+			allocated := r.allocated
+			shortfall := r.desired - r.allocated
+			r.decision.allocated = &allocated
+			r.decision.budget = &kubeaiv1.ModelStatusBudget{Shortfall: shortfall}
+			if shortfall > 0 {
+				log.Printf("Budget of resource profile %q (%d) exhausted, allocated %d of %d desired replicas to model %q",
+					profile, budget, r.allocated, r.desired, r.decision.model.Name)
+			}
+			metrics.ModelReplicasShortfall.Record(ctx, int64(shortfall), metric.WithAttributes(
+				metrics.AttrRequestModel.String(r.decision.model.Name),
+			))
+		}
+	}
+}
+
+// allocateBudget allocates the budget of a resource profile that is not
+// used yet to the requests. The min replicas of the requests are always
+// allocated. The remaining replicas are allocated in the order of priority.
+func allocateBudget(budget, used int64, reqs []*budgetRequest) {
+	sort.SliceStable(reqs, func(i, j int) bool {
+		if reqs[i].priority != reqs[j].priority {
+			return reqs[i].priority > reqs[j].priority
+		}
+		return reqs[i].decision.model.Name < reqs[j].decision.model.Name
+	})
+
+	for _, r := range reqs {
+		r.allocated = r.min
+		used += int64(r.min) * r.units
+	}
+	for _, r := range reqs {
+		free := budget - used
+		if free < r.units {
+			continue
+		}
+		add := int32(min(free/r.units, int64(r.desired-r.allocated)))
+		r.allocated += add
+		used += int64(add) * r.units
+	}
+}
+
+// getPriority returns the value of the PriorityClass (0 if it is not set or not found).
+func (a *Autoscaler) getPriority(ctx context.Context, className string) int32 {
+	if className == "" {
+		return 0
+	}
+	var pc schedulingv1.PriorityClass
+	if err := a.k8sClient.Get(ctx, client.ObjectKey{Name: className}, &pc); err != nil {
+		log.Printf("Failed to get PriorityClass %q, assuming priority 0: %v", className, err)
+		return 0
+	}
+	return pc.Value
+}
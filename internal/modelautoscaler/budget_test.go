@@ -0,0 +1,132 @@
+package modelautoscaler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "github.com/kubeai-project/kubeai/api/k8s/v1"
+	"github.com/kubeai-project/kubeai/internal/config"
+	"github.com/kubeai-project/kubeai/internal/metrics/metricstest"
+	"github.com/stretchr/testify/require"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestAllocateBudget(t *testing.T) {
+	req := func(name string, priority int32, units int64, min, desired int32) *budgetRequest {
+		return &budgetRequest{
+			decision: &scaleDecision{model: &v1.Model{ObjectMeta: metav1.ObjectMeta{Name: name}}},
+			priority: priority,
+			units:    units,
+			min:      min,
+			desired:  desired,
+		}
+	}
+	allocations := func(reqs []*budgetRequest) map[string]int32 {
+		result := map[string]int32{}
+		for _, r := range reqs {
+			result[r.decision.model.Name] = r.allocated
+		}
+		return result
+	}
+
+	cases := map[string]struct {
+		budget, used int64
+		reqs         []*budgetRequest
+		exp          map[string]int32
+	}{
+		"within budget": {
+			budget: 10,
+			reqs:   []*budgetRequest{req("a", 0, 1, 0, 3), req("b", 0, 2, 0, 2)},
+			exp:    map[string]int32{"a": 3, "b": 2},
+		},
+		"by priority": {
+			budget: 8,
+			reqs:   []*budgetRequest{req("low", 0, 1, 0, 5), req("high", 100, 2, 0, 3)},
+			exp:    map[string]int32{"low": 2, "high": 3},
+		},
+		"min replicas are allocated first": {
+			budget: 8,
+			reqs:   []*budgetRequest{req("low", 0, 2, 1, 5), req("high", 100, 2, 0, 5)},
+			exp:    map[string]int32{"low": 1, "high": 3},
+		},
+		"ties are broken by name": {
+			budget: 3,
+			reqs:   []*budgetRequest{req("b", 0, 1, 0, 2), req("a", 0, 1, 0, 2)},
+			exp:    map[string]int32{"a": 2, "b": 1},
+		},
+		"smaller replicas fill the rest": {
+			budget: 5,
+			reqs:   []*budgetRequest{req("big", 100, 4, 0, 2), req("small", 0, 1, 0, 2)},
+			exp:    map[string]int32{"big": 1, "small": 1},
+		},
+		"budget used by other models": {
+			budget: 5,
+			used:   4,
+			reqs:   []*budgetRequest{req("a", 0, 1, 0, 3)},
+			exp:    map[string]int32{"a": 1},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			allocateBudget(c.budget, c.used, c.reqs)
+			require.Equal(t, c.exp, allocations(c.reqs))
+		})
+	}
+}
+
+func TestAllocateBudgets(t *testing.T) {
+	metricstest.Init(t)
+	ctx := context.Background()
+
+	model := func(name, profile, priorityClass string, replicas int32, autoscalingDisabled bool) *v1.Model {
+		return &v1.Model{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: v1.ModelSpec{
+				ResourceProfile:     profile,
+				PriorityClassName:   priorityClass,
+				Replicas:            ptr.To(replicas),
+				AutoscalingDisabled: autoscalingDisabled,
+			},
+		}
+	}
+	fixed := model("fixed", "gpu:1", "", 2, true)
+	high := model("high", "gpu:2", "high", 1, false)
+	low := model("low", "gpu:1", "", 1, false)
+	unlimited := model("unlimited", "cpu:1", "", 1, false)
+	recommend := model("recommend", "gpu:1", "high", 1, false)
+	recommend.Spec.AutoscalingMode = v1.RecommendAutoscalingMode
+	highPriority := &schedulingv1.PriorityClass{ObjectMeta: metav1.ObjectMeta{Name: "high"}, Value: 1000}
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1.AddToScheme(scheme))
+	a := &Autoscaler{
+		k8sClient: fake.NewClientBuilder().WithScheme(scheme).WithObjects(highPriority).Build(),
+		resourceProfiles: map[string]config.ResourceProfile{
+			"gpu": {Budget: 9},
+			"cpu": {},
+		},
+	}
+
+	decisions := []*scaleDecision{
+		{model: low, desired: 4},
+		{model: high, desired: 2},
+		{model: unlimited, desired: 10},
+		{model: recommend, desired: 10},
+	}
+	a.allocateBudgets(ctx, []v1.Model{*fixed, *high, *low, *unlimited, *recommend}, decisions, time.Now())
+	// 2 units are used by the fixed model, 1 by the current replica of the
+	// recommend model and 4 by the high priority model.
+	require.Equal(t, []int32{2, 2, 10, 10}, []int32{decisions[0].replicas(), decisions[1].replicas(), decisions[2].replicas(), decisions[3].replicas()})
+
+	require.Equal(t, &v1.ModelStatusBudget{Shortfall: 2}, decisions[0].budget)
+	require.Equal(t, &v1.ModelStatusBudget{Shortfall: 0}, decisions[1].budget)
+	require.Nil(t, decisions[2].budget)
+	require.Nil(t, decisions[3].budget)
+}
@@ -0,0 +1,130 @@
+package modelautoscaler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	kubeaiv1 "github.com/kubeai-project/kubeai/api/k8s/v1"
+	"github.com/kubeai-project/kubeai/internal/metrics"
+	"github.com/kubeai-project/kubeai/internal/modelclient"
+	"go.opentelemetry.io/otel/metric"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// activeRequestsMetricName is the name of the active requests metric in the
+// autoscaling status of Models.
+const activeRequestsMetricName = "ActiveRequests"
+
+// scaleDecision is the number of replicas that the autoscaler calculated
+// for a Model.
+type scaleDecision struct {
+	model   *kubeaiv1.Model
+	desired int32
+	// metrics are the values that the desired replicas were calculated from.
+	metrics []kubeaiv1.ModelStatusAutoscalingMetric
+	// allocated is the number of replicas that fit into the budget of the
+	// resource profile of the Model (nil if the profile has no budget).
+	allocated *int32
+	budget    *kubeaiv1.ModelStatusBudget
+}
+
+func (d *scaleDecision) replicas() int32 {
+	if d.allocated != nil {
+		return *d.allocated
+	}
+	return d.desired
+}
+
+func (d *scaleDecision) addMetric(name string, value float64, target int64, desired float64) {
+	d.metrics = append(d.metrics, kubeaiv1.ModelStatusAutoscalingMetric{
+		Name:            name,
+		Value:           formatMetricValue(value),
+		Target:          target,
+		DesiredReplicas: int32(desired),
+	})
+}
+
+// recordDecision records the decision in the status of the Model and
+// records an Event if the replicas of the Model changed.
+func (a *Autoscaler) recordDecision(ctx context.Context, d *scaleDecision, result modelclient.ScaleResult, requiredScaleDowns int, now time.Time) {
+	m := d.model
+	mode := autoscalingMode(m)
+
+	metrics.ModelReplicasDesired.Record(ctx, int64(result.Replicas), metric.WithAttributes(
+		metrics.AttrRequestModel.String(m.Name),
+		metrics.AttrAutoscalingMode.String(string(mode)),
+	))
+
+	status := &kubeaiv1.ModelStatusAutoscaling{
+		Mode:               mode,
+		DesiredReplicas:    result.DesiredReplicas,
+		Replicas:           result.Replicas,
+		Metrics:            d.metrics,
+		Window:             metav1.Duration{Duration: a.cfg.TimeWindow.Duration},
+		PendingScaleDowns:  int32(result.ConsecutiveScaleDowns),
+		RequiredScaleDowns: int32(requiredScaleDowns),
+	}
+	prev := m.Status.Autoscaling
+	if prev != nil {
+		status.LastScaleTime = prev.LastScaleTime
+	}
+
+	if mode == kubeaiv1.RecommendAutoscalingMode {
+		// The replicas of the Model are not changed, so the recommendation
+		// is compared to the last recommendation.
+		if prev == nil || prev.Mode != mode || prev.Replicas != result.Replicas {
+			status.LastScaleTime = &metav1.Time{Time: now}
+		}
+	} else if result.Replicas != result.PreviousReplicas {
+		status.LastScaleTime = &metav1.Time{Time: now}
+		reason := "ScaledUp"
+		if result.Replicas < result.PreviousReplicas {
+			reason = "ScaledDown"
+		}
+		if a.recorder != nil {
+			a.recorder.Eventf(m, corev1.EventTypeNormal, reason, "Scaled from %d to %d replicas based on %s",
+				result.PreviousReplicas, result.Replicas, describeMetrics(d.metrics))
+		}
+	}
+
+	if reflect.DeepEqual(m.Status.Autoscaling, status) && reflect.DeepEqual(m.Status.Budget, d.budget) {
+		return
+	}
+	base := client.MergeFrom(m.DeepCopy())
+	m.Status.Autoscaling = status
+	m.Status.Budget = d.budget
+	if err := a.k8sClient.Status().Patch(ctx, m, base); err != nil {
+		log.Printf("Failed to update autoscaling status of model %q: %v", m.Name, err)
+	}
+}
+
+// autoscalingMode returns the autoscaling mode of the Model (Auto if not set).
+func autoscalingMode(m *kubeaiv1.Model) kubeaiv1.AutoscalingMode {
+	if m.Spec.AutoscalingMode == "" {
+		return kubeaiv1.AutoAutoscalingMode
+	}
+	return m.Spec.AutoscalingMode
+}
+
+// describeMetrics describes the metrics for Events:
+// "ActiveRequests 250/100 (3 replicas), TokensPerSecond 900/1000 (1 replicas)".
+func describeMetrics(mets []kubeaiv1.ModelStatusAutoscalingMetric) string {
+	parts := make([]string, len(mets))
+	for i, met := range mets {
+		parts[i] = fmt.Sprintf("%s %s/%d (%d replicas)", met.Name, met.Value, met.Target, met.DesiredReplicas)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatMetricValue rounds the value to avoid status updates for
+// insignificant changes.
+func formatMetricValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
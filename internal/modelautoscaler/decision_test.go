@@ -0,0 +1,107 @@
+package modelautoscaler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "github.com/kubeai-project/kubeai/api/k8s/v1"
+	"github.com/kubeai-project/kubeai/internal/config"
+	"github.com/kubeai-project/kubeai/internal/metrics/metricstest"
+	"github.com/kubeai-project/kubeai/internal/modelclient"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRecordDecision(t *testing.T) {
+	metricstest.Init(t)
+	ctx := context.Background()
+	model := &v1.Model{ObjectMeta: metav1.ObjectMeta{Name: "my-model", Namespace: "default"}}
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1.AddToScheme(scheme))
+	recorder := record.NewFakeRecorder(10)
+	a := &Autoscaler{
+		k8sClient: fake.NewClientBuilder().WithScheme(scheme).WithObjects(model).WithStatusSubresource(model).Build(),
+		recorder:  recorder,
+		cfg:       config.ModelAutoscaling{TimeWindow: config.Duration{Duration: 10 * time.Minute}},
+	}
+	getStatus := func() *v1.ModelStatusAutoscaling {
+		var got v1.Model
+		require.NoError(t, a.k8sClient.Get(ctx, client.ObjectKeyFromObject(model), &got))
+		return got.Status.Autoscaling
+	}
+
+	now := time.Now().Truncate(time.Second)
+	d := &scaleDecision{model: model, desired: 3}
+	d.addMetric(activeRequestsMetricName, 250, 100, 3)
+	d.addMetric(string(v1.TokensPerSecondScalingMetric), 900.123, 1000, 1)
+	a.recordDecision(ctx, d, modelclient.ScaleResult{DesiredReplicas: 3, PreviousReplicas: 1, Replicas: 3}, 3, now)
+
+	require.Equal(t, "Normal ScaledUp Scaled from 1 to 3 replicas based on ActiveRequests 250.00/100 (3 replicas), TokensPerSecond 900.12/1000 (1 replicas)", <-recorder.Events)
+	require.Equal(t, &v1.ModelStatusAutoscaling{
+		Mode:            v1.AutoAutoscalingMode,
+		DesiredReplicas: 3,
+		Replicas:        3,
+		Metrics: []v1.ModelStatusAutoscalingMetric{
+			{Name: "ActiveRequests", Value: "250.00", Target: 100, DesiredReplicas: 3},
+			{Name: "TokensPerSecond", Value: "900.12", Target: 1000, DesiredReplicas: 1},
+		},
+		Window:             metav1.Duration{Duration: 10 * time.Minute},
+		RequiredScaleDowns: 3,
+		LastScaleTime:      &metav1.Time{Time: now},
+	}, getStatus())
+
+	// A delayed scale down keeps the last scale time and records no Event.
+	d = &scaleDecision{model: model, desired: 1}
+	d.addMetric(activeRequestsMetricName, 50, 100, 1)
+	a.recordDecision(ctx, d, modelclient.ScaleResult{DesiredReplicas: 1, PreviousReplicas: 3, Replicas: 3, ConsecutiveScaleDowns: 1}, 3, now.Add(time.Minute))
+	require.Empty(t, recorder.Events)
+	status := getStatus()
+	require.Equal(t, int32(1), status.DesiredReplicas)
+	require.Equal(t, int32(3), status.Replicas)
+	require.Equal(t, int32(1), status.PendingScaleDowns)
+	require.Equal(t, now, status.LastScaleTime.Time)
+}
+
+func TestRecordRecommendation(t *testing.T) {
+	metricstest.Init(t)
+	ctx := context.Background()
+	model := &v1.Model{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-model", Namespace: "default"},
+		Spec:       v1.ModelSpec{AutoscalingMode: v1.RecommendAutoscalingMode},
+	}
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1.AddToScheme(scheme))
+	recorder := record.NewFakeRecorder(10)
+	a := &Autoscaler{
+		k8sClient: fake.NewClientBuilder().WithScheme(scheme).WithObjects(model).WithStatusSubresource(model).Build(),
+		recorder:  recorder,
+	}
+	getStatus := func() *v1.ModelStatusAutoscaling {
+		var got v1.Model
+		require.NoError(t, a.k8sClient.Get(ctx, client.ObjectKeyFromObject(model), &got))
+		return got.Status.Autoscaling
+	}
+
+	now := time.Now().Truncate(time.Second)
+	d := &scaleDecision{model: model, desired: 3}
+	a.recordDecision(ctx, d, modelclient.ScaleResult{DesiredReplicas: 3, PreviousReplicas: 1, Replicas: 3}, 3, now)
+	require.Empty(t, recorder.Events)
+	status := getStatus()
+	require.Equal(t, v1.RecommendAutoscalingMode, status.Mode)
+	require.Equal(t, int32(3), status.Replicas)
+	require.Equal(t, now, status.LastScaleTime.Time)
+
+	// The replicas of the Model did not change, but the recommendation did not either.
+	a.recordDecision(ctx, d, modelclient.ScaleResult{DesiredReplicas: 3, PreviousReplicas: 1, Replicas: 3}, 3, now.Add(time.Minute))
+	require.Equal(t, now, getStatus().LastScaleTime.Time)
+}
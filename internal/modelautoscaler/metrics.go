@@ -1,59 +1,72 @@
 package modelautoscaler
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"io"
-	"net/http"
-	"strings"
+	"log"
+	"sync"
+	"time"
 
+	"github.com/kubeai-project/kubeai/internal/loadbalancer"
 	"github.com/kubeai-project/kubeai/internal/metrics"
 	io_prometheus_client "github.com/prometheus/client_model/go"
-	"github.com/prometheus/common/expfmt"
 )
 
-func aggregateAllMetrics(agg *metricsAggregation, addrs []string, path string) (err error) {
-	// TODO: Consider concurrently scraping metrics from all endpoints.
-	for _, addr := range addrs {
-		if e := scrapeAndAggregateMetrics(agg, fmt.Sprintf("http://%s%s", addr, path)); e != nil {
-			err = errors.Join(err, e)
-		}
-	}
-
-	return err
-}
-
 type metricsAggregation struct {
 	activeRequestsByModel map[string][]int64
+	// counters are the cumulative values of the counters that were scraped
+	// from each address. Their increase between scrapes is used to
+	// calculate rates.
+	counters map[counterKey]float64
 }
 
 func newMetricsAggregation() *metricsAggregation {
 	return &metricsAggregation{
 		activeRequestsByModel: make(map[string][]int64),
+		counters:              make(map[counterKey]float64),
 	}
 }
 
-func scrapeAndAggregateMetrics(agg *metricsAggregation, url string) error {
-	// Perform the HTTP GET request
-	resp, err := http.Get(url)
-	if err != nil {
-		return fmt.Errorf("failed to scrape metrics: %w", err)
-	}
-	defer resp.Body.Close()
+type counterKey struct {
+	addr   string
+	metric string
+	model  string
+}
 
-	// Read the response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read response body: %w", err)
-	}
+// Names of the counters that are aggregated.
+const (
+	// counterTokens is the number of prompt and completion tokens.
+	counterTokens = "tokens"
+	// counterQueueWaitSum is the sum of the time (in seconds) that requests waited for an endpoint.
+	counterQueueWaitSum = "queue_wait_sum"
+	// counterQueueWaitCount is the number of requests that waited for an endpoint.
+	counterQueueWaitCount = "queue_wait_count"
+)
 
-	// Use the expfmt library to parse the Prometheus metrics
-	parser := expfmt.TextParser{}
-	metricFamilies, err := parser.TextToMetricFamilies(strings.NewReader(string(body)))
-	if err != nil {
-		return fmt.Errorf("failed to parse metrics: %w", err)
+// counterIncreases returns the increase of the counters since the previous
+// scrape summed across addresses (the addr of the returned keys is empty).
+// Counters that were not scraped before are ignored and counters that were
+// reset (i.e. because KubeAI restarted) are counted from zero.
+func counterIncreases(prev, next map[counterKey]float64) map[counterKey]float64 {
+	increases := make(map[counterKey]float64)
+	for k, v := range next {
+		p, ok := prev[k]
+		if !ok {
+			continue
+		}
+		inc := v - p
+		if inc < 0 {
+			inc = v
+		}
+		increases[counterKey{metric: k.metric, model: k.model}] += inc
 	}
+	return increases
+}
 
+// aggregate adds the metrics that were scraped from the KubeAI replica at the
+// given address to the aggregation.
+func (agg *metricsAggregation) aggregate(addr string, metricFamilies map[string]*io_prometheus_client.MetricFamily) {
 	if fam, ok := metricFamilies[metrics.OtelNameToPromName(metrics.InferenceRequestsActiveMetricName)]; ok {
 		for _, m := range fam.Metric {
 			for _, label := range m.Label {
@@ -67,7 +80,78 @@ func scrapeAndAggregateMetrics(agg *metricsAggregation, url string) error {
 		}
 	}
 
-	return nil
+	// The Prometheus exporter adds a "_total" suffix to counters and
+	// a unit suffix to histograms.
+	for _, name := range []string{metrics.InferenceTokensPromptMetricName, metrics.InferenceTokensCompletionMetricName} {
+		if fam, ok := metricFamilies[metrics.OtelNameToPromName(name)+"_total"]; ok {
+			for _, m := range fam.Metric {
+				if model := getModelLabel(m); model != "" {
+					agg.counters[counterKey{addr: addr, metric: counterTokens, model: model}] += m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	if fam, ok := metricFamilies[metrics.OtelNameToPromName(metrics.InferenceRequestsQueueWaitMetricName)+"_seconds"]; ok {
+		for _, m := range fam.Metric {
+			if model := getModelLabel(m); model != "" && m.Histogram != nil {
+				agg.counters[counterKey{addr: addr, metric: counterQueueWaitSum, model: model}] += m.GetHistogram().GetSampleSum()
+				agg.counters[counterKey{addr: addr, metric: counterQueueWaitCount, model: model}] += float64(m.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+}
+
+func getModelLabel(m *io_prometheus_client.Metric) string {
+	for _, label := range m.Label {
+		if label.GetName() == metrics.OtelAttrToPromLabel(metrics.AttrRequestModel) {
+			return label.GetValue()
+		}
+	}
+	return ""
+}
+
+// engineScrapeTimeout is the timeout for scraping the metrics of an engine.
+const engineScrapeTimeout = 5 * time.Second
+
+// scrapeEngineRequestsWaiting returns the sum of the requests that are
+// waiting in the queues of the engines (vLLM) at the given addresses.
+// The engines are scraped concurrently. Engines that could not be scraped
+// (e.g. Pods that are starting up or shutting down) are skipped, an error is
+// only returned if none of the engines could be scraped.
+func scrapeEngineRequestsWaiting(ctx context.Context, addrs []string) (float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, engineScrapeTimeout)
+	defer cancel()
+
+	var (
+		mtx     sync.Mutex
+		sum     float64
+		scraped int
+		errs    error
+		wg      sync.WaitGroup
+	)
+	for _, addr := range addrs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m, err := loadbalancer.ScrapeEngineMetrics(ctx, addr)
+			mtx.Lock()
+			defer mtx.Unlock()
+			if err != nil {
+				errs = errors.Join(errs, fmt.Errorf("%s: %w", addr, err))
+				return
+			}
+			sum += m.Waiting
+			scraped++
+		}()
+	}
+	wg.Wait()
+	if errs != nil {
+		if scraped == 0 {
+			return 0, errs
+		}
+		log.Printf("Skipping engines that could not be scraped: %v", errs)
+	}
+	return sum, nil
 }
 
 func getMetricsValue(mf *io_prometheus_client.MetricFamily, m *io_prometheus_client.Metric) int64 {
@@ -0,0 +1,128 @@
+package modelautoscaler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	v1 "github.com/kubeai-project/kubeai/api/k8s/v1"
+	"github.com/kubeai-project/kubeai/internal/loadbalancer"
+	"github.com/stretchr/testify/require"
+)
+
+const testKubeAIMetrics = `# TYPE kubeai_inference_requests_active gauge
+kubeai_inference_requests_active{request_model="m1",request_type="http"} 3
+# TYPE kubeai_inference_tokens_prompt_total counter
+kubeai_inference_tokens_prompt_total{request_adapter="",request_caller="a",request_model="m1"} 100
+kubeai_inference_tokens_prompt_total{request_adapter="",request_caller="b",request_model="m1"} 50
+# TYPE kubeai_inference_tokens_completion_total counter
+kubeai_inference_tokens_completion_total{request_adapter="",request_caller="a",request_model="m1"} 25
+# TYPE kubeai_inference_requests_queue_wait_seconds histogram
+kubeai_inference_requests_queue_wait_seconds_bucket{request_model="m1",le="+Inf"} 4
+kubeai_inference_requests_queue_wait_seconds_sum{request_model="m1"} 2.5
+kubeai_inference_requests_queue_wait_seconds_count{request_model="m1"} 4
+`
+
+func TestAggregateMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(testKubeAIMetrics))
+	}))
+	defer server.Close()
+	addr := strings.TrimPrefix(server.URL, "http://")
+
+	results, err := loadbalancer.NewSelfMetricsScraper(nil, 0, []string{addr}).Scrape(context.Background())
+	require.NoError(t, err)
+	agg := newMetricsAggregation()
+	for addr, metricFamilies := range results {
+		agg.aggregate(addr, metricFamilies)
+	}
+	require.Equal(t, []int64{3}, agg.activeRequestsByModel["m1"])
+	require.Equal(t, map[counterKey]float64{
+		{addr: addr, metric: counterTokens, model: "m1"}:         175,
+		{addr: addr, metric: counterQueueWaitSum, model: "m1"}:   2.5,
+		{addr: addr, metric: counterQueueWaitCount, model: "m1"}: 4,
+	}, agg.counters)
+}
+
+func TestScrapeEngineRequestsWaiting(t *testing.T) {
+	newEngine := func(body string, status int) string {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(body))
+		}))
+		t.Cleanup(server.Close)
+		return strings.TrimPrefix(server.URL, "http://")
+	}
+	engine1 := newEngine("# TYPE vllm:num_requests_waiting gauge\nvllm:num_requests_waiting{model_name=\"m1\"} 3\n", http.StatusOK)
+	engine2 := newEngine("# TYPE vllm:num_requests_waiting gauge\nvllm:num_requests_waiting{model_name=\"m1\"} 4\n", http.StatusOK)
+	starting := newEngine("", http.StatusServiceUnavailable)
+
+	waiting, err := scrapeEngineRequestsWaiting(context.Background(), []string{engine1, engine2})
+	require.NoError(t, err)
+	require.Equal(t, 7.0, waiting)
+
+	waiting, err = scrapeEngineRequestsWaiting(context.Background(), []string{engine1, starting, engine2})
+	require.NoError(t, err, "engines that could not be scraped should be skipped")
+	require.Equal(t, 7.0, waiting)
+
+	_, err = scrapeEngineRequestsWaiting(context.Background(), []string{starting})
+	require.Error(t, err)
+}
+
+func TestCounterIncreases(t *testing.T) {
+	prev := map[counterKey]float64{
+		{addr: "a", metric: counterTokens, model: "m1"}: 100,
+		{addr: "b", metric: counterTokens, model: "m1"}: 500,
+	}
+	next := map[counterKey]float64{
+		{addr: "a", metric: counterTokens, model: "m1"}: 150,
+		// Counter reset (i.e. KubeAI restarted).
+		{addr: "b", metric: counterTokens, model: "m1"}: 20,
+		// Not scraped before.
+		{addr: "c", metric: counterTokens, model: "m1"}: 1000,
+	}
+	require.Equal(t, map[counterKey]float64{
+		{metric: counterTokens, model: "m1"}: 70,
+	}, counterIncreases(prev, next))
+}
+
+func TestScalingMetricReplicas(t *testing.T) {
+	cases := map[string]struct {
+		metric  v1.ScalingMetric
+		avg     float64
+		current int32
+		exp     float64
+	}{
+		"tokens per second": {
+			metric:  v1.ScalingMetric{Type: v1.TokensPerSecondScalingMetric, Target: 1000},
+			avg:     2500,
+			current: 1,
+			exp:     3,
+		},
+		"vllm requests waiting": {
+			metric:  v1.ScalingMetric{Type: v1.VLLMRequestsWaitingScalingMetric, Target: 10},
+			avg:     0,
+			current: 4,
+			exp:     0,
+		},
+		"queue wait scales current replicas": {
+			metric:  v1.ScalingMetric{Type: v1.QueueWaitMillisecondsScalingMetric, Target: 500},
+			avg:     1200,
+			current: 2,
+			exp:     5,
+		},
+		"queue wait without replicas": {
+			metric:  v1.ScalingMetric{Type: v1.QueueWaitMillisecondsScalingMetric, Target: 500},
+			avg:     1200,
+			current: 0,
+			exp:     0,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.exp, scalingMetricReplicas(c.metric, c.avg, c.current))
+		})
+	}
+}
@@ -7,6 +7,9 @@ import (
 	"log"
 	"time"
 
+	kubeaiv1 "github.com/kubeai-project/kubeai/api/k8s/v1"
+	"github.com/kubeai-project/kubeai/internal/movingaverage"
+
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -27,6 +30,13 @@ type totalModelState struct {
 
 type modelState struct {
 	AverageActiveRequests float64 `json:"averageActiveRequests"`
+	// AverageScalingMetrics are the averages of the scaling metrics of the model.
+	AverageScalingMetrics map[kubeaiv1.ScalingMetricType]float64 `json:"averageScalingMetrics,omitempty"`
+	// ActiveRequests and ScalingMetrics are the states that the moving
+	// averages are restored from. The averages above are used instead if
+	// the moving average algorithm of the model changed.
+	ActiveRequests *movingaverage.State                               `json:"activeRequests,omitempty"`
+	ScalingMetrics map[kubeaiv1.ScalingMetricType]movingaverage.State `json:"scalingMetrics,omitempty"`
 }
 
 func (a *Autoscaler) loadLastTotalModelState(ctx context.Context) (totalModelState, error) {
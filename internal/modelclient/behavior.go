@@ -0,0 +1,138 @@
+package modelclient
+
+import (
+	"math"
+	"time"
+
+	kubeaiv1 "github.com/kubeai-project/kubeai/api/k8s/v1"
+)
+
+// maxPolicyPeriod is the maximum PeriodSeconds of a ScalingPolicy.
+const maxPolicyPeriod = 1800 * time.Second
+
+// scalingHistory is the history of a Model that its ScalingBehavior is
+// applied to.
+type scalingHistory struct {
+	// recommendations are the desired replicas (within the replica bounds)
+	// during the scale up stabilization window.
+	recommendations []recommendation
+	// events are the changes of the replicas during the longest policy period.
+	events []scaleEvent
+}
+
+type recommendation struct {
+	time     time.Time
+	replicas int32
+}
+
+type scaleEvent struct {
+	time   time.Time
+	change int32
+}
+
+// applyBehavior records the desired replicas and returns the replicas that
+// the Model can be scaled to from the current replicas.
+func (h *scalingHistory) applyBehavior(b *kubeaiv1.ScalingBehavior, current, desired int32, now time.Time) int32 {
+	var window time.Duration
+	if b != nil && b.ScaleUp != nil {
+		window = time.Duration(b.ScaleUp.StabilizationWindowSeconds) * time.Second
+	}
+	h.recommendations = append(pruneRecommendations(h.recommendations, now.Add(-window)), recommendation{time: now, replicas: desired})
+	h.events = pruneScaleEvents(h.events, now.Add(-maxPolicyPeriod))
+
+	if b == nil {
+		return desired
+	}
+	switch {
+	case desired > current && b.ScaleUp != nil:
+		for _, r := range h.recommendations {
+			desired = min(desired, r.replicas)
+		}
+		desired = max(desired, current)
+		if len(b.ScaleUp.Policies) > 0 {
+			desired = min(desired, scaleUpLimit(b.ScaleUp.Policies, current, h.events, now))
+		}
+	case desired < current && b.ScaleDown != nil:
+		if len(b.ScaleDown.Policies) > 0 {
+			desired = max(desired, scaleDownLimit(b.ScaleDown.Policies, current, h.events, now))
+		}
+	}
+	return desired
+}
+
+func (h *scalingHistory) recordScale(change int32, now time.Time) {
+	h.events = append(h.events, scaleEvent{time: now, change: change})
+}
+
+// scaleUpLimit returns the highest number of replicas that the policies allow.
+func scaleUpLimit(policies []kubeaiv1.ScalingPolicy, current int32, events []scaleEvent, now time.Time) int32 {
+	var limit int32
+	for i, p := range policies {
+		added, _ := changesInPeriod(events, p.PeriodSeconds, now)
+		start := current - added
+		var l int32
+		switch p.Type {
+		case kubeaiv1.PercentScalingPolicy:
+			l = max(int32(math.Ceil(float64(start)*(1+float64(p.Value)/100))), start+1)
+		default:
+			l = start + p.Value
+		}
+		if i == 0 || l > limit {
+			limit = l
+		}
+	}
+	return limit
+}
+
+// scaleDownLimit returns the lowest number of replicas that the policies allow.
+func scaleDownLimit(policies []kubeaiv1.ScalingPolicy, current int32, events []scaleEvent, now time.Time) int32 {
+	var limit int32
+	for i, p := range policies {
+		_, removed := changesInPeriod(events, p.PeriodSeconds, now)
+		start := current + removed
+		var l int32
+		switch p.Type {
+		case kubeaiv1.PercentScalingPolicy:
+			l = int32(float64(start) * (1 - float64(p.Value)/100))
+		default:
+			l = start - p.Value
+		}
+		if i == 0 || l < limit {
+			limit = l
+		}
+	}
+	return limit
+}
+
+// changesInPeriod returns the number of replicas that were added and removed
+// during the period before now.
+func changesInPeriod(events []scaleEvent, periodSeconds int32, now time.Time) (added, removed int32) {
+	start := now.Add(-time.Duration(periodSeconds) * time.Second)
+	for _, e := range events {
+		if !e.time.After(start) {
+			continue
+		}
+		if e.change > 0 {
+			added += e.change
+		} else {
+			removed -= e.change
+		}
+	}
+	return added, removed
+}
+
+func pruneRecommendations(rs []recommendation, before time.Time) []recommendation {
+	i := 0
+	for i < len(rs) && rs[i].time.Before(before) {
+		i++
+	}
+	return rs[i:]
+}
+
+func pruneScaleEvents(es []scaleEvent, before time.Time) []scaleEvent {
+	i := 0
+	for i < len(es) && es[i].time.Before(before) {
+		i++
+	}
+	return es[i:]
+}
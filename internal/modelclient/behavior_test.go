@@ -0,0 +1,117 @@
+package modelclient
+
+import (
+	"testing"
+	"time"
+
+	kubeaiv1 "github.com/kubeai-project/kubeai/api/k8s/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyBehavior(t *testing.T) {
+	now := time.Now()
+
+	cases := map[string]struct {
+		behavior        *kubeaiv1.ScalingBehavior
+		recommendations []recommendation
+		events          []scaleEvent
+		current         int32
+		desired         int32
+		exp             int32
+	}{
+		"no behavior": {
+			current: 1,
+			desired: 20,
+			exp:     20,
+		},
+		"stabilization window uses lowest recommendation": {
+			behavior: &kubeaiv1.ScalingBehavior{ScaleUp: &kubeaiv1.ScaleUpBehavior{StabilizationWindowSeconds: 120}},
+			recommendations: []recommendation{
+				{time: now.Add(-3 * time.Minute), replicas: 1},
+				{time: now.Add(-time.Minute), replicas: 4},
+			},
+			current: 2,
+			desired: 20,
+			exp:     4,
+		},
+		"stabilization window does not scale down": {
+			behavior: &kubeaiv1.ScalingBehavior{ScaleUp: &kubeaiv1.ScaleUpBehavior{StabilizationWindowSeconds: 120}},
+			recommendations: []recommendation{
+				{time: now.Add(-time.Minute), replicas: 1},
+			},
+			current: 2,
+			desired: 20,
+			exp:     2,
+		},
+		"scale up replicas policy": {
+			behavior: &kubeaiv1.ScalingBehavior{ScaleUp: &kubeaiv1.ScaleUpBehavior{Policies: []kubeaiv1.ScalingPolicy{
+				{Type: kubeaiv1.ReplicasScalingPolicy, Value: 4, PeriodSeconds: 60},
+			}}},
+			events: []scaleEvent{
+				{time: now.Add(-2 * time.Minute), change: 4},
+				{time: now.Add(-30 * time.Second), change: 3},
+			},
+			current: 8,
+			desired: 20,
+			exp:     9,
+		},
+		"scale up applies most permissive policy": {
+			behavior: &kubeaiv1.ScalingBehavior{ScaleUp: &kubeaiv1.ScaleUpBehavior{Policies: []kubeaiv1.ScalingPolicy{
+				{Type: kubeaiv1.ReplicasScalingPolicy, Value: 2, PeriodSeconds: 60},
+				{Type: kubeaiv1.PercentScalingPolicy, Value: 100, PeriodSeconds: 60},
+			}}},
+			current: 4,
+			desired: 20,
+			exp:     8,
+		},
+		"scale up percent policy from zero": {
+			behavior: &kubeaiv1.ScalingBehavior{ScaleUp: &kubeaiv1.ScaleUpBehavior{Policies: []kubeaiv1.ScalingPolicy{
+				{Type: kubeaiv1.PercentScalingPolicy, Value: 50, PeriodSeconds: 60},
+			}}},
+			current: 0,
+			desired: 20,
+			exp:     1,
+		},
+		"scale down policy": {
+			behavior: &kubeaiv1.ScalingBehavior{ScaleDown: &kubeaiv1.ScaleDownBehavior{Policies: []kubeaiv1.ScalingPolicy{
+				{Type: kubeaiv1.PercentScalingPolicy, Value: 50, PeriodSeconds: 300},
+			}}},
+			events: []scaleEvent{
+				{time: now.Add(-time.Minute), change: -2},
+			},
+			current: 8,
+			desired: 0,
+			exp:     5,
+		},
+		"scale down percent policy to zero": {
+			behavior: &kubeaiv1.ScalingBehavior{ScaleDown: &kubeaiv1.ScaleDownBehavior{Policies: []kubeaiv1.ScalingPolicy{
+				{Type: kubeaiv1.PercentScalingPolicy, Value: 50, PeriodSeconds: 300},
+			}}},
+			current: 1,
+			desired: 0,
+			exp:     0,
+		},
+		"scale down percent policy rounds down": {
+			behavior: &kubeaiv1.ScalingBehavior{ScaleDown: &kubeaiv1.ScaleDownBehavior{Policies: []kubeaiv1.ScalingPolicy{
+				{Type: kubeaiv1.PercentScalingPolicy, Value: 10, PeriodSeconds: 300},
+			}}},
+			current: 5,
+			desired: 0,
+			exp:     4,
+		},
+		"scale down not limited by scale up behavior": {
+			behavior: &kubeaiv1.ScalingBehavior{ScaleUp: &kubeaiv1.ScaleUpBehavior{Policies: []kubeaiv1.ScalingPolicy{
+				{Type: kubeaiv1.ReplicasScalingPolicy, Value: 1, PeriodSeconds: 60},
+			}}},
+			current: 8,
+			desired: 0,
+			exp:     0,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			h := &scalingHistory{recommendations: c.recommendations, events: c.events}
+			require.Equal(t, c.exp, h.applyBehavior(c.behavior, c.current, c.desired, now))
+		})
+	}
+}
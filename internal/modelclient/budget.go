@@ -0,0 +1,55 @@
+package modelclient
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	kubeaiv1 "github.com/kubeai-project/kubeai/api/k8s/v1"
+	"github.com/kubeai-project/kubeai/internal/config"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// BudgetedProfile returns the name of the resource profile of the Model and
+// the number of units per replica if the profile has a budget.
+func BudgetedProfile(m *kubeaiv1.Model, profiles map[string]config.ResourceProfile) (string, int64, bool) {
+	name, count, ok := strings.Cut(m.Spec.ResourceProfile, ":")
+	if !ok {
+		return "", 0, false
+	}
+	if profiles[name].Budget <= 0 {
+		return "", 0, false
+	}
+	units, err := strconv.ParseInt(count, 10, 64)
+	if err != nil || units < 1 {
+		log.Printf("Invalid resource profile %q of model %q, ignoring budget", m.Spec.ResourceProfile, m.Name)
+		return "", 0, false
+	}
+	return name, units, true
+}
+
+// budgetAllowsReplica reports whether the budget of the resource profile of
+// the Model has room for another replica of the Model given the current
+// replicas of all Models.
+func (c *ModelClient) budgetAllowsReplica(ctx context.Context, model *kubeaiv1.Model) (bool, error) {
+	profile, units, ok := BudgetedProfile(model, c.resourceProfiles)
+	if !ok {
+		return true, nil
+	}
+
+	var models kubeaiv1.ModelList
+	if err := c.client.List(ctx, &models, client.InNamespace(c.namespace)); err != nil {
+		return false, fmt.Errorf("list models: %w", err)
+	}
+	var used int64
+	for _, m := range models.Items {
+		p, u, ok := BudgetedProfile(&m, c.resourceProfiles)
+		if !ok || p != profile || m.Spec.Replicas == nil {
+			continue
+		}
+		used += int64(*m.Spec.Replicas) * u
+	}
+	return used+units <= c.resourceProfiles[profile].Budget, nil
+}
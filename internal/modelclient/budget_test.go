@@ -0,0 +1,46 @@
+package modelclient
+
+import (
+	"context"
+	"testing"
+
+	kubeaiv1 "github.com/kubeai-project/kubeai/api/k8s/v1"
+	"github.com/kubeai-project/kubeai/internal/config"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestBudgetAllowsReplica(t *testing.T) {
+	ctx := context.Background()
+	testModel := func(name, profile string, replicas int32) *kubeaiv1.Model {
+		return &kubeaiv1.Model{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       kubeaiv1.ModelSpec{ResourceProfile: profile, Replicas: ptr.To(replicas)},
+		}
+	}
+	scheme := runtime.NewScheme()
+	require.NoError(t, kubeaiv1.AddToScheme(scheme))
+	c := NewModelClient(fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		testModel("a", "gpu:2", 2),
+		testModel("b", "gpu:1", 1),
+		testModel("other", "cpu:1", 10),
+	).Build(), "default", map[string]config.ResourceProfile{
+		"gpu": {Budget: 7},
+		"cpu": {},
+	})
+
+	ok, err := c.budgetAllowsReplica(ctx, testModel("c", "gpu:2", 0))
+	require.NoError(t, err)
+	require.True(t, ok, "5 of 7 units are used")
+
+	ok, err = c.budgetAllowsReplica(ctx, testModel("c", "gpu:4", 0))
+	require.NoError(t, err)
+	require.False(t, ok, "5 of 7 units are used")
+
+	ok, err = c.budgetAllowsReplica(ctx, testModel("c", "cpu:4", 0))
+	require.NoError(t, err)
+	require.True(t, ok, "the profile has no budget")
+}
@@ -6,6 +6,7 @@ import (
 	"sync"
 
 	kubeaiv1 "github.com/kubeai-project/kubeai/api/k8s/v1"
+	"github.com/kubeai-project/kubeai/internal/config"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
@@ -15,12 +16,15 @@ import (
 type ModelClient struct {
 	client                   client.Client
 	namespace                string
+	resourceProfiles         map[string]config.ResourceProfile
 	consecutiveScaleDownsMtx sync.RWMutex
 	consecutiveScaleDowns    map[string]int
+	scalingHistoriesMtx      sync.Mutex
+	scalingHistories         map[string]*scalingHistory
 }
 
-func NewModelClient(client client.Client, namespace string) *ModelClient {
-	return &ModelClient{client: client, namespace: namespace, consecutiveScaleDowns: map[string]int{}}
+func NewModelClient(client client.Client, namespace string, resourceProfiles map[string]config.ResourceProfile) *ModelClient {
+	return &ModelClient{client: client, namespace: namespace, resourceProfiles: resourceProfiles, consecutiveScaleDowns: map[string]int{}, scalingHistories: map[string]*scalingHistory{}}
 }
 
 // LookupModel checks if a model exists and matches the given label selectors.
@@ -63,6 +67,33 @@ func (c *ModelClient) LookupModel(ctx context.Context, model, adapter string, la
 	return m, nil
 }
 
+// LookupModelAlias checks if a model alias exists and matches the given label selectors.
+func (c *ModelClient) LookupModelAlias(ctx context.Context, name string, labelSelectors []string) (*kubeaiv1.ModelAlias, error) {
+	a := &kubeaiv1.ModelAlias{}
+	if err := c.client.Get(ctx, types.NamespacedName{Name: name, Namespace: c.namespace}, a); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	aliasLabels := a.GetLabels()
+	if aliasLabels == nil {
+		aliasLabels = map[string]string{}
+	}
+	for _, sel := range labelSelectors {
+		parsedSel, err := labels.Parse(sel)
+		if err != nil {
+			return nil, fmt.Errorf("parse label selector: %w", err)
+		}
+		if !parsedSel.Matches(labels.Set(aliasLabels)) {
+			return nil, nil
+		}
+	}
+
+	return a, nil
+}
+
 func (s *ModelClient) ListAllModels(ctx context.Context) ([]kubeaiv1.Model, error) {
 	models := &kubeaiv1.ModelList{}
 	if err := s.client.List(ctx, models, client.InNamespace(s.namespace)); err != nil {
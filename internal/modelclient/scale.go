@@ -4,20 +4,34 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"time"
 
 	kubeaiv1 "github.com/kubeai-project/kubeai/api/k8s/v1"
+	"github.com/kubeai-project/kubeai/internal/schedule"
 	autoscalingv1 "k8s.io/api/autoscaling/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// ScheduledToZeroError is returned by ScaleAtLeastOneReplica while a schedule
+// window keeps the Model at zero replicas.
+type ScheduledToZeroError struct {
+	Model string
+	// Until is the time at which the Model can be scaled up again.
+	Until time.Time
+}
+
+func (e *ScheduledToZeroError) Error() string {
+	return fmt.Sprintf("model %q is scheduled to have 0 replicas", e.Model)
+}
+
 func (c *ModelClient) ScaleAtLeastOneReplica(ctx context.Context, model string) error {
 	obj := &kubeaiv1.Model{}
 	if err := c.client.Get(ctx, types.NamespacedName{Namespace: c.namespace, Name: model}, obj); err != nil {
 		return fmt.Errorf("get scale: %w", err)
 	}
 
-	if obj.Spec.AutoscalingDisabled {
+	if obj.Spec.AutoscalingDisabled || obj.Spec.AutoscalingMode == kubeaiv1.RecommendAutoscalingMode {
 		return nil
 	}
 
@@ -26,7 +40,23 @@ func (c *ModelClient) ScaleAtLeastOneReplica(ctx context.Context, model string)
 		replicas = *obj.Spec.Replicas
 	}
 
+	if until, ok := schedule.ScaledToZeroUntil(obj, time.Now()); ok {
+		log.Printf("model %s is scheduled to have 0 replicas, not scaling up", model)
+		return &ScheduledToZeroError{Model: model, Until: until}
+	}
+
 	if replicas == 0 && !obj.Spec.AutoscalingDisabled {
+		// Without room in the budget, the replica is left to be allocated
+		// by the autoscaler (by the priority of the Models) while the
+		// request waits.
+		ok, err := c.budgetAllowsReplica(ctx, obj)
+		if err != nil {
+			return fmt.Errorf("check budget: %w", err)
+		}
+		if !ok {
+			log.Printf("budget of resource profile of model %s is exhausted, not scaling up", model)
+			return nil
+		}
 		scale := &autoscalingv1.Scale{
 			Spec: autoscalingv1.ScaleSpec{Replicas: 1},
 		}
@@ -38,21 +68,75 @@ func (c *ModelClient) ScaleAtLeastOneReplica(ctx context.Context, model string)
 	return nil
 }
 
+// ScaleResult is the outcome of Scale() or Recommend().
+type ScaleResult struct {
+	// DesiredReplicas is the requested number of replicas within the replica bounds.
+	DesiredReplicas int32
+	// PreviousReplicas is the number of replicas before scaling.
+	PreviousReplicas int32
+	// Replicas is the number of replicas after scaling.
+	Replicas int32
+	// ConsecutiveScaleDowns is the number of consecutive scale downs that
+	// were delayed (0 unless a scale down is pending).
+	ConsecutiveScaleDowns int
+}
+
 // Scale scales the model to the desired number of replicas, enforcing the min and max replica bounds.
 // Model should have .Spec defined before calling Scale().
-func (c *ModelClient) Scale(ctx context.Context, model *kubeaiv1.Model, replicas int32, requiredConsecutiveScaleDowns int) error {
+func (c *ModelClient) Scale(ctx context.Context, model *kubeaiv1.Model, replicas int32, requiredConsecutiveScaleDowns int) (ScaleResult, error) {
 	//obj := &kubeaiv1.Model{}
 	//if err := s.client.Get(ctx, types.NamespacedName{Namespace: s.namespace, Name: model}, obj); err != nil {
 	//	return fmt.Errorf("get scale: %w", err)
 	//}
 
-	replicas = enforceReplicaBounds(replicas, model)
+	now := time.Now()
+	result := c.recommend(model, replicas, requiredConsecutiveScaleDowns, now)
+
+	if result.Replicas != result.PreviousReplicas {
+		log.Printf("scaling model %s from %d to %d replicas", model.Name, result.PreviousReplicas, result.Replicas)
+		scale := &autoscalingv1.Scale{
+			Spec: autoscalingv1.ScaleSpec{Replicas: result.Replicas},
+		}
+		if err := c.client.SubResource("scale").Update(ctx, model, client.WithSubResourceBody(scale)); err != nil {
+			result.Replicas = result.PreviousReplicas
+			return result, fmt.Errorf("update scale: %w", err)
+		}
+		c.scalingHistoriesMtx.Lock()
+		c.scalingHistory(model.Name).recordScale(result.Replicas-result.PreviousReplicas, now)
+		c.scalingHistoriesMtx.Unlock()
+	}
+
+	return result, nil
+}
+
+// Recommend returns the result that Scale() would have without scaling the model.
+// Scale downs are delayed and the ScalingBehavior of the model is applied the same way as in Scale().
+func (c *ModelClient) Recommend(model *kubeaiv1.Model, replicas int32, requiredConsecutiveScaleDowns int) ScaleResult {
+	return c.recommend(model, replicas, requiredConsecutiveScaleDowns, time.Now())
+}
+
+func (c *ModelClient) recommend(model *kubeaiv1.Model, replicas int32, requiredConsecutiveScaleDowns int, now time.Time) ScaleResult {
+	replicas = enforceReplicaBounds(replicas, model, now)
 
 	var existingReplicas int32 = 0
 	if model.Spec.Replicas != nil {
 		existingReplicas = *model.Spec.Replicas
 	}
 
+	result := ScaleResult{
+		DesiredReplicas:  replicas,
+		PreviousReplicas: existingReplicas,
+		Replicas:         existingReplicas,
+	}
+
+	c.scalingHistoriesMtx.Lock()
+	limited := c.scalingHistory(model.Name).applyBehavior(model.Spec.ScalingBehavior, existingReplicas, replicas, now)
+	c.scalingHistoriesMtx.Unlock()
+	limited = enforceReplicaBounds(limited, model, now)
+	if limited != replicas {
+		log.Printf("model %s scaling behavior limits scaling from %d to %d replicas (desired %d)", model.Name, existingReplicas, limited, replicas)
+	}
+
 	if existingReplicas > replicas {
 		// Scale down
 		c.consecutiveScaleDownsMtx.RLock()
@@ -62,8 +146,9 @@ func (c *ModelClient) Scale(ctx context.Context, model *kubeaiv1.Model, replicas
 			log.Printf("model %s has %d consecutive scale downs (< %d), not scaling down yet", model.Name, consec, requiredConsecutiveScaleDowns)
 			c.consecutiveScaleDownsMtx.Lock()
 			c.consecutiveScaleDowns[model.Name]++
+			result.ConsecutiveScaleDowns = c.consecutiveScaleDowns[model.Name]
 			c.consecutiveScaleDownsMtx.Unlock()
-			return nil
+			return result
 		}
 	} else {
 		// Scale up or constant scale.
@@ -72,22 +157,23 @@ func (c *ModelClient) Scale(ctx context.Context, model *kubeaiv1.Model, replicas
 		c.consecutiveScaleDownsMtx.Unlock()
 	}
 
-	if existingReplicas != replicas {
-		log.Printf("scaling model %s from %d to %d replicas", model.Name, existingReplicas, replicas)
-		scale := &autoscalingv1.Scale{
-			Spec: autoscalingv1.ScaleSpec{Replicas: replicas},
-		}
-		if err := c.client.SubResource("scale").Update(ctx, model, client.WithSubResourceBody(scale)); err != nil {
-			return fmt.Errorf("update scale: %w", err)
-		}
-	}
+	result.Replicas = limited
+	return result
+}
 
-	return nil
+// scalingHistory returns the scaling history of the model.
+// The caller should hold scalingHistoriesMtx.
+func (c *ModelClient) scalingHistory(model string) *scalingHistory {
+	h, ok := c.scalingHistories[model]
+	if !ok {
+		h = &scalingHistory{}
+		c.scalingHistories[model] = h
+	}
+	return h
 }
 
-func enforceReplicaBounds(replicas int32, model *kubeaiv1.Model) int32 {
-	max := model.Spec.MaxReplicas
-	min := model.Spec.MinReplicas
+func enforceReplicaBounds(replicas int32, model *kubeaiv1.Model, now time.Time) int32 {
+	min, max := schedule.ReplicaBounds(model, now)
 	if max != nil {
 		if replicas > *max {
 			return *max
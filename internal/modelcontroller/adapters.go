@@ -21,7 +21,9 @@ const (
 // of the adapter URL.
 // At request-time, the endpoint resolver will inspect these labels to determine which adapters
 // are loaded in the pod.
-func (r *ModelReconciler) reconcileAdapters(ctx context.Context, pods []*corev1.Pod, adapters []v1.Adapter) error {
+// In OnDemand mode, adapters are loaded at request-time (see LoadAdapter) and only adapters
+// that were removed from the spec (or whose URL changed) are unloaded here.
+func (r *ModelReconciler) reconcileAdapters(ctx context.Context, pods []*corev1.Pod, adapters []v1.Adapter, mode v1.AdapterLoadingMode) error {
 	type reconcileParam struct {
 		pod         *corev1.Pod
 		toEnsure    []v1.Adapter
@@ -48,11 +50,11 @@ func (r *ModelReconciler) reconcileAdapters(ctx context.Context, pods []*corev1.
 		deletionCandidates := getLabelledAdapters(pod)
 
 		for _, adapter := range adapters {
-			if k8sutils.GetLabel(pod, v1.PodAdapterLabel(adapter.Name)) != k8sutils.StringHash(adapter.URL) {
-				param.toEnsure = append(param.toEnsure, adapter)
-			} else {
+			if k8sutils.GetLabel(pod, v1.PodAdapterLabel(adapter.Name)) == k8sutils.StringHash(adapter.URL) {
 				// Matches, so don't delete.
 				delete(deletionCandidates, adapter.Name)
+			} else if mode != v1.OnDemandAdapterLoadingMode {
+				param.toEnsure = append(param.toEnsure, adapter)
 			}
 		}
 
@@ -64,52 +66,22 @@ func (r *ModelReconciler) reconcileAdapters(ctx context.Context, pods []*corev1.
 	}
 
 	for _, param := range reconcileList {
+		if len(param.toEnsure) == 0 && len(param.toRemoveIDs) == 0 {
+			continue
+		}
 		// TODO: Parallelize
 		addr := getPodModelServerAddr(param.pod)
 		if !k8sutils.ContainerIsReady(param.pod, loaderContainerName) {
 			return errReturnEarly
 		}
 		for _, adapter := range param.toEnsure {
-			if err := r.execAdapterLoad(ctx, param.pod, adapter); err != nil {
-				return fmt.Errorf("exec adapter load for pod %q: %w", param.pod.Namespace+"/"+param.pod.Name, err)
-			}
-			switch param.engine {
-			case v1.VLLMEngine:
-				if err := r.VLLMClient.LoadLoraAdapter(ctx, addr, vllmclient.LoadAdapterRequest{
-					LoraName: adapter.Name,
-					LoraPath: adapterDir(adapter),
-					Options: vllmclient.LoadAdapterRequestOptions{
-						// It is possible that the adapter is already loaded, but updating the Pod labels
-						// failed. In this case, we ignore the error and continue.
-						IgnoreAlreadyLoaded: true,
-					},
-				}); err != nil {
-					return fmt.Errorf("load vllm adapter %q: %w", adapter.Name, err)
-				}
-			}
-			if err := r.updatePodAddLabel(ctx, param.pod, v1.PodAdapterLabel(adapter.Name), k8sutils.StringHash(adapter.URL)); err != nil {
-				return fmt.Errorf("update pod labels for pod %q: %w", param.pod.Namespace+"/"+param.pod.Name, err)
+			if err := r.loadAdapter(ctx, param.pod, param.engine, addr, adapter); err != nil {
+				return err
 			}
 		}
 		for _, adapterID := range param.toRemoveIDs {
-			if err := r.execAdapterUnload(ctx, param.pod, adapterID); err != nil {
-				return fmt.Errorf("exec adapter unload for pod %q: %w", param.pod.Namespace+"/"+param.pod.Name, err)
-			}
-			switch param.engine {
-			case v1.VLLMEngine:
-				if err := r.VLLMClient.UnloadLoraAdapter(ctx, addr, vllmclient.UnloadAdapterRequest{
-					LoraName: adapterID,
-					Options: vllmclient.UnloadAdapterRequestOptions{
-						// It is possible that the adapter is already unloaded, but updating the Pod labels
-						// failed. In this case, we ignore the error and continue.
-						IgnoreNotFound: true,
-					},
-				}); err != nil {
-					return fmt.Errorf("unload vllm adapter %q: %w", adapterID, err)
-				}
-			}
-			if err := r.updatePodRemoveLabel(ctx, param.pod, v1.PodAdapterLabel(adapterID)); err != nil {
-				return fmt.Errorf("update pod labels for pod %q: %w", param.pod.Namespace+"/"+param.pod.Name, err)
+			if err := r.unloadAdapter(ctx, param.pod, param.engine, addr, adapterID); err != nil {
+				return err
 			}
 		}
 	}
@@ -117,6 +89,57 @@ func (r *ModelReconciler) reconcileAdapters(ctx context.Context, pods []*corev1.
 	return nil
 }
 
+// loadAdapter loads the adapter into the model server of the Pod and labels
+// the Pod accordingly.
+func (r *ModelReconciler) loadAdapter(ctx context.Context, pod *corev1.Pod, engine, addr string, adapter v1.Adapter) error {
+	if err := r.execAdapterLoad(ctx, pod, adapter); err != nil {
+		return fmt.Errorf("exec adapter load for pod %q: %w", pod.Namespace+"/"+pod.Name, err)
+	}
+	switch engine {
+	case v1.VLLMEngine:
+		if err := r.VLLMClient.LoadLoraAdapter(ctx, addr, vllmclient.LoadAdapterRequest{
+			LoraName: adapter.Name,
+			LoraPath: adapterDir(adapter),
+			Options: vllmclient.LoadAdapterRequestOptions{
+				// It is possible that the adapter is already loaded, but updating the Pod labels
+				// failed. In this case, we ignore the error and continue.
+				IgnoreAlreadyLoaded: true,
+			},
+		}); err != nil {
+			return fmt.Errorf("load vllm adapter %q: %w", adapter.Name, err)
+		}
+	}
+	if err := r.updatePodAddLabel(ctx, pod, v1.PodAdapterLabel(adapter.Name), k8sutils.StringHash(adapter.URL)); err != nil {
+		return fmt.Errorf("update pod labels for pod %q: %w", pod.Namespace+"/"+pod.Name, err)
+	}
+	return nil
+}
+
+// unloadAdapter unloads the adapter from the model server of the Pod and
+// removes the corresponding Pod label.
+func (r *ModelReconciler) unloadAdapter(ctx context.Context, pod *corev1.Pod, engine, addr string, adapterID string) error {
+	if err := r.execAdapterUnload(ctx, pod, adapterID); err != nil {
+		return fmt.Errorf("exec adapter unload for pod %q: %w", pod.Namespace+"/"+pod.Name, err)
+	}
+	switch engine {
+	case v1.VLLMEngine:
+		if err := r.VLLMClient.UnloadLoraAdapter(ctx, addr, vllmclient.UnloadAdapterRequest{
+			LoraName: adapterID,
+			Options: vllmclient.UnloadAdapterRequestOptions{
+				// It is possible that the adapter is already unloaded, but updating the Pod labels
+				// failed. In this case, we ignore the error and continue.
+				IgnoreNotFound: true,
+			},
+		}); err != nil {
+			return fmt.Errorf("unload vllm adapter %q: %w", adapterID, err)
+		}
+	}
+	if err := r.updatePodRemoveLabel(ctx, pod, v1.PodAdapterLabel(adapterID)); err != nil {
+		return fmt.Errorf("update pod labels for pod %q: %w", pod.Namespace+"/"+pod.Name, err)
+	}
+	return nil
+}
+
 func getPodModelServerAddr(pod *corev1.Pod) string {
 	// Example:
 	//
@@ -0,0 +1,411 @@
+package modelcontroller
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "github.com/kubeai-project/kubeai/api/k8s/v1"
+	"github.com/kubeai-project/kubeai/internal/k8sutils"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// adapterLockTTL bounds how long a Pod stays locked if the KubeAI
+	// replica that holds the adapter lock stops while loading an adapter.
+	adapterLockTTL = 5 * time.Minute
+	// adapterLastUsedResolution is the resolution of the last-used
+	// annotations of adapters. It limits how often Pods are patched for
+	// requests to adapters that are loaded.
+	adapterLastUsedResolution = time.Minute
+)
+
+// adapterUsage tracks when adapters were last requested through this KubeAI
+// replica. The time of the last request through any replica is recorded in
+// the last-used annotations of the Pods (with a lower resolution).
+// The zero value is ready to use.
+type adapterUsage struct {
+	mtx sync.Mutex
+	// lastUsed is keyed by "<model>/<adapter>".
+	lastUsed map[string]time.Time
+	// locks serialize the loading of adapters per Model within this
+	// replica. Loads of different replicas are serialized by the adapter
+	// lock of the Pod.
+	locks map[string]chan struct{}
+}
+
+func (u *adapterUsage) touch(model, adapter string, t time.Time) {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+	if u.lastUsed == nil {
+		u.lastUsed = map[string]time.Time{}
+	}
+	u.lastUsed[model+"/"+adapter] = t
+}
+
+func (u *adapterUsage) get(model, adapter string) time.Time {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+	return u.lastUsed[model+"/"+adapter]
+}
+
+// lock acquires the loading lock of the Model. It returns a function that
+// releases the lock.
+func (u *adapterUsage) lock(ctx context.Context, model string) (func(), error) {
+	u.mtx.Lock()
+	if u.locks == nil {
+		u.locks = map[string]chan struct{}{}
+	}
+	l, ok := u.locks[model]
+	if !ok {
+		l = make(chan struct{}, 1)
+		u.locks[model] = l
+	}
+	u.mtx.Unlock()
+
+	select {
+	case l <- struct{}{}:
+		return func() { <-l }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// lastUsedAt returns when the adapter was last requested through any KubeAI
+// replica (as far as known).
+func (u *adapterUsage) lastUsedAt(pod *corev1.Pod, model, adapter string) time.Time {
+	t := u.get(model, adapter)
+	if v := k8sutils.GetAnnotation(pod, v1.PodAdapterLastUsedAnnotation(adapter)); v != "" {
+		if annotated, err := time.Parse(time.RFC3339, v); err == nil && annotated.After(t) {
+			t = annotated
+		}
+	}
+	return t
+}
+
+// leastRecentlyUsed sorts the IDs of the adapters loaded into the Pod by the
+// time they were last used (oldest first). Adapters without a known last use
+// come first.
+func (u *adapterUsage) leastRecentlyUsed(pod *corev1.Pod, model string, ids []string) {
+	sort.SliceStable(ids, func(i, j int) bool {
+		ti, tj := u.lastUsedAt(pod, model, ids[i]), u.lastUsedAt(pod, model, ids[j])
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return ids[i] < ids[j]
+	})
+}
+
+// LoadAdapter ensures that an adapter of a Model that uses the OnDemand
+// adapter loading mode is loaded into at least one Pod. If no Pod has the
+// adapter loaded, it is loaded into the ready Pod with the fewest adapters,
+// unloading the least recently used adapters of that Pod if it already
+// holds spec.adapterLoading.maxLoadedAdapters adapters. Adapters that serve
+// in-flight requests are not unloaded; LoadAdapter waits for them to
+// complete instead. It waits for a Pod to become ready if there is none.
+// It is called for every request for an adapter (by every KubeAI replica)
+// and does nothing for Models that load adapters eagerly. Loads into the
+// same Pod are serialized across replicas by the adapter lock annotation
+// of the Pod.
+func (r *ModelReconciler) LoadAdapter(ctx context.Context, modelName, adapterName string) error {
+	model := &v1.Model{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: r.Namespace, Name: modelName}, model); err != nil {
+		return fmt.Errorf("get model: %w", err)
+	}
+	if model.Spec.AdapterLoading.Mode != v1.OnDemandAdapterLoadingMode {
+		return nil
+	}
+	var adapter *v1.Adapter
+	for i := range model.Spec.Adapters {
+		if model.Spec.Adapters[i].Name == adapterName {
+			adapter = &model.Spec.Adapters[i]
+			break
+		}
+	}
+	if adapter == nil {
+		return fmt.Errorf("adapter %q not found in model %q", adapterName, modelName)
+	}
+
+	now := time.Now()
+	r.adapterUsage.touch(model.Name, adapter.Name, now)
+
+	// Fast path: the adapter is already loaded.
+	pods, err := r.listAdapterPods(ctx, model)
+	if err != nil {
+		return err
+	}
+	if adapterLoaded(pods, *adapter) {
+		r.recordAdapterUse(ctx, pods, adapter.Name, now)
+		return nil
+	}
+
+	unlock, err := r.adapterUsage.lock(ctx, model.Name)
+	if err != nil {
+		return fmt.Errorf("waiting for adapter loading lock: %w", err)
+	}
+	defer unlock()
+
+	var (
+		pod      *corev1.Pod
+		toUnload []string
+	)
+	if err := wait.PollUntilContextCancel(ctx, time.Second, true, func(ctx context.Context) (bool, error) {
+		pod = nil
+		pods, err := r.listAdapterPods(ctx, model)
+		if err != nil {
+			return false, err
+		}
+		if adapterLoaded(pods, *adapter) {
+			// Loaded while waiting for the lock.
+			return true, nil
+		}
+		candidate := pickAdapterPod(r.unlockedPods(pods, time.Now()))
+		if candidate == nil {
+			return false, nil
+		}
+		locked, err := r.lockPodAdapters(ctx, candidate)
+		if err != nil || !locked {
+			return false, err
+		}
+		// The lock was acquired on the latest version of the Pod.
+		if adapterLoaded([]*corev1.Pod{candidate}, *adapter) {
+			r.unlockPodAdapters(candidate)
+			return true, nil
+		}
+		ids, ok, err := r.adaptersToUnload(ctx, model, candidate)
+		if err != nil || !ok {
+			r.unlockPodAdapters(candidate)
+			return false, err
+		}
+		pod, toUnload = candidate, ids
+		return true, nil
+	}); err != nil {
+		return fmt.Errorf("waiting for a ready pod: %w", err)
+	}
+	if pod == nil {
+		return nil
+	}
+	defer r.unlockPodAdapters(pod)
+
+	addr := getPodModelServerAddr(pod)
+	for _, id := range toUnload {
+		log.Printf("Unloading adapter %q of model %q from pod %q to make room for adapter %q", id, model.Name, pod.Name, adapter.Name)
+		if err := r.unloadAdapter(ctx, pod, v1.VLLMEngine, addr, id); err != nil {
+			return err
+		}
+		if err := r.updatePodRemoveAnnotation(ctx, pod, v1.PodAdapterLastUsedAnnotation(id)); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("Loading adapter %q of model %q into pod %q", adapter.Name, model.Name, pod.Name)
+	if err := r.loadAdapter(ctx, pod, v1.VLLMEngine, addr, *adapter); err != nil {
+		return err
+	}
+	r.recordAdapterUse(ctx, []*corev1.Pod{pod}, adapter.Name, now)
+
+	// Wait for the cache to observe the label so that the next request for
+	// the adapter does not load it again.
+	hash := k8sutils.StringHash(adapter.URL)
+	if err := wait.PollUntilContextTimeout(ctx, 100*time.Millisecond, 10*time.Second, true, func(ctx context.Context) (bool, error) {
+		var p corev1.Pod
+		if err := r.Get(ctx, client.ObjectKeyFromObject(pod), &p); err != nil {
+			return false, client.IgnoreNotFound(err)
+		}
+		return k8sutils.GetLabel(&p, v1.PodAdapterLabel(adapter.Name)) == hash, nil
+	}); err != nil {
+		return fmt.Errorf("waiting for pod %q to be labelled: %w", pod.Name, err)
+	}
+
+	return nil
+}
+
+// adaptersToUnload returns the least recently used adapters that need to be
+// unloaded from the Pod to make room for another adapter. It reports false
+// if there is not enough room because adapters have in-flight requests.
+func (r *ModelReconciler) adaptersToUnload(ctx context.Context, model *v1.Model, pod *corev1.Pod) ([]string, bool, error) {
+	maxAdapters := max(1, model.Spec.AdapterLoading.MaxLoadedAdapters)
+	loaded := make([]string, 0, len(pod.Labels))
+	for id := range getLabelledAdapters(pod) {
+		loaded = append(loaded, id)
+	}
+	n := len(loaded) - maxAdapters + 1
+	if n <= 0 {
+		return nil, true, nil
+	}
+	r.adapterUsage.leastRecentlyUsed(pod, model.Name, loaded)
+
+	var ids []string
+	for _, id := range loaded {
+		if len(ids) == n {
+			break
+		}
+		if r.InFlightCounter != nil {
+			inFlight, err := r.InFlightCounter.InFlightRequests(ctx, model.Name, pod.Namespace+"/"+pod.Name, id)
+			if err != nil {
+				log.Printf("Unable to count in-flight requests of adapter %q of model %q in pod %q, keeping it loaded: %v", id, model.Name, pod.Name, err)
+				continue
+			}
+			if inFlight > 0 {
+				continue
+			}
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) < n {
+		log.Printf("Waiting for in-flight requests of the adapters of model %q in pod %q to complete", model.Name, pod.Name)
+		return nil, false, nil
+	}
+	return ids, true, nil
+}
+
+// unlockedPods returns the Pods that are not locked by another KubeAI replica.
+func (r *ModelReconciler) unlockedPods(pods []*corev1.Pod, now time.Time) []*corev1.Pod {
+	var unlocked []*corev1.Pod
+	for _, pod := range pods {
+		if !r.lockedByOther(pod, now) {
+			unlocked = append(unlocked, pod)
+		}
+	}
+	return unlocked
+}
+
+// lockedByOther reports whether another KubeAI replica holds the unexpired
+// adapter lock of the Pod.
+func (r *ModelReconciler) lockedByOther(pod *corev1.Pod, now time.Time) bool {
+	holder, expiry, ok := parseAdapterLock(k8sutils.GetAnnotation(pod, v1.ModelPodAdapterLockAnnotation))
+	return ok && holder != r.Hostname && now.Before(expiry)
+}
+
+// lockPodAdapters acquires the adapter lock of the Pod for this KubeAI
+// replica. The Pod is updated with optimistic concurrency, so it reports
+// false if the Pod changed since it was read (i.e. because another replica
+// acquired the lock). The Pod is updated in place.
+func (r *ModelReconciler) lockPodAdapters(ctx context.Context, pod *corev1.Pod) (bool, error) {
+	if r.lockedByOther(pod, time.Now()) {
+		return false, nil
+	}
+	k8sutils.SetAnnotation(pod, v1.ModelPodAdapterLockAnnotation,
+		r.Hostname+"/"+time.Now().Add(adapterLockTTL).UTC().Format(time.RFC3339))
+	if err := r.Update(ctx, pod); err != nil {
+		if apierrors.IsConflict(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("locking pod %q: %w", pod.Name, err)
+	}
+	return true, nil
+}
+
+// unlockPodAdapters releases the adapter lock of the Pod if it is held by
+// this KubeAI replica. Errors are logged because the lock expires anyway.
+func (r *ModelReconciler) unlockPodAdapters(pod *corev1.Pod) {
+	// The lock is released even if the request was cancelled.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var p corev1.Pod
+		if err := r.Get(ctx, client.ObjectKeyFromObject(pod), &p); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		holder, _, ok := parseAdapterLock(k8sutils.GetAnnotation(&p, v1.ModelPodAdapterLockAnnotation))
+		if !ok || holder != r.Hostname {
+			return nil
+		}
+		base := client.MergeFromWithOptions(p.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		delete(p.Annotations, v1.ModelPodAdapterLockAnnotation)
+		return r.Patch(ctx, &p, base)
+	}); err != nil {
+		log.Printf("Unable to release adapter lock of pod %q: %v", pod.Name, err)
+	}
+}
+
+func parseAdapterLock(val string) (string, time.Time, bool) {
+	holder, ts, ok := strings.Cut(val, "/")
+	if !ok {
+		return "", time.Time{}, false
+	}
+	expiry, err := time.Parse(time.RFC3339, ts)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return holder, expiry, true
+}
+
+// recordAdapterUse updates the last-used annotation of the adapter on the
+// Pods that have it loaded if it is older than adapterLastUsedResolution.
+// Errors are logged because they only affect the order of unloading.
+func (r *ModelReconciler) recordAdapterUse(ctx context.Context, pods []*corev1.Pod, adapter string, t time.Time) {
+	key := v1.PodAdapterLastUsedAnnotation(adapter)
+	for _, pod := range pods {
+		if _, ok := getLabelledAdapters(pod)[adapter]; !ok {
+			continue
+		}
+		if v := k8sutils.GetAnnotation(pod, key); v != "" {
+			if last, err := time.Parse(time.RFC3339, v); err == nil && t.Sub(last) < adapterLastUsedResolution {
+				continue
+			}
+		}
+		base := client.MergeFrom(pod.DeepCopy())
+		k8sutils.SetAnnotation(pod, key, t.UTC().Format(time.RFC3339))
+		if err := r.Patch(ctx, pod, base); err != nil {
+			log.Printf("Unable to record use of adapter %q in pod %q: %v", adapter, pod.Name, err)
+		}
+	}
+}
+
+// listAdapterPods lists the Pods of the Model that adapters can be loaded into.
+func (r *ModelReconciler) listAdapterPods(ctx context.Context, model *v1.Model) ([]*corev1.Pod, error) {
+	var podList corev1.PodList
+	if err := r.List(ctx, &podList, client.InNamespace(model.Namespace), client.MatchingLabels{
+		v1.PodModelLabel: model.Name,
+	}); err != nil {
+		return nil, fmt.Errorf("listing pods: %w", err)
+	}
+	var pods []*corev1.Pod
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.DeletionTimestamp != nil ||
+			k8sutils.GetLabel(pod, appKubernetesIOName) != strings.ToLower(v1.VLLMEngine) ||
+			!k8sutils.PodIsReady(pod) ||
+			!k8sutils.ContainerIsReady(pod, loaderContainerName) {
+			continue
+		}
+		pods = append(pods, pod)
+	}
+	return pods, nil
+}
+
+func adapterLoaded(pods []*corev1.Pod, adapter v1.Adapter) bool {
+	hash := k8sutils.StringHash(adapter.URL)
+	for _, pod := range pods {
+		if k8sutils.GetLabel(pod, v1.PodAdapterLabel(adapter.Name)) == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// pickAdapterPod returns the Pod with the fewest loaded adapters or nil if
+// there are no Pods.
+func pickAdapterPod(pods []*corev1.Pod) *corev1.Pod {
+	var (
+		best      *corev1.Pod
+		bestCount int
+	)
+	for _, pod := range pods {
+		n := len(getLabelledAdapters(pod))
+		if best == nil || n < bestCount || (n == bestCount && pod.Name < best.Name) {
+			best, bestCount = pod, n
+		}
+	}
+	return best
+}
@@ -0,0 +1,179 @@
+package modelcontroller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/kubeai-project/kubeai/api/k8s/v1"
+	"github.com/kubeai-project/kubeai/internal/k8sutils"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestLoadAdapterAlreadyLoaded(t *testing.T) {
+	const ns = "default"
+	adapter := v1.Adapter{Name: "my-adapter", URL: "hf://my/adapter"}
+	model := &v1.Model{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-model", Namespace: ns},
+		Spec: v1.ModelSpec{
+			Adapters: []v1.Adapter{adapter},
+			AdapterLoading: v1.AdapterLoading{
+				Mode:              v1.OnDemandAdapterLoadingMode,
+				MaxLoadedAdapters: 2,
+			},
+		},
+	}
+	pod := testAdapterPod("pod1", map[string]string{
+		v1.PodModelLabel:                 model.Name,
+		v1.PodAdapterLabel(adapter.Name): k8sutils.StringHash(adapter.URL),
+	})
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1.AddToScheme(scheme))
+	r := &ModelReconciler{
+		Client:    fake.NewClientBuilder().WithScheme(scheme).WithObjects(model, pod).Build(),
+		Namespace: ns,
+	}
+
+	// The adapter is already loaded so no Pod is exec'd into (which
+	// would panic without a REST client).
+	require.NoError(t, r.LoadAdapter(context.Background(), model.Name, adapter.Name))
+	require.False(t, r.adapterUsage.get(model.Name, adapter.Name).IsZero(), "usage should be recorded")
+	var got corev1.Pod
+	require.NoError(t, r.Get(context.Background(), client.ObjectKeyFromObject(pod), &got))
+	require.NotEmpty(t, k8sutils.GetAnnotation(&got, v1.PodAdapterLastUsedAnnotation(adapter.Name)), "usage should be shared with other replicas")
+
+	require.ErrorContains(t, r.LoadAdapter(context.Background(), model.Name, "other"), "not found")
+}
+
+func TestPickAdapterPod(t *testing.T) {
+	require.Nil(t, pickAdapterPod(nil))
+
+	pods := []*corev1.Pod{
+		testAdapterPod("pod1", map[string]string{v1.PodAdapterLabel("a"): "x", v1.PodAdapterLabel("b"): "x"}),
+		testAdapterPod("pod3", map[string]string{v1.PodAdapterLabel("c"): "x"}),
+		testAdapterPod("pod2", map[string]string{v1.PodAdapterLabel("d"): "x"}),
+	}
+	require.Equal(t, "pod2", pickAdapterPod(pods).Name, "pod with fewest adapters (ties broken by name)")
+}
+
+func TestAdapterUsageLeastRecentlyUsed(t *testing.T) {
+	var u adapterUsage
+	now := time.Now()
+	u.touch("m", "a", now)
+	u.touch("m", "b", now.Add(-time.Minute))
+	u.touch("other", "c", now.Add(time.Minute))
+	pod := testAdapterPod("pod1", map[string]string{})
+	// Used through another replica.
+	pod.Annotations = map[string]string{
+		v1.PodAdapterLastUsedAnnotation("e"): now.Add(-30 * time.Second).UTC().Format(time.RFC3339),
+	}
+
+	ids := []string{"a", "b", "c", "d", "e"}
+	u.leastRecentlyUsed(pod, "m", ids)
+	require.Equal(t, []string{"c", "d", "b", "e", "a"}, ids, "unknown adapters should be unloaded first")
+}
+
+func TestLockPodAdapters(t *testing.T) {
+	ctx := context.Background()
+	pod := testAdapterPod("pod1", map[string]string{})
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod).Build()
+	r1 := &ModelReconciler{Client: c, Hostname: "kubeai-1"}
+	r2 := &ModelReconciler{Client: c, Hostname: "kubeai-2"}
+
+	var pod1, pod2 corev1.Pod
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(pod), &pod1))
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(pod), &pod2))
+
+	locked, err := r1.lockPodAdapters(ctx, &pod1)
+	require.NoError(t, err)
+	require.True(t, locked)
+	locked, err = r2.lockPodAdapters(ctx, &pod2)
+	require.NoError(t, err)
+	require.False(t, locked, "the lock should be acquired on the latest version of the Pod")
+
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(pod), &pod2))
+	require.True(t, r2.lockedByOther(&pod2, time.Now()))
+	require.Empty(t, r2.unlockedPods([]*corev1.Pod{&pod2}, time.Now()))
+	require.False(t, r2.lockedByOther(&pod2, time.Now().Add(adapterLockTTL+time.Second)), "locks should expire")
+	locked, err = r2.lockPodAdapters(ctx, &pod2)
+	require.NoError(t, err)
+	require.False(t, locked)
+
+	// Only the holder releases the lock.
+	r2.unlockPodAdapters(&pod2)
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(pod), &pod2))
+	require.NotEmpty(t, k8sutils.GetAnnotation(&pod2, v1.ModelPodAdapterLockAnnotation))
+	r1.unlockPodAdapters(&pod1)
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(pod), &pod2))
+	require.Empty(t, k8sutils.GetAnnotation(&pod2, v1.ModelPodAdapterLockAnnotation))
+
+	locked, err = r2.lockPodAdapters(ctx, &pod2)
+	require.NoError(t, err)
+	require.True(t, locked)
+}
+
+func TestAdaptersToUnload(t *testing.T) {
+	ctx := context.Background()
+	model := &v1.Model{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-model", Namespace: "default"},
+		Spec: v1.ModelSpec{
+			AdapterLoading: v1.AdapterLoading{Mode: v1.OnDemandAdapterLoadingMode, MaxLoadedAdapters: 2},
+		},
+	}
+	pod := testAdapterPod("pod1", map[string]string{
+		v1.PodAdapterLabel("a"): "x",
+		v1.PodAdapterLabel("b"): "x",
+	})
+	inFlight := testInFlightCounter{}
+	r := &ModelReconciler{InFlightCounter: inFlight}
+	now := time.Now()
+	r.adapterUsage.touch(model.Name, "a", now.Add(-time.Minute))
+	r.adapterUsage.touch(model.Name, "b", now)
+
+	ids, ok, err := r.adaptersToUnload(ctx, model, pod)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, []string{"a"}, ids, "the least recently used adapter should be unloaded")
+
+	inFlight["my-model:default/pod1:a"] = 1
+	ids, ok, err = r.adaptersToUnload(ctx, model, pod)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, []string{"b"}, ids, "adapters with in-flight requests should be kept")
+
+	inFlight["my-model:default/pod1:b"] = 1
+	_, ok, err = r.adaptersToUnload(ctx, model, pod)
+	require.NoError(t, err)
+	require.False(t, ok, "no adapter can be unloaded")
+
+	model.Spec.AdapterLoading.MaxLoadedAdapters = 3
+	ids, ok, err = r.adaptersToUnload(ctx, model, pod)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Empty(t, ids)
+}
+
+func testAdapterPod(name string, labels map[string]string) *corev1.Pod {
+	labels[appKubernetesIOName] = strings.ToLower(v1.VLLMEngine)
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels},
+		Status: corev1.PodStatus{
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: serverContainerName, Ready: true},
+				{Name: loaderContainerName, Ready: true},
+			},
+		},
+	}
+}
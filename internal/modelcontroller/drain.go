@@ -0,0 +1,75 @@
+package modelcontroller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	kubeaiv1 "github.com/kubeai-project/kubeai/api/k8s/v1"
+	"github.com/kubeai-project/kubeai/internal/k8sutils"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// drainPollInterval is the interval at which draining Pods are checked
+// for in-flight requests.
+const drainPollInterval = 2 * time.Second
+
+// InFlightRequestCounter counts the requests that all KubeAI replicas have
+// in flight to the Pods of a Model. If adapter is not empty, only the requests
+// for that adapter are counted.
+type InFlightRequestCounter interface {
+	InFlightRequests(ctx context.Context, model, pod, adapter string) (int64, error)
+}
+
+// drainPod reports whether the Pod can be deleted. Ready Pods are marked as
+// draining so that the load balancer stops sending new requests to them and
+// are deleted once their in-flight requests completed or the drain timeout
+// expired.
+func (r *ModelReconciler) drainPod(ctx context.Context, pod *corev1.Pod) (bool, error) {
+	timeout := r.ModelDraining.Timeout.Duration
+	if timeout <= 0 || r.InFlightCounter == nil {
+		return true, nil
+	}
+
+	val := k8sutils.GetAnnotation(pod, kubeaiv1.ModelPodDrainingAnnotation)
+	if val == "" {
+		if !k8sutils.PodIsReady(pod) {
+			// The Pod does not serve any requests.
+			return true, nil
+		}
+		base := client.MergeFrom(pod.DeepCopy())
+		k8sutils.SetAnnotation(pod, kubeaiv1.ModelPodDrainingAnnotation, time.Now().UTC().Format(time.RFC3339))
+		if err := r.Client.Patch(ctx, pod, base); err != nil {
+			return false, fmt.Errorf("annotating pod: %w", err)
+		}
+		// Give the load balancer time to observe the annotation before
+		// checking for in-flight requests.
+		return false, nil
+	}
+
+	log := log.FromContext(ctx)
+	start, err := time.Parse(time.RFC3339, val)
+	if err != nil {
+		log.Info("Invalid draining annotation, deleting Pod", "podName", pod.Name, "value", val)
+		return true, nil
+	}
+	if time.Since(start) >= timeout {
+		log.Info("Pod drain timed out", "podName", pod.Name, "timeout", timeout)
+		return true, nil
+	}
+	modelName := k8sutils.GetLabel(pod, kubeaiv1.PodModelLabel)
+	n, err := r.InFlightCounter.InFlightRequests(ctx, modelName, pod.Namespace+"/"+pod.Name, "")
+	if err != nil {
+		// Keep draining until the timeout rather than dropping requests
+		// of replicas that could not be reached.
+		log.Error(err, "Unable to count in-flight requests of draining Pod", "podName", pod.Name)
+		return false, nil
+	}
+	if n > 0 {
+		log.Info("Waiting for in-flight requests of draining Pod", "podName", pod.Name, "inFlight", n)
+		return false, nil
+	}
+	return true, nil
+}
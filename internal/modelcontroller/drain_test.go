@@ -0,0 +1,88 @@
+package modelcontroller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "github.com/kubeai-project/kubeai/api/k8s/v1"
+	"github.com/kubeai-project/kubeai/internal/config"
+	"github.com/kubeai-project/kubeai/internal/k8sutils"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type testInFlightCounter map[string]int64
+
+func (c testInFlightCounter) InFlightRequests(_ context.Context, model, pod, adapter string) (int64, error) {
+	if adapter != "" {
+		return c[model+":"+pod+":"+adapter], nil
+	}
+	return c[model+":"+pod], nil
+}
+
+func TestDrainPod(t *testing.T) {
+	ctx := context.Background()
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod1",
+			Namespace: "default",
+			Labels:    map[string]string{v1.PodModelLabel: "my-model"},
+		},
+		Status: corev1.PodStatus{
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+		},
+	}
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	inFlight := testInFlightCounter{"my-model:default/pod1": 2}
+	r := &ModelReconciler{
+		Client:          fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod).Build(),
+		ModelDraining:   config.ModelDraining{Timeout: config.Duration{Duration: time.Minute}},
+		InFlightCounter: inFlight,
+	}
+
+	// The Pod is marked as draining first.
+	drained, err := r.drainPod(ctx, pod)
+	require.NoError(t, err)
+	require.False(t, drained)
+	var got corev1.Pod
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(pod), &got))
+	require.NotEmpty(t, k8sutils.GetAnnotation(&got, v1.ModelPodDrainingAnnotation))
+
+	// Requests are still in flight.
+	drained, err = r.drainPod(ctx, &got)
+	require.NoError(t, err)
+	require.False(t, drained)
+
+	inFlight["my-model:default/pod1"] = 0
+	drained, err = r.drainPod(ctx, &got)
+	require.NoError(t, err)
+	require.True(t, drained)
+
+	// The drain timeout expired.
+	inFlight["my-model:default/pod1"] = 1
+	got.Annotations[v1.ModelPodDrainingAnnotation] = time.Now().Add(-2 * time.Minute).Format(time.RFC3339)
+	drained, err = r.drainPod(ctx, &got)
+	require.NoError(t, err)
+	require.True(t, drained)
+
+	// Pods that are not ready are deleted immediately.
+	unready := pod.DeepCopy()
+	unready.Annotations = nil
+	unready.Status.Conditions = nil
+	drained, err = r.drainPod(ctx, unready)
+	require.NoError(t, err)
+	require.True(t, drained)
+
+	// Draining is disabled.
+	r.ModelDraining.Timeout.Duration = 0
+	drained, err = r.drainPod(ctx, &got)
+	require.NoError(t, err)
+	require.True(t, drained)
+}
@@ -27,17 +27,21 @@ import (
 
 	batchv1 "k8s.io/api/batch/v1"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	kubeaiv1 "github.com/kubeai-project/kubeai/api/k8s/v1"
 	"github.com/kubeai-project/kubeai/internal/config"
 	"github.com/kubeai-project/kubeai/internal/k8sutils"
+	"github.com/kubeai-project/kubeai/internal/schedule"
 	"github.com/kubeai-project/kubeai/internal/vllmclient"
 	corev1 "k8s.io/api/core/v1"
 )
@@ -65,6 +69,17 @@ type ModelReconciler struct {
 	ModelServerPods         config.ModelServerPods
 	ModelLoaders            config.ModelLoading
 	ModelRollouts           config.ModelRollouts
+	ModelDraining           config.ModelDraining
+	// InFlightCounter is used to wait for in-flight requests of draining Pods
+	// and to avoid unloading adapters that serve requests (optional).
+	InFlightCounter InFlightRequestCounter
+	// Hostname identifies this KubeAI replica in the adapter locks of Pods.
+	Hostname string
+	// Recorder records Events on Models (optional).
+	Recorder record.EventRecorder
+
+	adapterUsage adapterUsage
+	warmups      podWarmups
 }
 
 func (r *ModelReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res ctrl.Result, resErr error) {
@@ -95,7 +110,8 @@ func (r *ModelReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res
 	// Apply self labels based on features so that we can easily filter models.
 	shouldUpdate := r.applySelfLabels(model)
 	// Apply replica bounds to handle cases where min/max replicas were updated but a scale event was not triggered.
-	if !model.Spec.AutoscalingDisabled {
+	if !model.Spec.AutoscalingDisabled && model.Spec.AutoscalingMode != kubeaiv1.RecommendAutoscalingMode {
+		r.validateSchedule(model)
 		shouldUpdate = r.applyAutoscalingReplicaBounds(model) || shouldUpdate
 	}
 	if shouldUpdate {
@@ -181,27 +197,50 @@ func (r *ModelReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res
 
 	if plan.containsActions() {
 		var err error
-		scaled, err = plan.execute(ctx, r.Client, r.Scheme)
+		scaled, err = plan.execute(ctx, r.Client, r.Scheme, r.drainPod)
 		if err != nil {
 			return ctrl.Result{}, fmt.Errorf("executing pod plan: %w", err)
 		}
 	}
+	var result ctrl.Result
+	if plan.draining {
+		result.RequeueAfter = drainPollInterval
+	}
+
+	if err := r.reconcilePodWeights(ctx, plan.toRemain, plan.hash, plan.weight); err != nil {
+		return ctrl.Result{}, fmt.Errorf("reconciling pod weights: %w", err)
+	}
 
-	if err := r.reconcileAdapters(ctx, plan.toRemain, model.Spec.Adapters); err != nil {
+	if err := r.reconcileAdapters(ctx, plan.toRemain, model.Spec.Adapters, model.Spec.AdapterLoading.Mode); err != nil {
 		if errors.Is(err, errReturnEarly) {
-			return ctrl.Result{}, nil
+			return result, nil
 		}
 		return ctrl.Result{}, fmt.Errorf("reconciling adapters: %w", err)
 	}
 
-	return ctrl.Result{}, nil
+	warmingUp, err := r.reconcileWarmup(ctx, model, plan.toRemain)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("reconciling warmup: %w", err)
+	}
+	if warmingUp && (result.RequeueAfter == 0 || result.RequeueAfter > warmupPollInterval) {
+		result.RequeueAfter = warmupPollInterval
+	}
+
+	return result, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *ModelReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	// TODO: Set Model concurrency. Pod rollouts can be slow.
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&kubeaiv1.Model{}).
+		// The autoscaler patches the Model status on most of its ticks.
+		// Status changes do not affect the Pods, so they are ignored
+		// (annotations are copied to the Pods, so they still count).
+		For(&kubeaiv1.Model{}, builder.WithPredicates(predicate.Or(
+			predicate.GenerationChangedPredicate{},
+			predicate.AnnotationChangedPredicate{},
+			predicate.LabelChangedPredicate{},
+		))).
 		Owns(&corev1.Pod{}).
 		Owns(&corev1.PersistentVolumeClaim{}).
 		Owns(&batchv1.Job{}).
@@ -354,9 +393,21 @@ func (r *ModelReconciler) lookupServerImage(model *kubeaiv1.Model, profile confi
 	}
 }
 
+// validateSchedule records an Event for schedule windows of the Model that
+// are ignored because of an invalid cron expression or time zone.
+func (r *ModelReconciler) validateSchedule(model *kubeaiv1.Model) {
+	err := schedule.Validate(model)
+	if err == nil || r.Recorder == nil {
+		return
+	}
+	r.Recorder.Eventf(model, corev1.EventTypeWarning, "InvalidSchedule",
+		"Ignoring invalid schedule windows: %v", err)
+}
+
+// applyAutoscalingReplicaBounds does not check the budget of the resource
+// profile: the min replicas are always allocated by the autoscaler as well.
 func (r *ModelReconciler) applyAutoscalingReplicaBounds(model *kubeaiv1.Model) bool {
-	min := model.Spec.MinReplicas
-	max := model.Spec.MaxReplicas
+	min, max := schedule.ReplicaBounds(model, time.Now())
 
 	if model.Spec.Replicas == nil || *model.Spec.Replicas < min {
 		model.Spec.Replicas = ptr.To(min)
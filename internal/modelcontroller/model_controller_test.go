@@ -9,6 +9,7 @@ import (
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/tools/record"
 )
 
 func Test_getModelConfig(t *testing.T) {
@@ -146,3 +147,19 @@ func requireEqualJSON(t *testing.T, a, b interface{}) {
 	require.NoError(t, err)
 	require.JSONEq(t, string(jsonA), string(jsonB))
 }
+
+func TestValidateSchedule(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	r := &ModelReconciler{Recorder: recorder}
+
+	r.validateSchedule(&v1.Model{Spec: v1.ModelSpec{
+		Schedule: []v1.ScheduleWindow{{Cron: "* 8-17 * * MON-FRI", TimeZone: "America/New_York"}},
+	}})
+	require.Empty(t, recorder.Events)
+
+	r.validateSchedule(&v1.Model{Spec: v1.ModelSpec{
+		Schedule: []v1.ScheduleWindow{{Cron: "* * * * *", TimeZone: "Invalid/Zone"}},
+	}})
+	require.Len(t, recorder.Events, 1)
+	require.Contains(t, <-recorder.Events, "Warning InvalidSchedule Ignoring invalid schedule windows: window 0: loading time zone")
+}
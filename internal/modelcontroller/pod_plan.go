@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math"
 	"sort"
+	"strconv"
 	"strings"
 
 	kubeaiv1 "github.com/kubeai-project/kubeai/api/k8s/v1"
@@ -39,6 +40,16 @@ func (r *ModelReconciler) calculatePodPlan(allPods *corev1.PodList, model *kubea
 		podForModel = r.vLLMPodForModel(model, modelConfig)
 	}
 
+	// The weight is not part of the Pod spec (and hash), so Pods are not
+	// recreated when it changes (see reconcilePodWeights).
+	if w := modelConfig.ResourceProfile.Weight; w > 0 {
+		k8sutils.SetAnnotation(podForModel, kubeaiv1.ModelPodWeightAnnotation, strconv.Itoa(w))
+	}
+	if model.Spec.Warmup != nil {
+		// Removed once the warmup completed (see reconcileWarmup).
+		k8sutils.SetAnnotation(podForModel, kubeaiv1.ModelPodWarmingUpAnnotation, "true")
+	}
+
 	if err := applyJSONPatchToPod(r.ModelServerPods.JSONPatches, podForModel); err != nil {
 		return nil, err
 	}
@@ -57,9 +68,23 @@ func (r *ModelReconciler) calculatePodPlan(allPods *corev1.PodList, model *kubea
 		return p.Namespace + "/" + p.Name
 	}
 
-	sortPodsByDeletionOrder(allPods.Items, expectedHash)
-
+	// Draining Pods are about to be deleted and do not count towards the
+	// replicas of the Model.
+	var (
+		pods     []corev1.Pod
+		draining []*corev1.Pod
+	)
 	for _, p := range allPods.Items {
+		if k8sutils.GetAnnotation(&p, kubeaiv1.ModelPodDrainingAnnotation) != "" {
+			draining = append(draining, &p)
+			continue
+		}
+		pods = append(pods, p)
+	}
+
+	sortPodsByDeletionOrder(pods, expectedHash)
+
+	for _, p := range pods {
 		remainder[podKey(p)] = &p
 
 		upToDate := k8sutils.GetLabel(&p, kubeaiv1.PodHashLabel) == expectedHash
@@ -76,8 +101,11 @@ func (r *ModelReconciler) calculatePodPlan(allPods *corev1.PodList, model *kubea
 	var (
 		details  []string
 		toCreate []*corev1.Pod
-		toDelete []*corev1.Pod
+		toDelete = draining
 	)
+	if len(draining) > 0 {
+		details = append(details, fmt.Sprintf("Draining %d Pods", len(draining)))
+	}
 	appendToDelete := func(p corev1.Pod) {
 		delete(remainder, podKey(p))
 		toDelete = append(toDelete, &p)
@@ -91,7 +119,7 @@ func (r *ModelReconciler) calculatePodPlan(allPods *corev1.PodList, model *kubea
 	if len(outOfDate) > 0 {
 		desiredReplicas += r.ModelRollouts.Surge
 	}
-	observedReplicas := int32(len(allPods.Items))
+	observedReplicas := int32(len(pods))
 	replicaDiff := observedReplicas - desiredReplicas
 	replicaDiffAbs := int32(math.Abs(float64(replicaDiff)))
 
@@ -108,7 +136,7 @@ func (r *ModelReconciler) calculatePodPlan(allPods *corev1.PodList, model *kubea
 		// Delete Pods.
 		details = append(details, fmt.Sprintf("Deleting %d Pods", replicaDiffAbs))
 		toDeleteCount := replicaDiffAbs
-		for _, pod := range allPods.Items {
+		for _, pod := range pods {
 			if toDeleteCount == 0 {
 				break
 			}
@@ -152,6 +180,8 @@ func (r *ModelReconciler) calculatePodPlan(allPods *corev1.PodList, model *kubea
 		toDelete: toDelete,
 		toRemain: toRemain,
 		details:  details,
+		hash:     expectedHash,
+		weight:   k8sutils.GetAnnotation(podForModel, kubeaiv1.ModelPodWeightAnnotation),
 	}, nil
 }
 
@@ -161,6 +191,13 @@ type podPlan struct {
 	toDelete []*corev1.Pod
 	toRemain []*corev1.Pod
 	details  []string
+	// hash is the hash of up-to-date Pods.
+	hash string
+	// weight is the weight annotation of new Pods (after JSON patches).
+	weight string
+
+	// draining is set by execute if Pods are still draining.
+	draining bool
 }
 
 func (pp *podPlan) containsActions() bool {
@@ -168,7 +205,8 @@ func (pp *podPlan) containsActions() bool {
 }
 
 // execute returns true if a Pod was created or deleted.
-func (pp *podPlan) execute(ctx context.Context, client client.Client, scheme *runtime.Scheme) (bool, error) {
+// Pods are only deleted once drain reports that they are drained (if not nil).
+func (pp *podPlan) execute(ctx context.Context, client client.Client, scheme *runtime.Scheme, drain func(context.Context, *corev1.Pod) (bool, error)) (bool, error) {
 	log := log.FromContext(ctx)
 
 	detailsCSV := strings.Join(pp.details, ", ")
@@ -178,6 +216,16 @@ func (pp *podPlan) execute(ctx context.Context, client client.Client, scheme *ru
 
 	// Delete before create to avoid unnecessary Node scale-ups.
 	for _, pod := range pp.toDelete {
+		if drain != nil {
+			drained, err := drain(ctx, pod)
+			if err != nil {
+				return changed, fmt.Errorf("draining pod: %w", err)
+			}
+			if !drained {
+				pp.draining = true
+				continue
+			}
+		}
 		if err := client.Delete(ctx, &corev1.Pod{
 			ObjectMeta: metav1.ObjectMeta{
 				Namespace: pod.Namespace,
@@ -241,3 +289,27 @@ func sortPodsByDeletionOrder(pods []corev1.Pod, expectedHash string) {
 		return iCreationTime.After(jCreationTime)
 	})
 }
+
+// reconcilePodWeights updates the weight annotation of up-to-date Pods when
+// the weight of the resource profile of the Model (or the JSON patches of model
+// server Pods) changed. An empty weight removes the annotation.
+// Out-of-date Pods keep their weight: they might run on the resources of a
+// previous resource profile until they are replaced.
+func (r *ModelReconciler) reconcilePodWeights(ctx context.Context, pods []*corev1.Pod, hash, want string) error {
+	for _, pod := range pods {
+		if k8sutils.GetLabel(pod, kubeaiv1.PodHashLabel) != hash ||
+			k8sutils.GetAnnotation(pod, kubeaiv1.ModelPodWeightAnnotation) == want {
+			continue
+		}
+		var err error
+		if want == "" {
+			err = r.updatePodRemoveAnnotation(ctx, pod, kubeaiv1.ModelPodWeightAnnotation)
+		} else {
+			err = r.updatePodAddAnnotation(ctx, pod, kubeaiv1.ModelPodWeightAnnotation, want)
+		}
+		if err != nil {
+			return fmt.Errorf("pod %s: %w", pod.Name, err)
+		}
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package modelcontroller
 
 import (
+	"context"
 	"strings"
 	"testing"
 	"time"
@@ -13,7 +14,11 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
 const (
@@ -85,6 +90,12 @@ func Test_calculatePodPlan(t *testing.T) {
 		return p
 	}
 
+	drainingPod := func(name string, hash string, rdy readiness) corev1.Pod {
+		p := testPod(name, hash, rdy)
+		p.Annotations = map[string]string{v1.ModelPodDrainingAnnotation: "2024-01-01T00:00:00Z"}
+		return p
+	}
+
 	cases := []struct {
 		name           string
 		replicas       int32
@@ -92,6 +103,12 @@ func Test_calculatePodPlan(t *testing.T) {
 		wantNCreations int
 		wantDeletions  []string
 		jsonPatches    []config.JSONPatch
+		// weight of the resource profile.
+		weight               int
+		wantWeightAnnotation string
+		// warmup of the Model.
+		warmup                  *v1.Warmup
+		wantWarmingUpAnnotation string
 	}{
 		{
 			name: "do nothing",
@@ -108,6 +125,26 @@ func Test_calculatePodPlan(t *testing.T) {
 			},
 			wantNCreations: 2,
 		},
+		{
+			name: "scale up with weighted resource profile",
+			pods: []corev1.Pod{
+				testPod("up-to-date-1", expectedHash, ready),
+			},
+			weight:               3,
+			wantNCreations:       2,
+			wantWeightAnnotation: "3",
+		},
+		{
+			name: "scale up with warmup",
+			pods: []corev1.Pod{
+				testPod("up-to-date-1", expectedHash, ready),
+			},
+			warmup: &v1.Warmup{
+				Requests: []v1.WarmupRequest{{Path: "/v1/completions", Body: "{}"}},
+			},
+			wantNCreations:          2,
+			wantWarmingUpAnnotation: "true",
+		},
 		{
 			name: "scale down",
 			pods: []corev1.Pod{
@@ -118,6 +155,16 @@ func Test_calculatePodPlan(t *testing.T) {
 			},
 			wantDeletions: []string{"unready-up-to-date"},
 		},
+		{
+			name: "draining pods are deleted and do not count as replicas",
+			pods: []corev1.Pod{
+				testPod("ready-up-to-date-1", expectedHash, ready),
+				drainingPod("draining-up-to-date", expectedHash, ready),
+				testPod("ready-up-to-date-2", expectedHash, ready),
+			},
+			wantNCreations: 1,
+			wantDeletions:  []string{"draining-up-to-date"},
+		},
 		{
 			name: "rollout add surge and delete unreadies",
 			pods: []corev1.Pod{
@@ -159,8 +206,16 @@ func Test_calculatePodPlan(t *testing.T) {
 			if c.jsonPatches != nil {
 				r.ModelServerPods.JSONPatches = c.jsonPatches
 			}
-			plan, err := r.calculatePodPlan(&corev1.PodList{Items: c.pods}, model, modelConfig)
+			mc := modelConfig
+			mc.Weight = c.weight
+			model := model.DeepCopy()
+			model.Spec.Warmup = c.warmup
+			plan, err := r.calculatePodPlan(&corev1.PodList{Items: c.pods}, model, mc)
 			require.NoError(t, err)
+			for _, p := range plan.toCreate {
+				require.Equal(t, c.wantWeightAnnotation, p.Annotations[v1.ModelPodWeightAnnotation])
+				require.Equal(t, c.wantWarmingUpAnnotation, p.Annotations[v1.ModelPodWarmingUpAnnotation])
+			}
 			detailsCSV := strings.Join(plan.details, ", ")
 			require.Lenf(t, plan.toCreate, c.wantNCreations, "Unexpected creation count, details: %v", detailsCSV)
 			var deletionNames []string
@@ -173,6 +228,47 @@ func Test_calculatePodPlan(t *testing.T) {
 	}
 }
 
+func TestReconcilePodWeights(t *testing.T) {
+	ctx := context.Background()
+	testPod := func(name, hash, weight string) *corev1.Pod {
+		p := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+		k8sutils.SetLabel(p, v1.PodHashLabel, hash)
+		if weight != "" {
+			k8sutils.SetAnnotation(p, v1.ModelPodWeightAnnotation, weight)
+		}
+		return p
+	}
+	unweighted := testPod("unweighted", "new", "")
+	outdated := testPod("outdated", "new", "2")
+	current := testPod("current", "new", "3")
+	// A Pod of the previous resource profile during a rollout.
+	rollingOut := testPod("rolling-out", "old", "2")
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	r := &ModelReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(unweighted, outdated, current, rollingOut).Build(),
+	}
+	weight := func(p *corev1.Pod) string {
+		var got corev1.Pod
+		require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(p), &got))
+		return k8sutils.GetAnnotation(&got, v1.ModelPodWeightAnnotation)
+	}
+	pods := []*corev1.Pod{unweighted, outdated, current, rollingOut}
+
+	require.NoError(t, r.reconcilePodWeights(ctx, pods, "new", "3"))
+	require.Equal(t, "3", weight(unweighted))
+	require.Equal(t, "3", weight(outdated))
+	require.Equal(t, "3", weight(current))
+	require.Equal(t, "2", weight(rollingOut), "out-of-date Pods should keep their weight")
+
+	require.NoError(t, r.reconcilePodWeights(ctx, pods, "new", ""))
+	require.Equal(t, "", weight(unweighted), "the default weight should not be annotated")
+	require.Equal(t, "", weight(outdated))
+	require.Equal(t, "", weight(current))
+	require.Equal(t, "2", weight(rollingOut), "out-of-date Pods should keep their weight")
+}
+
 func Test_sortPodsByDeletionOrder(t *testing.T) {
 	cases := []struct {
 		name string
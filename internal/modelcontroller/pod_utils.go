@@ -8,6 +8,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/remotecommand"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 func (r *ModelReconciler) execPod(ctx context.Context, pod *corev1.Pod, container string, command []string) error {
@@ -46,19 +47,47 @@ func (r *ModelReconciler) updatePodRemoveLabel(ctx context.Context, pod *corev1.
 	if pod.Labels == nil {
 		return nil
 	}
+	// Labels are patched because adapters might be loaded concurrently
+	// (see LoadAdapter) based on a stale copy of the Pod.
+	base := client.MergeFrom(pod.DeepCopy())
 	delete(pod.Labels, key)
-	if err := r.Client.Update(ctx, pod); err != nil {
+	if err := r.Client.Patch(ctx, pod, base); err != nil {
 		return fmt.Errorf("update pod labels: %w", err)
 	}
 	return nil
 }
 
+func (r *ModelReconciler) updatePodRemoveAnnotation(ctx context.Context, pod *corev1.Pod, key string) error {
+	if _, ok := pod.Annotations[key]; !ok {
+		return nil
+	}
+	base := client.MergeFrom(pod.DeepCopy())
+	delete(pod.Annotations, key)
+	if err := r.Client.Patch(ctx, pod, base); err != nil {
+		return fmt.Errorf("update pod annotations: %w", err)
+	}
+	return nil
+}
+
+func (r *ModelReconciler) updatePodAddAnnotation(ctx context.Context, pod *corev1.Pod, key, value string) error {
+	base := client.MergeFrom(pod.DeepCopy())
+	if pod.Annotations == nil {
+		pod.Annotations = make(map[string]string)
+	}
+	pod.Annotations[key] = value
+	if err := r.Client.Patch(ctx, pod, base); err != nil {
+		return fmt.Errorf("update pod annotations: %w", err)
+	}
+	return nil
+}
+
 func (r *ModelReconciler) updatePodAddLabel(ctx context.Context, pod *corev1.Pod, key, value string) error {
+	base := client.MergeFrom(pod.DeepCopy())
 	if pod.Labels == nil {
 		pod.Labels = make(map[string]string)
 	}
 	pod.Labels[key] = value
-	if err := r.Client.Update(ctx, pod); err != nil {
+	if err := r.Client.Patch(ctx, pod, base); err != nil {
 		return fmt.Errorf("update pod labels: %w", err)
 	}
 	return nil
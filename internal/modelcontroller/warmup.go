@@ -0,0 +1,199 @@
+package modelcontroller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	kubeaiv1 "github.com/kubeai-project/kubeai/api/k8s/v1"
+	"github.com/kubeai-project/kubeai/internal/k8sutils"
+	"github.com/kubeai-project/kubeai/internal/metrics"
+	"go.opentelemetry.io/otel/metric"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// warmupPollInterval is the interval at which running warmups are checked.
+const warmupPollInterval = 2 * time.Second
+
+// maxWarmupAttempts is the number of times that the warmup of a Pod is
+// attempted before the Pod is added to load balancing without warmup.
+const maxWarmupAttempts = 3
+
+// podWarmups tracks the warmups that run in the background by Pod UID.
+// The zero value is ready to use.
+type podWarmups struct {
+	mtx     sync.Mutex
+	warmups map[types.UID]*podWarmup
+}
+
+type podWarmup struct {
+	model    string
+	running  bool
+	start    time.Time
+	err      error
+	done     bool
+	failures int
+}
+
+// reconcileWarmup starts the warmup of the ready Pods that are still marked
+// as warming up in the background and removes the mark once all requests
+// succeeded (or the warmup failed too often), which adds the Pods to load
+// balancing. It returns true while warmups are running.
+func (r *ModelReconciler) reconcileWarmup(ctx context.Context, model *kubeaiv1.Model, pods []*corev1.Pod) (bool, error) {
+	log := log.FromContext(ctx)
+
+	var running bool
+	for _, pod := range pods {
+		if k8sutils.GetAnnotation(pod, kubeaiv1.ModelPodWarmingUpAnnotation) == "" ||
+			!k8sutils.PodIsReady(pod) {
+			continue
+		}
+
+		// The warmup might have been removed from the Model since the
+		// Pod was created.
+		if w := model.Spec.Warmup; w != nil {
+			state := r.warmups.get(pod.UID)
+			switch {
+			case state.running:
+				running = true
+				continue
+			case state.done:
+				log.Info("Warmed up Pod", "podName", pod.Name, "duration", time.Since(state.start))
+				metrics.EndpointWarmupDuration.Record(ctx, time.Since(state.start).Seconds(), metric.WithAttributes(
+					metrics.AttrRequestModel.String(model.Name),
+				))
+			case state.failures >= maxWarmupAttempts:
+				log.Info("Giving up warming up Pod", "podName", pod.Name, "error", state.err)
+				if r.Recorder != nil {
+					r.Recorder.Eventf(model, corev1.EventTypeWarning, "WarmupFailed",
+						"Warmup of Pod %s failed %d times, adding it without warmup: %v", pod.Name, state.failures, state.err)
+				}
+			default:
+				if state.err != nil {
+					log.Info("Retrying warmup of Pod", "podName", pod.Name, "error", state.err)
+				} else {
+					log.Info("Warming up Pod", "podName", pod.Name)
+				}
+				r.warmups.start(ctx, model.Name, pod.UID, func(ctx context.Context) error {
+					return r.warmupPod(ctx, pod, w)
+				})
+				running = true
+				continue
+			}
+		}
+
+		base := client.MergeFrom(pod.DeepCopy())
+		delete(pod.Annotations, kubeaiv1.ModelPodWarmingUpAnnotation)
+		if err := r.Client.Patch(ctx, pod, base); err != nil {
+			return running, fmt.Errorf("removing warmup annotation from pod %q: %w", pod.Namespace+"/"+pod.Name, err)
+		}
+		r.warmups.forget(pod.UID)
+	}
+
+	r.warmups.prune(model.Name, pods)
+
+	return running, nil
+}
+
+// get returns a copy of the warmup state of the Pod.
+func (w *podWarmups) get(uid types.UID) podWarmup {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if state, ok := w.warmups[uid]; ok {
+		return *state
+	}
+	return podWarmup{}
+}
+
+// start runs a warmup attempt of the Pod in the background.
+func (w *podWarmups) start(ctx context.Context, model string, uid types.UID, warmup func(context.Context) error) {
+	w.mtx.Lock()
+	if w.warmups == nil {
+		w.warmups = map[types.UID]*podWarmup{}
+	}
+	state, ok := w.warmups[uid]
+	if !ok {
+		state = &podWarmup{model: model}
+		w.warmups[uid] = state
+	}
+	state.running = true
+	state.start = time.Now()
+	w.mtx.Unlock()
+
+	go func() {
+		err := warmup(ctx)
+		w.mtx.Lock()
+		defer w.mtx.Unlock()
+		state.running = false
+		state.err = err
+		if err != nil {
+			state.failures++
+		} else {
+			state.done = true
+		}
+	}()
+}
+
+func (w *podWarmups) forget(uid types.UID) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	delete(w.warmups, uid)
+}
+
+// prune forgets the finished warmups of the Pods of the Model that were removed.
+func (w *podWarmups) prune(model string, pods []*corev1.Pod) {
+	uids := make(map[types.UID]bool, len(pods))
+	for _, pod := range pods {
+		uids[pod.UID] = true
+	}
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	for uid, state := range w.warmups {
+		if state.model == model && !state.running && !uids[uid] {
+			delete(w.warmups, uid)
+		}
+	}
+}
+
+func (r *ModelReconciler) warmupPod(ctx context.Context, pod *corev1.Pod, w *kubeaiv1.Warmup) error {
+	addr := getPodModelServerAddr(pod)
+	timeout := time.Duration(max(1, w.TimeoutSeconds)) * time.Second
+	for i, wr := range w.Requests {
+		if err := sendWarmupRequest(ctx, addr+wr.Path, wr.Body, timeout); err != nil {
+			return fmt.Errorf("request %d (%s): %w", i, wr.Path, err)
+		}
+	}
+	return nil
+}
+
+func sendWarmupRequest(ctx context.Context, url, body string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Read the whole response to make sure that the generation completed.
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, respBody)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package modelcontroller
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v1 "github.com/kubeai-project/kubeai/api/k8s/v1"
+	"github.com/kubeai-project/kubeai/internal/k8sutils"
+	"github.com/kubeai-project/kubeai/internal/metrics/metricstest"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileWarmup(t *testing.T) {
+	metricstest.Init(t)
+	ctx := context.Background()
+
+	var (
+		requests atomic.Int32
+		failing  atomic.Bool
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		require.Equal(t, "/v1/completions", r.URL.Path)
+		require.Equal(t, `{"prompt":"hi"}`, string(body))
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	require.NoError(t, err)
+
+	model := &v1.Model{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-model", Namespace: "default"},
+		Spec: v1.ModelSpec{
+			Warmup: &v1.Warmup{
+				Requests: []v1.WarmupRequest{
+					{Path: "/v1/completions", Body: `{"prompt":"hi"}`},
+					{Path: "/v1/completions", Body: `{"prompt":"hi"}`},
+				},
+				TimeoutSeconds: 5,
+			},
+		},
+	}
+	testPod := func(name string, ready, warmingUp bool) *corev1.Pod {
+		p := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				UID:       types.UID(name),
+				Annotations: map[string]string{
+					v1.ModelPodIPAnnotation:   host,
+					v1.ModelPodPortAnnotation: port,
+				},
+			},
+		}
+		if warmingUp {
+			p.Annotations[v1.ModelPodWarmingUpAnnotation] = "true"
+		}
+		if ready {
+			p.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+		}
+		return p
+	}
+	cold := testPod("cold", true, true)
+	unready := testPod("unready", false, true)
+	warm := testPod("warm", true, false)
+	broken := testPod("broken", true, true)
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	recorder := record.NewFakeRecorder(10)
+	r := &ModelReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(cold, unready, warm, broken).Build(),
+		Recorder: recorder,
+	}
+	warmingUp := func(p *corev1.Pod) bool {
+		var got corev1.Pod
+		require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(p), &got))
+		return k8sutils.GetAnnotation(&got, v1.ModelPodWarmingUpAnnotation) != ""
+	}
+	// Warmups run in the background, so reconcile until no warmup is running.
+	reconcileUntilDone := func(pods ...*corev1.Pod) {
+		require.Eventually(t, func() bool {
+			running, err := r.reconcileWarmup(ctx, model, pods)
+			require.NoError(t, err)
+			return !running
+		}, 5*time.Second, 10*time.Millisecond)
+	}
+
+	running, err := r.reconcileWarmup(ctx, model, []*corev1.Pod{cold, unready, warm})
+	require.NoError(t, err)
+	require.True(t, running, "the warmup should not block the reconcile")
+	reconcileUntilDone(cold, unready, warm)
+	require.Equal(t, int32(2), requests.Load(), "only the ready cold Pod should be warmed up")
+	require.False(t, warmingUp(cold))
+	require.True(t, warmingUp(unready))
+
+	mets := metricstest.Collect(t)
+	metricstest.RequireWarmupDurationMetric(t, mets, model.Name, 1)
+
+	// Failed warmups are retried and given up on after the max attempts.
+	failing.Store(true)
+	requests.Store(0)
+	reconcileUntilDone(broken)
+	require.Equal(t, int32(maxWarmupAttempts), requests.Load())
+	require.False(t, warmingUp(broken))
+	require.Contains(t, <-recorder.Events, "Warning WarmupFailed Warmup of Pod broken failed 3 times")
+
+	// Pods of Models whose warmup was removed join without warmup.
+	model.Spec.Warmup = nil
+	unready.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+	requests.Store(0)
+	running, err = r.reconcileWarmup(ctx, model, []*corev1.Pod{unready})
+	require.NoError(t, err)
+	require.False(t, running)
+	require.Zero(t, requests.Load())
+	require.False(t, warmingUp(unready))
+}
@@ -1,27 +1,48 @@
 package modelproxy
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"strings"
+	"time"
 
 	v1 "github.com/kubeai-project/kubeai/api/k8s/v1"
 	"github.com/kubeai-project/kubeai/internal/apiutils"
+	"github.com/kubeai-project/kubeai/internal/config"
+	"github.com/kubeai-project/kubeai/internal/loadbalancer"
 	"github.com/kubeai-project/kubeai/internal/metrics"
+	"github.com/kubeai-project/kubeai/internal/modelclient"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 )
 
 type ModelClient interface {
 	LookupModel(ctx context.Context, model, adapter string, selectors []string) (*v1.Model, error)
+	LookupModelAlias(ctx context.Context, name string, selectors []string) (*v1.ModelAlias, error)
 	ScaleAtLeastOneReplica(ctx context.Context, model string) error
 }
 
 type LoadBalancer interface {
 	AwaitBestAddress(ctx context.Context, req *apiutils.Request) (string, func(), error)
+	GetAllAddresses(model string) []string
+	RecordOutcome(model, address string, failed bool, latency time.Duration)
+}
+
+type AdapterLoader interface {
+	LoadAdapter(ctx context.Context, model, adapter string) error
+}
+
+type RateLimiter interface {
+	Key(caller string, selectors []string) string
+	Allow(key string) (time.Duration, bool)
+	ConsumeTokens(key string, n int64)
 }
 
 // Handler serves http requests for end-clients.
@@ -31,6 +52,18 @@ type Handler struct {
 	loadBalancer LoadBalancer
 	maxRetries   int
 	retryCodes   map[int]struct{}
+	// callerHeader is the request header that identifies the caller
+	// when accounting for token usage.
+	callerHeader string
+	// retryInterruptedResponses enables buffering of non-streamed
+	// responses so that they can be retried.
+	retryInterruptedResponses bool
+	// allowPriorityHeader honors the X-Priority header of clients.
+	allowPriorityHeader bool
+	// rateLimiter is optional.
+	rateLimiter RateLimiter
+	// adapterLoader is optional.
+	adapterLoader AdapterLoader
 }
 
 func NewHandler(
@@ -38,12 +71,20 @@ func NewHandler(
 	loadBalancer LoadBalancer,
 	maxRetries int,
 	retryCodes map[int]struct{},
+	cfg config.ModelProxy,
+	rateLimiter RateLimiter,
+	adapterLoader AdapterLoader,
 ) *Handler {
 	return &Handler{
-		modelClient:  modelClient,
-		loadBalancer: loadBalancer,
-		maxRetries:   maxRetries,
-		retryCodes:   retryCodes,
+		modelClient:               modelClient,
+		loadBalancer:              loadBalancer,
+		maxRetries:                maxRetries,
+		retryCodes:                retryCodes,
+		callerHeader:              cfg.CallerHeader,
+		retryInterruptedResponses: cfg.RetryInterruptedResponses,
+		allowPriorityHeader:       cfg.AllowPriorityHeader,
+		rateLimiter:               rateLimiter,
+		adapterLoader:             adapterLoader,
 	}
 }
 
@@ -73,6 +114,15 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	log.Println("model:", pr.Model, "adapter:", pr.Adapter)
 
+	if h.rateLimiter != nil {
+		key := h.rateLimiter.Key(pr.caller, pr.Selectors)
+		if retryAfter, ok := h.rateLimiter.Allow(key); !ok {
+			pr.sendRateLimitResponse(w, retryAfter)
+			return
+		}
+		pr.consumeTokens = func(n int64) { h.rateLimiter.ConsumeTokens(key, n) }
+	}
+
 	metricAttrs := metric.WithAttributeSet(attribute.NewSet(
 		metrics.AttrRequestModel.String(pr.RequestedModel),
 		metrics.AttrRequestType.String(metrics.AttrRequestTypeHTTP),
@@ -82,13 +132,90 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// Ensure the backend is scaled to at least one Pod.
 	if err := h.modelClient.ScaleAtLeastOneReplica(r.Context(), pr.Model); err != nil {
-		pr.sendErrorResponse(w, http.StatusInternalServerError, "unable to scale model: %v", err)
+		var scheduled *modelclient.ScheduledToZeroError
+		if !errors.As(err, &scheduled) {
+			pr.sendErrorResponse(w, http.StatusInternalServerError, "unable to scale model: %v", err)
+			return
+		}
+		// The Model is not scaled up, so the request is only served if the
+		// Model still has replicas or if it can fall back to another Model.
+		if len(h.loadBalancer.GetAllAddresses(pr.Model)) == 0 && !h.fallback(pr, metrics.AttrFallbackReasonUnavailable) {
+			w.Header().Set("Retry-After", retryAfterSeconds(time.Until(scheduled.Until)))
+			pr.sendErrorResponse(w, http.StatusServiceUnavailable, "%v", err)
+			return
+		}
+	}
+
+	// Serve the request with a fallback Model (if any) instead of
+	// waiting for the requested Model to scale up.
+	if len(h.loadBalancer.GetAllAddresses(pr.Model)) == 0 {
+		h.fallback(pr, metrics.AttrFallbackReasonUnavailable)
+	}
+
+	// Adapters of Models that load them on demand might not be loaded
+	// into any Pod yet. Adapters that are unloaded again while the request
+	// waits for an endpoint are reloaded by proxyHTTP.
+	if !h.loadAdapter(w, pr) {
 		return
 	}
 
 	h.proxyHTTP(w, pr)
 }
 
+// loadAdapter loads the adapter of the request (if any) into a Pod of the
+// Model. It responds with an error and returns false if the adapter could
+// not be loaded.
+func (h *Handler) loadAdapter(w http.ResponseWriter, pr *proxyRequest) bool {
+	if pr.Adapter == "" || h.adapterLoader == nil {
+		return true
+	}
+	if err := h.adapterLoader.LoadAdapter(pr.http.Context(), pr.Model, pr.Adapter); err != nil {
+		pr.sendErrorResponse(w, http.StatusInternalServerError, "unable to load adapter: %v", err)
+		return false
+	}
+	return true
+}
+
+// servedModelHeader is set on responses from backends to report the Model
+// (possibly merged with an adapter) that served the request.
+const servedModelHeader = "X-Served-Model"
+
+// fallback switches the request over to the next fallback Model that has
+// ready endpoints. It reports whether the request was switched.
+func (h *Handler) fallback(pr *proxyRequest, reason string) bool {
+	ctx := pr.http.Context()
+	for len(pr.fallback) > 0 {
+		name := pr.fallback[0]
+		pr.fallback = pr.fallback[1:]
+
+		model, _ := apiutils.SplitModelAdapter(name)
+		if len(h.loadBalancer.GetAllAddresses(model)) == 0 {
+			continue
+		}
+		from := apiutils.MergeModelAdapter(pr.Model, pr.Adapter)
+		if err := pr.SwitchModel(ctx, h.modelClient, name); err != nil {
+			log.Printf("Unable to fall back to model %q: %v: %v", name, pr.ID, err)
+			continue
+		}
+
+		log.Printf("Falling back from model %q to %q (%v): %v", from, name, reason, pr.ID)
+		metrics.InferenceRequestsFallback.Add(ctx, 1, metric.WithAttributeSet(attribute.NewSet(
+			metrics.AttrRequestModel.String(pr.RequestedModel),
+			metrics.AttrFallbackModel.String(name),
+			metrics.AttrFallbackReason.String(reason),
+			metrics.AttrRequestType.String(metrics.AttrRequestTypeHTTP),
+		)))
+		// The fallback Model gets its own retries.
+		pr.attempt = 0
+		return true
+	}
+	return false
+}
+
+// queueRetryAfterSeconds is sent to clients of requests that were
+// rejected by the request queue of a model.
+const queueRetryAfterSeconds = "1"
+
 // AdditionalProxyRewrite is an injection point for modifying proxy requests.
 // Used in tests.
 var AdditionalProxyRewrite = func(*httputil.ProxyRequest) {}
@@ -97,11 +224,28 @@ func (h *Handler) proxyHTTP(w http.ResponseWriter, pr *proxyRequest) {
 	log.Printf("Waiting for host: %v", pr.ID)
 
 	addr, decrementInflight, err := h.loadBalancer.AwaitBestAddress(pr.http.Context(), pr.Request)
+	for errors.Is(err, loadbalancer.ErrAdapterNotLoaded) && h.adapterLoader != nil {
+		// The adapter was unloaded to make room for another adapter
+		// while the request was waiting.
+		log.Printf("Loading adapter %q of model %q again: %v", pr.Adapter, pr.Model, pr.ID)
+		if !h.loadAdapter(w, pr) {
+			return
+		}
+		addr, decrementInflight, err = h.loadBalancer.AwaitBestAddress(pr.http.Context(), pr.Request)
+	}
 	if err != nil {
 		switch {
 		case errors.Is(err, context.Canceled):
 			pr.sendErrorResponse(w, http.StatusInternalServerError, "request cancelled while finding host: %v", err)
 			return
+		case errors.Is(err, loadbalancer.ErrQueueFull):
+			w.Header().Set("Retry-After", queueRetryAfterSeconds)
+			pr.sendErrorResponse(w, http.StatusTooManyRequests, "%v", err)
+			return
+		case errors.Is(err, loadbalancer.ErrQueueWaitExceeded):
+			w.Header().Set("Retry-After", queueRetryAfterSeconds)
+			pr.sendErrorResponse(w, http.StatusServiceUnavailable, "%v", err)
+			return
 		case errors.Is(err, context.DeadlineExceeded):
 			pr.sendErrorResponse(w, http.StatusGatewayTimeout, "request timeout while finding host: %v", err)
 			return
@@ -111,7 +255,29 @@ func (h *Handler) proxyHTTP(w http.ResponseWriter, pr *proxyRequest) {
 		}
 	}
 	// NOTE: decrementInflight will be called after the request succeeds or fails after all retries.
-	defer decrementInflight()
+	defer func() { decrementInflight() }()
+	// retry releases the in-flight slot of this attempt before the request is
+	// retried (the endpoint might not have another slot for the retry).
+	retry := func(w http.ResponseWriter) {
+		decrementInflight()
+		decrementInflight = func() {}
+		h.proxyHTTP(w, pr)
+	}
+
+	// The outcome of each attempt is reported to the load balancer
+	// for passive health checking of the endpoint.
+	var (
+		model           = pr.Model
+		start           = time.Now()
+		outcomeRecorded bool
+	)
+	recordOutcome := func(failed bool, latency time.Duration) {
+		if outcomeRecorded {
+			return
+		}
+		outcomeRecorded = true
+		h.loadBalancer.RecordOutcome(model, addr, failed, latency)
+	}
 
 	proxy := &httputil.ReverseProxy{
 		Rewrite: func(r *httputil.ProxyRequest) {
@@ -127,11 +293,37 @@ func (h *Handler) proxyHTTP(w http.ResponseWriter, pr *proxyRequest) {
 	proxy.ModifyResponse = func(r *http.Response) error {
 		// Record the response for metrics.
 		pr.status = r.StatusCode
+		latency := time.Since(start)
 
 		// This point is reached if a response code is received.
-		if h.isRetryCode(r.StatusCode) && pr.attempt < h.maxRetries {
+		if h.isRetryCode(r.StatusCode) {
+			recordOutcome(true, latency)
 			// Returning an error will trigger the ErrorHandler.
-			return ErrRetry
+			if pr.attempt < h.maxRetries {
+				return ErrRetry
+			}
+			if h.fallback(pr, metrics.AttrFallbackReasonErrors) {
+				return ErrFallback
+			}
+		}
+
+		if h.retryInterruptedResponses && !isEventStream(r) {
+			// Read the whole body before responding to the client so that
+			// a failure while reading it can still be retried.
+			// Returning an error will trigger the ErrorHandler.
+			// Bodies that are too large to buffer are streamed and
+			// are not retried.
+			if err := bufferBody(r, maxBufferedBodyBytes); err != nil {
+				recordOutcome(true, latency)
+				return fmt.Errorf("reading response body: %w", err)
+			}
+		}
+		recordOutcome(false, latency)
+
+		r.Header.Set(servedModelHeader, apiutils.MergeModelAdapter(pr.Model, pr.Adapter))
+
+		if r.StatusCode == http.StatusOK && apiutils.ReportsUsage(pr.http.URL.Path) {
+			r.Body = pr.usageBody(r)
 		}
 
 		return nil
@@ -141,13 +333,31 @@ func (h *Handler) proxyHTTP(w http.ResponseWriter, pr *proxyRequest) {
 		// This point could be reached if a bad response code was sent by the backend
 		// or
 		// if there was an issue with the connection and no response was ever received.
-		if err != nil && r.Context().Err() == nil && pr.attempt < h.maxRetries {
-			pr.attempt++
-
-			log.Printf("Retrying request (%v/%v): %v: %v", pr.attempt, h.maxRetries, pr.ID, err)
-			h.proxyHTTP(w, pr)
+		if r.Context().Err() == nil {
+			recordOutcome(true, time.Since(start))
+		}
+		if errors.Is(err, ErrFallback) {
+			// The fallback Model might serve an adapter.
+			if h.loadAdapter(w, pr) {
+				retry(w)
+			}
 			return
 		}
+		if err != nil && r.Context().Err() == nil {
+			if pr.attempt < h.maxRetries {
+				pr.attempt++
+
+				log.Printf("Retrying request (%v/%v): %v: %v", pr.attempt, h.maxRetries, pr.ID, err)
+				retry(w)
+				return
+			}
+			if h.fallback(pr, metrics.AttrFallbackReasonErrors) {
+				if h.loadAdapter(w, pr) {
+					retry(w)
+				}
+				return
+			}
+		}
 
 		if !errors.Is(err, ErrRetry) {
 			pr.sendErrorResponse(w, http.StatusBadGateway, "proxy: exceeded retries: %v/%v", pr.attempt, h.maxRetries)
@@ -158,7 +368,42 @@ func (h *Handler) proxyHTTP(w http.ResponseWriter, pr *proxyRequest) {
 	proxy.ServeHTTP(w, pr.httpRequest())
 }
 
-var ErrRetry = errors.New("retry")
+var (
+	ErrRetry    = errors.New("retry")
+	ErrFallback = errors.New("fallback")
+)
+
+func isEventStream(r *http.Response) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), "text/event-stream")
+}
+
+// maxBufferedBodyBytes limits the size of response bodies that are buffered
+// to be able to retry interrupted responses.
+const maxBufferedBodyBytes = 10 << 20
+
+// bufferBody reads the whole response body into memory. If the body is
+// larger than limit, only the beginning of the body is read and the rest
+// is streamed from the backend.
+func bufferBody(r *http.Response, limit int64) error {
+	if r.ContentLength > limit {
+		return nil
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		r.Body.Close()
+		return err
+	}
+	if int64(len(body)) > limit {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		return nil
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return nil
+}
 
 func (h *Handler) isRetryCode(status int) bool {
 	var retry bool
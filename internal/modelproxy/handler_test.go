@@ -2,6 +2,7 @@ package modelproxy
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,7 +14,10 @@ import (
 
 	v1 "github.com/kubeai-project/kubeai/api/k8s/v1"
 	"github.com/kubeai-project/kubeai/internal/apiutils"
+	"github.com/kubeai-project/kubeai/internal/config"
+	"github.com/kubeai-project/kubeai/internal/loadbalancer"
 	"github.com/kubeai-project/kubeai/internal/metrics/metricstest"
+	"github.com/kubeai-project/kubeai/internal/modelclient"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -27,6 +31,21 @@ func TestHandler(t *testing.T) {
 		model3   = "model3"
 		adapter3 = "adapter3"
 
+		// model4 has no ready endpoints.
+		model4 = "model4"
+		model5 = "model5"
+
+		// model6 fails to load its adapter.
+		model6   = "model6"
+		adapter6 = "adapter6"
+
+		// model7 is scheduled to have 0 replicas.
+		model7 = "model7"
+		// model8 is scheduled to have 0 replicas and has a fallback.
+		model8 = "model8"
+		// model9 allows one in-flight request per endpoint.
+		model9 = "model9"
+
 		maxRetries = 3
 	)
 	models := map[string]testMockModel{
@@ -37,24 +56,98 @@ func TestHandler(t *testing.T) {
 				adapter3: true,
 			},
 		},
+		model4: {
+			unavailable: true,
+			fallback:    []string{model1},
+		},
+		model5: {
+			fallback: []string{model4, apiutils.MergeModelAdapter(model3, adapter3)},
+		},
+		model6: {
+			adapters: map[string]bool{
+				adapter6: true,
+			},
+			adapterLoadErr: errors.New("no ready pods"),
+		},
+		model7: {
+			unavailable:  true,
+			scheduledFor: 90 * time.Minute,
+		},
+		model8: {
+			unavailable:  true,
+			scheduledFor: 90 * time.Minute,
+			fallback:     []string{model1},
+		},
+		model9: {
+			maxConcurrency: 1,
+		},
 	}
 
 	type metricsTestSpec struct {
 		expModel string
 	}
 
+	type fallbackTestSpec struct {
+		expModel    string
+		expFallback string
+		expReason   string
+	}
+
+	type usageTestSpec struct {
+		expModel      string
+		expAdapter    string
+		expCaller     string
+		expPrompt     int64
+		expCompletion int64
+	}
+
+	const streamedChunks = "" +
+		"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hi\"}}],\"usage\":null}\n\n" +
+		"data: {\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}],\"usage\":null}\n\n"
+	const streamedUsageChunk = "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":7,\"completion_tokens\":2,\"total_tokens\":9}}\n\n"
+	const streamedDone = "data: [DONE]\n\n"
+
 	specs := map[string]struct {
 		reqBody    string
 		reqHeaders map[string]string
+		// allowPriorityHeader configures the handler to honor X-Priority headers.
+		allowPriorityHeader bool
+		// retryInterruptedResponses configures the handler to buffer responses.
+		retryInterruptedResponses bool
 
-		backendPanic bool
-		backendCode  int
-		backendBody  string
+		// awaitErr is returned by the load balancer instead of an address.
+		awaitErr error
 
-		expRewrittenReqBody    string
-		expCode                int
-		expBody                string
-		expMetrics             *metricsTestSpec
+		backendPanic bool
+		// backendFailures is the number of requests that the backend
+		// responds to with a 503 before it responds normally.
+		backendFailures int
+		// backendInterrupts is the number of requests for which the backend
+		// fails after the response body was (partially) sent.
+		backendInterrupts  int
+		backendCode        int
+		backendContentType string
+		backendBody        string
+
+		expRewrittenReqBody string
+		// expFallbackReqBody is the request body that is expected to reach
+		// the backend after the backend failures.
+		expFallbackReqBody string
+		expCode            int
+		expBody            string
+		expMetrics         *metricsTestSpec
+		expUsage           *usageTestSpec
+		expFallback        *fallbackTestSpec
+		// expOutcomes are the outcomes (success) reported to the load balancer.
+		expOutcomes           []bool
+		expStreamsInterrupted int64
+		expHeaders            map[string]string
+		// expConsumedTokens are the tokens accounted for per rate limit key.
+		expConsumedTokens map[string]int64
+		// expLoadedAdapters are the adapters that were requested to be loaded.
+		expLoadedAdapters []string
+		// expPriority is the priority of the request in the request queue.
+		expPriority            int32
 		expBackendRequestCount int
 	}{
 		"no model": {
@@ -78,6 +171,29 @@ func TestHandler(t *testing.T) {
 			expMetrics: &metricsTestSpec{
 				expModel: model1,
 			},
+			expHeaders:             map[string]string{servedModelHeader: model1},
+			expOutcomes:            []bool{true},
+			expBackendRequestCount: 1,
+		},
+		"priority header ignored by default": {
+			reqBody:                fmt.Sprintf(`{"model":%q,"messages":[]}`, model1),
+			reqHeaders:             map[string]string{"X-Priority": "10"},
+			backendCode:            http.StatusOK,
+			backendBody:            `{"result":"ok"}`,
+			expCode:                http.StatusOK,
+			expBody:                `{"result":"ok"}`,
+			expPriority:            0,
+			expBackendRequestCount: 1,
+		},
+		"priority header allowed": {
+			reqBody:                fmt.Sprintf(`{"model":%q,"messages":[]}`, model1),
+			reqHeaders:             map[string]string{"X-Priority": "10"},
+			allowPriorityHeader:    true,
+			backendCode:            http.StatusOK,
+			backendBody:            `{"result":"ok"}`,
+			expCode:                http.StatusOK,
+			expBody:                `{"result":"ok"}`,
+			expPriority:            10,
 			expBackendRequestCount: 1,
 		},
 		"happy 200 model+adapter in body": {
@@ -90,8 +206,45 @@ func TestHandler(t *testing.T) {
 			expMetrics: &metricsTestSpec{
 				expModel: apiutils.MergeModelAdapter(model3, adapter3),
 			},
+			expLoadedAdapters:      []string{apiutils.MergeModelAdapter(model3, adapter3)},
 			expBackendRequestCount: 1,
 		},
+		"200 adapter unloaded while waiting": {
+			reqBody:             fmt.Sprintf(`{"model":%q,"messages":[]}`, apiutils.MergeModelAdapter(model3, adapter3)),
+			expRewrittenReqBody: fmt.Sprintf(`{"model":%q,"messages":[]}`, adapter3),
+			awaitErr:            loadbalancer.ErrAdapterNotLoaded,
+			backendCode:         http.StatusOK,
+			backendBody:         `{"result":"ok"}`,
+			expCode:             http.StatusOK,
+			expBody:             `{"result":"ok"}`,
+			expMetrics: &metricsTestSpec{
+				expModel: apiutils.MergeModelAdapter(model3, adapter3),
+			},
+			expLoadedAdapters: []string{
+				apiutils.MergeModelAdapter(model3, adapter3),
+				apiutils.MergeModelAdapter(model3, adapter3),
+			},
+			expBackendRequestCount: 1,
+		},
+		"retry loads the adapter once": {
+			reqBody:                fmt.Sprintf(`{"model":%q,"messages":[]}`, apiutils.MergeModelAdapter(model3, adapter3)),
+			expRewrittenReqBody:    fmt.Sprintf(`{"model":%q,"messages":[]}`, adapter3),
+			backendFailures:        2,
+			backendCode:            http.StatusOK,
+			backendBody:            `{"result":"ok"}`,
+			expCode:                http.StatusOK,
+			expBody:                `{"result":"ok"}`,
+			expOutcomes:            []bool{false, false, true},
+			expLoadedAdapters:      []string{apiutils.MergeModelAdapter(model3, adapter3)},
+			expBackendRequestCount: 3,
+		},
+		"500 adapter fails to load": {
+			reqBody:                fmt.Sprintf(`{"model":%q,"messages":[]}`, apiutils.MergeModelAdapter(model6, adapter6)),
+			expCode:                http.StatusInternalServerError,
+			expBody:                `{"error":"Internal Server Error"}` + "\n",
+			expLoadedAdapters:      []string{apiutils.MergeModelAdapter(model6, adapter6)},
+			expBackendRequestCount: 0,
+		},
 		"404 model+adapter in body but missing adapter": {
 			reqBody: fmt.Sprintf(`{"model":%q,"messages":[]}`, apiutils.MergeModelAdapter(model1, "no-such-adapter")),
 			expCode: http.StatusNotFound,
@@ -134,6 +287,125 @@ func TestHandler(t *testing.T) {
 			},
 			expBackendRequestCount: 1,
 		},
+		"usage in response is accounted for": {
+			reqBody:     fmt.Sprintf(`{"model":%q,"messages":[]}`, apiutils.MergeModelAdapter(model3, adapter3)),
+			reqHeaders:  map[string]string{"X-Caller-ID": "team-a"},
+			backendCode: http.StatusOK,
+			backendBody: `{"choices":[],"usage":{"prompt_tokens":10,"completion_tokens":3,"total_tokens":13}}`,
+
+			expRewrittenReqBody: fmt.Sprintf(`{"model":%q,"messages":[]}`, adapter3),
+			expCode:             http.StatusOK,
+			expBody:             `{"choices":[],"usage":{"prompt_tokens":10,"completion_tokens":3,"total_tokens":13}}`,
+			expUsage: &usageTestSpec{
+				expModel:      model3,
+				expAdapter:    adapter3,
+				expCaller:     "team-a",
+				expPrompt:     10,
+				expCompletion: 3,
+			},
+			expConsumedTokens:      map[string]int64{"team-a": 13},
+			expBackendRequestCount: 1,
+		},
+		"rate limited caller": {
+			reqBody:    fmt.Sprintf(`{"model":%q,"messages":[]}`, model1),
+			reqHeaders: map[string]string{"X-Caller-ID": "rate-limited"},
+			expCode:    http.StatusTooManyRequests,
+			expBody:    `{"error":{"message":"Rate limit reached, please try again in 2s.","type":"requests","code":"rate_limit_exceeded"}}` + "\n",
+			expHeaders: map[string]string{
+				"Retry-After": "2",
+			},
+			expBackendRequestCount: 0,
+		},
+		"usage in stream is accounted for and omitted when not requested": {
+			reqBody:            fmt.Sprintf(`{"model":%q,"messages":[],"stream":true}`, model1),
+			backendCode:        http.StatusOK,
+			backendContentType: "text/event-stream",
+			backendBody:        streamedChunks + streamedUsageChunk + streamedDone,
+
+			expRewrittenReqBody: fmt.Sprintf(`{"model":%q,"messages":[],"stream":true,"stream_options":{"include_usage":true}}`, model1),
+			expCode:             http.StatusOK,
+			expBody:             streamedChunks + streamedDone,
+			expUsage: &usageTestSpec{
+				expModel:      model1,
+				expPrompt:     7,
+				expCompletion: 2,
+			},
+			expBackendRequestCount: 1,
+		},
+		"usage in stream is accounted for and forwarded when requested": {
+			reqBody:            fmt.Sprintf(`{"model":%q,"messages":[],"stream":true,"stream_options":{"include_usage":true}}`, model1),
+			reqHeaders:         map[string]string{"X-Caller-ID": "team-b"},
+			backendCode:        http.StatusOK,
+			backendContentType: "text/event-stream",
+			backendBody:        streamedChunks + streamedUsageChunk + streamedDone,
+
+			expCode: http.StatusOK,
+			expBody: streamedChunks + streamedUsageChunk + streamedDone,
+			expUsage: &usageTestSpec{
+				expModel:      model1,
+				expCaller:     "team-b",
+				expPrompt:     7,
+				expCompletion: 2,
+			},
+			expBackendRequestCount: 1,
+		},
+		"stream interrupted by backend": {
+			reqBody:            fmt.Sprintf(`{"model":%q,"messages":[],"stream":true}`, model1),
+			backendCode:        http.StatusOK,
+			backendContentType: "text/event-stream",
+			backendBody:        streamedChunks + `data: {"choices":[{"ind`,
+			backendInterrupts:  1,
+
+			expRewrittenReqBody: fmt.Sprintf(`{"model":%q,"messages":[],"stream":true,"stream_options":{"include_usage":true}}`, model1),
+			expCode:             http.StatusOK,
+			expBody: streamedChunks +
+				`data: {"error":{"message":"The model backend failed while streaming the response, please retry the request.","type":"server_error","code":"stream_interrupted"}}` + "\n\n" +
+				streamedDone,
+			expStreamsInterrupted:  1,
+			expBackendRequestCount: 1,
+		},
+		"interrupted response is retried": {
+			reqBody:                   fmt.Sprintf(`{"model":%q,"messages":[]}`, model1),
+			retryInterruptedResponses: true,
+			backendCode:               http.StatusOK,
+			backendBody:               `{"result":"ok"}`,
+			backendInterrupts:         2,
+
+			expCode:                http.StatusOK,
+			expBody:                `{"result":"ok"}`,
+			expBackendRequestCount: 3,
+		},
+		"interrupted response exceeds retries": {
+			reqBody:                   fmt.Sprintf(`{"model":%q,"messages":[]}`, model1),
+			retryInterruptedResponses: true,
+			backendCode:               http.StatusOK,
+			backendBody:               `{"result":"ok"}`,
+			backendInterrupts:         1 + maxRetries,
+
+			expCode:                http.StatusBadGateway,
+			expBody:                `{"error":"Bad Gateway"}` + "\n",
+			expBackendRequestCount: 1 + maxRetries,
+		},
+		"queue full": {
+			reqBody:  fmt.Sprintf(`{"model":%q,"messages":[]}`, model1),
+			awaitErr: loadbalancer.ErrQueueFull,
+			expCode:  http.StatusTooManyRequests,
+			expBody:  `{"error":"request queue is full"}` + "\n",
+			expHeaders: map[string]string{
+				"Retry-After": "1",
+			},
+			expBackendRequestCount: 0,
+		},
+		"queue wait exceeded": {
+			reqBody:  fmt.Sprintf(`{"model":%q,"messages":[]}`, model1),
+			awaitErr: loadbalancer.ErrQueueWaitExceeded,
+			expCode:  http.StatusServiceUnavailable,
+			expBody:  `{"error":"Service Unavailable"}` + "\n",
+			expHeaders: map[string]string{
+				"Retry-After": "1",
+			},
+			expBackendRequestCount: 0,
+		},
 		"retryable 500": {
 			reqBody:     fmt.Sprintf(`{"model":%q,"messages":[]}`, model1),
 			backendCode: http.StatusInternalServerError,
@@ -156,6 +428,82 @@ func TestHandler(t *testing.T) {
 			},
 			expBackendRequestCount: 1,
 		},
+		"unavailable model falls back": {
+			reqBody:             fmt.Sprintf(`{"model":%q,"messages":[]}`, model4),
+			expRewrittenReqBody: fmt.Sprintf(`{"model":%q,"messages":[]}`, model1),
+			backendCode:         http.StatusOK,
+			backendBody:         `{"result":"ok"}`,
+			expCode:             http.StatusOK,
+			expBody:             `{"result":"ok"}`,
+			expMetrics: &metricsTestSpec{
+				expModel: model4,
+			},
+			expFallback: &fallbackTestSpec{
+				expModel:    model4,
+				expFallback: model1,
+				expReason:   "unavailable",
+			},
+			expHeaders:             map[string]string{servedModelHeader: model1},
+			expBackendRequestCount: 1,
+		},
+		"repeated errors fall back to the next available model": {
+			reqBody:            fmt.Sprintf(`{"model":%q,"messages":[]}`, model5),
+			backendFailures:    1 + maxRetries,
+			expFallbackReqBody: fmt.Sprintf(`{"model":%q,"messages":[]}`, adapter3),
+			backendCode:        http.StatusOK,
+			backendBody:        `{"result":"ok"}`,
+			expCode:            http.StatusOK,
+			expBody:            `{"result":"ok"}`,
+			expFallback: &fallbackTestSpec{
+				expModel:    model5,
+				expFallback: apiutils.MergeModelAdapter(model3, adapter3),
+				expReason:   "errors",
+			},
+			expHeaders:             map[string]string{servedModelHeader: apiutils.MergeModelAdapter(model3, adapter3)},
+			expOutcomes:            []bool{false, false, false, false, true},
+			expLoadedAdapters:      []string{apiutils.MergeModelAdapter(model3, adapter3)},
+			expBackendRequestCount: 1 + maxRetries + 1,
+		},
+		"repeated errors without available fallback": {
+			reqBody:                fmt.Sprintf(`{"model":%q,"messages":[]}`, model5),
+			backendFailures:        1 + maxRetries + 1 + maxRetries,
+			expFallbackReqBody:     fmt.Sprintf(`{"model":%q,"messages":[]}`, adapter3),
+			expCode:                http.StatusServiceUnavailable,
+			expHeaders:             map[string]string{servedModelHeader: apiutils.MergeModelAdapter(model3, adapter3)},
+			expBackendRequestCount: 1 + maxRetries + 1 + maxRetries,
+		},
+		"model scheduled to zero replicas": {
+			reqBody:                fmt.Sprintf(`{"model":%q,"messages":[]}`, model7),
+			expCode:                http.StatusServiceUnavailable,
+			expBody:                `{"error":"Service Unavailable"}` + "\n",
+			expHeaders:             map[string]string{"Retry-After": "5400"},
+			expBackendRequestCount: 0,
+		},
+		"model scheduled to zero replicas falls back": {
+			reqBody:             fmt.Sprintf(`{"model":%q,"messages":[]}`, model8),
+			expRewrittenReqBody: fmt.Sprintf(`{"model":%q,"messages":[]}`, model1),
+			backendCode:         http.StatusOK,
+			backendBody:         `{"result":"ok"}`,
+			expCode:             http.StatusOK,
+			expBody:             `{"result":"ok"}`,
+			expFallback: &fallbackTestSpec{
+				expModel:    model8,
+				expFallback: model1,
+				expReason:   "unavailable",
+			},
+			expHeaders:             map[string]string{servedModelHeader: model1},
+			expBackendRequestCount: 1,
+		},
+		"retry with one in-flight request per endpoint": {
+			reqBody:                fmt.Sprintf(`{"model":%q,"messages":[]}`, model9),
+			backendFailures:        1,
+			backendCode:            http.StatusOK,
+			backendBody:            `{"result":"ok"}`,
+			expCode:                http.StatusOK,
+			expBody:                `{"result":"ok"}`,
+			expOutcomes:            []bool{false, true},
+			expBackendRequestCount: 2,
+		},
 		"good request but dropped connection": {
 			reqBody:      fmt.Sprintf(`{"model":%q,"messages":[]}`, model1),
 			backendPanic: true,
@@ -164,6 +512,7 @@ func TestHandler(t *testing.T) {
 			expMetrics: &metricsTestSpec{
 				expModel: model1,
 			},
+			expOutcomes:            []bool{false, false, false, false},
 			expBackendRequestCount: 1 + maxRetries,
 		},
 	}
@@ -185,7 +534,9 @@ func TestHandler(t *testing.T) {
 				bdy, err := io.ReadAll(r.Body)
 				assert.NoError(t, err, "The request body should be readable")
 
-				if spec.expRewrittenReqBody != "" {
+				if spec.expFallbackReqBody != "" && backendRequestCount > 1+maxRetries {
+					assert.Equal(t, spec.expFallbackReqBody, string(bdy), "The request body for the fallback model should reach the backend")
+				} else if spec.expRewrittenReqBody != "" {
 					assert.Equal(t, spec.expRewrittenReqBody, string(bdy), "The rewritten request body should reach the backend")
 				} else {
 					assert.Equal(t, spec.reqBody, string(bdy), "The exact request body should reach the backend")
@@ -197,20 +548,42 @@ func TestHandler(t *testing.T) {
 					panic("panicing on purpose")
 				}
 
+				if backendRequestCount <= spec.backendFailures {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+
+				if spec.backendContentType != "" {
+					w.Header().Set("Content-Type", spec.backendContentType)
+				}
 				if spec.backendCode != 0 {
 					w.WriteHeader(spec.backendCode)
 				}
 				if spec.backendBody != "" {
 					_, _ = w.Write([]byte(spec.backendBody))
 				}
+				if backendRequestCount <= spec.backendInterrupts {
+					// Send what was written so far and then drop the connection.
+					w.(http.Flusher).Flush()
+					panic(http.ErrAbortHandler)
+				}
 			}))
 
 			// Setup handler.
 			testInf := &testModelInterface{
-				models:  models,
-				address: backend.Listener.Addr().String(),
+				models:   models,
+				address:  backend.Listener.Addr().String(),
+				awaitErr: spec.awaitErr,
 			}
-			h := NewHandler(testInf, testInf, maxRetries, nil)
+			testLimiter := &testRateLimiter{
+				limited:  map[string]time.Duration{"rate-limited": 1500 * time.Millisecond},
+				consumed: map[string]int64{},
+			}
+			h := NewHandler(testInf, testInf, maxRetries, nil, config.ModelProxy{
+				CallerHeader:              "X-Caller-ID",
+				RetryInterruptedResponses: spec.retryInterruptedResponses,
+				AllowPriorityHeader:       spec.allowPriorityHeader,
+			}, testLimiter, testInf)
 			server := httptest.NewServer(h)
 
 			// Issue request.
@@ -253,27 +626,75 @@ func TestHandler(t *testing.T) {
 			assert.Equal(t, spec.expBody, string(respBody), "Unexpected response body to client")
 			assert.Equal(t, spec.expBackendRequestCount, backendRequestCount, "Unexpected number of requests sent to backend")
 			assert.Equal(t, spec.expBackendRequestCount, testInf.hostRequestCount, "Unexpected number of requests for backend hosts")
+			for k, v := range spec.expHeaders {
+				assert.Equal(t, v, resp.Header.Get(k), "Unexpected response header %q", k)
+			}
+			if spec.expStreamsInterrupted != 0 {
+				mets := metricstest.Collect(t)
+				metricstest.RequireStreamsInterruptedMetric(t, mets, model1, spec.expStreamsInterrupted)
+			}
+			if spec.expOutcomes != nil {
+				assert.Equal(t, spec.expOutcomes, testInf.outcomes, "Unexpected outcomes reported to the load balancer")
+			}
+			if spec.expFallback != nil {
+				mets := metricstest.Collect(t)
+				metricstest.RequireFallbackMetric(t, mets,
+					spec.expFallback.expModel, spec.expFallback.expFallback, spec.expFallback.expReason, 1)
+			}
+			if spec.expConsumedTokens != nil {
+				assert.Equal(t, spec.expConsumedTokens, testLimiter.consumed, "Unexpected tokens accounted for in rate limits")
+			}
+			if spec.expBackendRequestCount > 0 {
+				assert.Equal(t, spec.expPriority, testInf.requestedPriority, "Unexpected request priority")
+			}
+			if spec.expLoadedAdapters != nil {
+				assert.Equal(t, spec.expLoadedAdapters, testInf.loadedAdapters, "Unexpected adapters loaded")
+			}
 
 			// Assert on metrics after the request is responded to.
 			if spec.expMetrics != nil {
 				mets := metricstest.Collect(t)
 				metricstest.RequireActiveRequestsMetric(t, mets, spec.expMetrics.expModel, 0)
 			}
+			if spec.expUsage != nil {
+				mets := metricstest.Collect(t)
+				metricstest.RequireTokenUsageMetrics(t, mets,
+					spec.expUsage.expModel, spec.expUsage.expAdapter, spec.expUsage.expCaller,
+					spec.expUsage.expPrompt, spec.expUsage.expCompletion)
+			}
 		})
 	}
 }
 
 type testMockModel struct {
 	adapters map[string]bool
+	fallback []string
+	// unavailable models have no endpoints.
+	unavailable bool
+	// adapterLoadErr is returned when loading an adapter of the model.
+	adapterLoadErr error
+	// scheduledFor is set for models that are scheduled to have 0 replicas.
+	scheduledFor time.Duration
+	// maxConcurrency is the MaxConcurrencyPerEndpoint of the model.
+	maxConcurrency int
 }
 
 type testModelInterface struct {
-	address string
+	address  string
+	awaitErr error
 
-	requestedModel   string
-	requestedAdapter string
+	requestedModel    string
+	requestedAdapter  string
+	requestedPriority int32
 
 	hostRequestCount int
+	loadedAdapters   []string
+	// inFlight are the requests that hold an address.
+	inFlight int
+
+	// outcomes records the success of each request reported to the load balancer.
+	outcomesMtx sync.Mutex
+	outcomes    []bool
 
 	models map[string]testMockModel
 }
@@ -281,26 +702,133 @@ type testModelInterface struct {
 func (t *testModelInterface) LookupModel(ctx context.Context, model, adapter string, selector []string) (*v1.Model, error) {
 	m, ok := t.models[model]
 	if ok {
+		k8sModel := &v1.Model{
+			ObjectMeta: metav1.ObjectMeta{Name: model},
+			Spec: v1.ModelSpec{
+				Fallback:      m.fallback,
+				LoadBalancing: v1.LoadBalancing{MaxConcurrencyPerEndpoint: m.maxConcurrency},
+			},
+		}
 		if adapter == "" {
-			return &v1.Model{ObjectMeta: metav1.ObjectMeta{Name: model}}, nil
+			return k8sModel, nil
 		}
 		if m.adapters == nil {
 			return nil, nil
 		}
 		if m.adapters[adapter] {
-			return &v1.Model{ObjectMeta: metav1.ObjectMeta{Name: model}}, nil
+			return k8sModel, nil
 		}
 	}
 	return nil, nil
 }
 
+func (t *testModelInterface) LookupModelAlias(ctx context.Context, name string, selectors []string) (*v1.ModelAlias, error) {
+	return nil, nil
+}
+
 func (t *testModelInterface) ScaleAtLeastOneReplica(ctx context.Context, model string) error {
+	if d := t.models[model].scheduledFor; d > 0 {
+		return &modelclient.ScheduledToZeroError{Model: model, Until: time.Now().Add(d)}
+	}
 	return nil
 }
 
+func (t *testModelInterface) LoadAdapter(ctx context.Context, model, adapter string) error {
+	t.loadedAdapters = append(t.loadedAdapters, apiutils.MergeModelAdapter(model, adapter))
+	return t.models[model].adapterLoadErr
+}
+
 func (t *testModelInterface) AwaitBestAddress(ctx context.Context, req *apiutils.Request) (string, func(), error) {
+	if t.awaitErr != nil {
+		err := t.awaitErr
+		if errors.Is(err, loadbalancer.ErrAdapterNotLoaded) {
+			// The adapter is available once it was loaded again.
+			t.awaitErr = nil
+		}
+		return "", func() {}, err
+	}
+	if limit := req.LoadBalancing.MaxConcurrencyPerEndpoint; limit > 0 && t.inFlight >= limit {
+		// Unlike the load balancer, requests are not queued until an
+		// endpoint is available.
+		return "", func() {}, errors.New("all endpoints are at their concurrency limit")
+	}
 	t.hostRequestCount++
+	t.inFlight++
 	t.requestedModel = req.Model
 	t.requestedAdapter = req.Adapter
-	return t.address, func() {}, nil
+	t.requestedPriority = req.Priority
+	return t.address, func() { t.inFlight-- }, nil
+}
+
+func (t *testModelInterface) GetAllAddresses(model string) []string {
+	if t.models[model].unavailable {
+		return nil
+	}
+	return []string{t.address}
+}
+
+func (t *testModelInterface) RecordOutcome(model, address string, failed bool, latency time.Duration) {
+	t.outcomesMtx.Lock()
+	defer t.outcomesMtx.Unlock()
+	t.outcomes = append(t.outcomes, !failed)
+}
+
+type testRateLimiter struct {
+	// limited maps rate limited keys to the duration after which to retry.
+	limited map[string]time.Duration
+
+	mtx      sync.Mutex
+	consumed map[string]int64
+}
+
+func (l *testRateLimiter) Key(caller string, selectors []string) string {
+	return caller
+}
+
+func (l *testRateLimiter) Allow(key string) (time.Duration, bool) {
+	retryAfter, limited := l.limited[key]
+	return retryAfter, !limited
+}
+
+func (l *testRateLimiter) ConsumeTokens(key string, n int64) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.consumed[key] += n
+}
+
+func TestBufferBody(t *testing.T) {
+	newResponse := func(body string, contentLength int64) (*http.Response, *testBody) {
+		b := &testBody{Reader: strings.NewReader(body)}
+		return &http.Response{Body: b, ContentLength: contentLength}, b
+	}
+
+	r, backendBody := newResponse("0123456789", -1)
+	require.NoError(t, bufferBody(r, 10))
+	require.True(t, backendBody.closed, "the backend body should be closed once it was buffered")
+	got, err := io.ReadAll(r.Body)
+	require.NoError(t, err)
+	require.Equal(t, "0123456789", string(got))
+
+	r, backendBody = newResponse("0123456789", -1)
+	require.NoError(t, bufferBody(r, 5))
+	require.False(t, backendBody.closed, "bodies over the limit should be streamed")
+	got, err = io.ReadAll(r.Body)
+	require.NoError(t, err)
+	require.Equal(t, "0123456789", string(got))
+	require.NoError(t, r.Body.Close())
+	require.True(t, backendBody.closed)
+
+	r, backendBody = newResponse("0123456789", 10)
+	require.NoError(t, bufferBody(r, 5))
+	require.Same(t, backendBody, r.Body, "bodies with a known length over the limit should not be read")
+}
+
+type testBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *testBody) Close() error {
+	b.closed = true
+	return nil
 }
@@ -6,8 +6,12 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/kubeai-project/kubeai/internal/apikeys"
 	"github.com/kubeai-project/kubeai/internal/apiutils"
 )
 
@@ -20,6 +24,15 @@ type proxyRequest struct {
 	http    *http.Request
 	status  int
 	attempt int
+	// fallback is the remaining list of fallback Models that
+	// have not been tried yet.
+	fallback []string
+
+	// caller identifies the client that sent the request.
+	caller string
+	// consumeTokens accounts for token usage against the rate limit
+	// of the caller (if any).
+	consumeTokens func(n int64)
 }
 
 func (h *Handler) parseProxyRequest(r *http.Request) (*proxyRequest, error) {
@@ -27,6 +40,17 @@ func (h *Handler) parseProxyRequest(r *http.Request) (*proxyRequest, error) {
 		http:   r,
 		status: http.StatusOK,
 	}
+	if key, ok := apikeys.FromContext(r.Context()); ok {
+		// Prefer the authenticated identity over a header that
+		// could be set to anything by the client.
+		pr.caller = key.Name
+	} else if h.callerHeader != "" {
+		pr.caller = r.Header.Get(h.callerHeader)
+	}
+
+	if !h.allowPriorityHeader {
+		r.Header.Del("X-Priority")
+	}
 
 	apiReq, err := apiutils.ParseRequest(r.Context(), h.modelClient, r.Body, r.URL.Path, r.Header)
 	if err != nil {
@@ -35,6 +59,7 @@ func (h *Handler) parseProxyRequest(r *http.Request) (*proxyRequest, error) {
 	// The content length might have changed after the body was read and rewritten.
 	r.ContentLength = apiReq.ContentLength
 	pr.Request = apiReq
+	pr.fallback = apiReq.Fallback
 
 	return pr, nil
 }
@@ -62,6 +87,40 @@ func (pr *proxyRequest) sendErrorResponse(w http.ResponseWriter, status int, for
 	}
 }
 
+// retryAfterSeconds formats a duration as the value of a Retry-After header,
+// which is specified in whole seconds.
+func retryAfterSeconds(d time.Duration) string {
+	return strconv.Itoa(max(1, int(math.Ceil(d.Seconds()))))
+}
+
+// sendRateLimitResponse sends an OpenAI-style rate limit error
+// that tells the client when to retry.
+func (pr *proxyRequest) sendRateLimitResponse(w http.ResponseWriter, retryAfter time.Duration) {
+	log.Printf("sending rate limit response: caller %q: retry after %v", pr.caller, retryAfter)
+
+	w.Header().Set("Retry-After", retryAfterSeconds(retryAfter))
+	w.Header().Set("Content-Type", "application/json")
+	pr.setStatus(w, http.StatusTooManyRequests)
+
+	if err := json.NewEncoder(w).Encode(struct {
+		Error openAIError `json:"error"`
+	}{
+		Error: openAIError{
+			Message: fmt.Sprintf("Rate limit reached, please try again in %v.", retryAfter.Round(time.Second)),
+			Type:    "requests",
+			Code:    "rate_limit_exceeded",
+		},
+	}); err != nil {
+		log.Printf("error encoding rate limit response: %v", err)
+	}
+}
+
+type openAIError struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	Code    string `json:"code"`
+}
+
 func (pr *proxyRequest) setStatus(w http.ResponseWriter, code int) {
 	pr.status = code
 	w.WriteHeader(code)
@@ -74,6 +133,8 @@ func (pr *proxyRequest) httpRequest() *http.Request {
 	clone := pr.http.Clone(pr.http.Context())
 	if pr.Body != nil {
 		clone.Body = io.NopCloser(bytes.NewReader(pr.Body))
+		// The body might have been rewritten (i.e. when falling back to another model).
+		clone.ContentLength = int64(len(pr.Body))
 	}
 	return clone
 }
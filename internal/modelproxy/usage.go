@@ -0,0 +1,231 @@
+package modelproxy
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+	openaiv1 "github.com/kubeai-project/kubeai/api/openai/v1"
+	"github.com/kubeai-project/kubeai/internal/apiutils"
+	"github.com/kubeai-project/kubeai/internal/metrics"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// usageBody wraps the body of a successful backend response so that the
+// token usage reported by the backend is recorded as the body is proxied.
+// Streamed responses are also guarded against backend failures.
+func (pr *proxyRequest) usageBody(r *http.Response) io.ReadCloser {
+	path := pr.http.URL.Path
+	if isEventStream(r) {
+		// The body might be shortened by dropping the usage chunk
+		// or changed when the stream is interrupted.
+		r.Header.Del("Content-Length")
+		r.ContentLength = -1
+		return &sseBody{
+			body:           r.Body,
+			src:            bufio.NewReader(r.Body),
+			dropUsageChunk: pr.StreamUsageInjected,
+			onUsage:        pr.recordUsage,
+			onInterrupt:    pr.interruptStream,
+		}
+	}
+	return newJSONUsageBody(path, r.Body, pr.recordUsage)
+}
+
+func (pr *proxyRequest) recordUsage(usage *openaiv1.CompletionUsage) {
+	metricAttrs := metric.WithAttributeSet(attribute.NewSet(
+		metrics.AttrRequestModel.String(pr.Model),
+		metrics.AttrRequestAdapter.String(pr.Adapter),
+		metrics.AttrRequestCaller.String(pr.caller),
+		metrics.AttrRequestType.String(metrics.AttrRequestTypeHTTP),
+	))
+	metrics.InferenceTokensPrompt.Add(pr.http.Context(), int64(usage.PromptTokens), metricAttrs)
+	metrics.InferenceTokensCompletion.Add(pr.http.Context(), int64(usage.CompletionTokens), metricAttrs)
+	if pr.consumeTokens != nil {
+		pr.consumeTokens(int64(usage.PromptTokens + usage.CompletionTokens))
+	}
+}
+
+// jsonUsageBody inspects a JSON response body while it is being read.
+// The body is streamed to a decoder (instead of being buffered) to avoid
+// holding large responses in memory.
+type jsonUsageBody struct {
+	io.ReadCloser
+	pw   *io.PipeWriter
+	done chan struct{}
+}
+
+func newJSONUsageBody(path string, body io.ReadCloser, onUsage func(*openaiv1.CompletionUsage)) *jsonUsageBody {
+	pr, pw := io.Pipe()
+	b := &jsonUsageBody{
+		ReadCloser: body,
+		pw:         pw,
+		done:       make(chan struct{}),
+	}
+	go func() {
+		defer close(b.done)
+		usage, err := apiutils.ReadUsage(path, pr)
+		// Unblock any writes once the decoder is finished.
+		pr.CloseWithError(io.ErrClosedPipe)
+		if err != nil {
+			log.Printf("unable to read usage from response: %v", err)
+			return
+		}
+		if usage != nil {
+			onUsage(usage)
+		}
+	}()
+	return b
+}
+
+func (b *jsonUsageBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if b.pw == nil {
+		return n, err
+	}
+	if n > 0 {
+		if _, werr := b.pw.Write(p[:n]); werr != nil {
+			// The decoder is no longer reading.
+			b.pw = nil
+			return n, err
+		}
+	}
+	if err == io.EOF {
+		// Wait for the usage to be recorded before signaling EOF
+		// so that it is accounted for before the response completes.
+		b.pw.Close()
+		b.pw = nil
+		<-b.done
+	}
+	return n, err
+}
+
+func (b *jsonUsageBody) Close() error {
+	if b.pw != nil {
+		// The body was not fully read.
+		b.pw.CloseWithError(io.ErrUnexpectedEOF)
+		b.pw = nil
+	}
+	return b.ReadCloser.Close()
+}
+
+// interruptStream records a streamed response that failed before it was
+// complete and returns the events that end the stream for the client.
+// It returns nil if the client went away.
+func (pr *proxyRequest) interruptStream(err error) []byte {
+	if pr.http.Context().Err() != nil {
+		return nil
+	}
+	log.Printf("backend stream interrupted: %v: %v", pr.ID, err)
+	metrics.InferenceStreamsInterrupted.Add(pr.http.Context(), 1, metric.WithAttributeSet(attribute.NewSet(
+		metrics.AttrRequestModel.String(pr.RequestedModel),
+		metrics.AttrRequestType.String(metrics.AttrRequestTypeHTTP),
+	)))
+
+	payload, merr := json.Marshal(struct {
+		Error openAIError `json:"error"`
+	}{
+		Error: openAIError{
+			Message: "The model backend failed while streaming the response, please retry the request.",
+			Type:    "server_error",
+			Code:    "stream_interrupted",
+		},
+	})
+	if merr != nil {
+		log.Printf("error encoding stream error event: %v", merr)
+		return nil
+	}
+	return []byte("data: " + string(payload) + "\n\ndata: [DONE]\n\n")
+}
+
+// sseBody inspects a server-sent-events response body event by event.
+// The usage chunk that a backend sends at the end of the stream is recorded
+// and optionally dropped (when the client did not ask for it).
+// If the backend fails mid-stream, the stream is ended with an error event
+// (instead of being cut off) so that clients can tell what happened.
+type sseBody struct {
+	body           io.ReadCloser
+	src            *bufio.Reader
+	out            bytes.Buffer
+	err            error
+	dropUsageChunk bool
+	onUsage        func(*openaiv1.CompletionUsage)
+	onInterrupt    func(error) []byte
+}
+
+func (b *sseBody) Read(p []byte) (int, error) {
+	for b.out.Len() == 0 {
+		if b.err != nil {
+			return 0, b.err
+		}
+		b.readEvent()
+	}
+	return b.out.Read(p)
+}
+
+func (b *sseBody) Close() error {
+	return b.body.Close()
+}
+
+// readEvent reads a single event (terminated by an empty line) from the
+// source and writes it to the output buffer unless it should be dropped.
+func (b *sseBody) readEvent() {
+	var event []byte
+	for {
+		line, err := b.src.ReadBytes('\n')
+		event = append(event, line...)
+		if err != nil {
+			b.err = err
+			if err != io.EOF && b.onInterrupt != nil {
+				if end := b.onInterrupt(err); end != nil {
+					// Drop the incomplete event.
+					b.out.Write(end)
+					b.err = io.EOF
+					return
+				}
+			}
+			break
+		}
+		if len(bytes.TrimRight(line, "\r\n")) == 0 {
+			break
+		}
+	}
+	if b.inspectEvent(event) {
+		return
+	}
+	b.out.Write(event)
+}
+
+// inspectEvent records any usage found in the event and returns true if
+// the event should be dropped.
+func (b *sseBody) inspectEvent(event []byte) (drop bool) {
+	for _, line := range bytes.Split(event, []byte("\n")) {
+		data, ok := bytes.CutPrefix(bytes.TrimRight(line, "\r"), []byte("data:"))
+		if !ok {
+			continue
+		}
+		data = bytes.TrimSpace(data)
+		if !bytes.Contains(data, []byte(`"usage"`)) {
+			continue
+		}
+		var chunk struct {
+			Choices []jsontext.Value          `json:"choices"`
+			Usage   *openaiv1.CompletionUsage `json:"usage"`
+		}
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			log.Printf("unable to parse streamed chunk: %v", err)
+			continue
+		}
+		if chunk.Usage == nil {
+			continue
+		}
+		b.onUsage(chunk.Usage)
+		drop = b.dropUsageChunk && len(chunk.Choices) == 0
+	}
+	return drop
+}
@@ -0,0 +1,85 @@
+// Package movingaverage smooths the measurements that models are autoscaled on.
+package movingaverage
+
+// Algorithm is a moving average algorithm.
+type Algorithm string
+
+const (
+	SimpleAlgorithm      Algorithm = "Simple"
+	ExponentialAlgorithm Algorithm = "Exponential"
+	MaxAlgorithm         Algorithm = "Max"
+	P90Algorithm         Algorithm = "P90"
+)
+
+// Average is a moving average over a window of measurements.
+// All methods are thread safe.
+type Average interface {
+	// Next adds a measurement.
+	Next(next float64)
+	// Calculate returns the current average.
+	Calculate() float64
+	// Algorithm returns the algorithm of the average.
+	Algorithm() Algorithm
+	// State returns the state that the average can be restored from.
+	State() State
+}
+
+// State is the state of an Average.
+type State struct {
+	Algorithm Algorithm `json:"algorithm"`
+	// Window are the measurements of windowed averages (oldest first).
+	Window []float64 `json:"window,omitempty"`
+	// Value is the value of exponential averages.
+	Value float64 `json:"value,omitempty"`
+	// Zeros is the number of consecutive zero measurements of exponential averages.
+	Zeros int `json:"zeros,omitempty"`
+}
+
+// New returns an average over a window of size measurements that starts at zero.
+func New(algorithm Algorithm, size int) Average {
+	return NewPrefilled(algorithm, size, 0)
+}
+
+// NewPrefilled returns an average over a window of size measurements that
+// starts as if every measurement in the window was the given value.
+func NewPrefilled(algorithm Algorithm, size int, value float64) Average {
+	return Restore(State{
+		Algorithm: algorithm,
+		Window:    []float64{value},
+		Value:     value,
+	}, size)
+}
+
+// Restore returns an average from its state. Windows that do not match the
+// size are truncated (dropping the oldest measurements) or padded with the
+// oldest measurement. Unknown algorithms fall back to the simple average.
+func Restore(state State, size int) Average {
+	switch state.Algorithm {
+	case ExponentialAlgorithm:
+		a := NewExponential(size)
+		a.value, a.zeros = state.Value, state.Zeros
+		return a
+	case MaxAlgorithm:
+		return NewMax(resize(state.Window, size))
+	case P90Algorithm:
+		return NewP90(resize(state.Window, size))
+	default:
+		return NewSimple(resize(state.Window, size))
+	}
+}
+
+func resize(window []float64, size int) []float64 {
+	if len(window) >= size {
+		return append([]float64(nil), window[len(window)-size:]...)
+	}
+	var pad float64
+	if len(window) > 0 {
+		pad = window[0]
+	}
+	result := make([]float64, size)
+	for i := range size - len(window) {
+		result[i] = pad
+	}
+	copy(result[size-len(window):], window)
+	return result
+}
@@ -0,0 +1,137 @@
+package movingaverage_test
+
+import (
+	"testing"
+
+	"github.com/kubeai-project/kubeai/internal/movingaverage"
+)
+
+func TestAlgorithms(t *testing.T) {
+	cases := []struct {
+		name      string
+		algorithm movingaverage.Algorithm
+		values    []float64
+		want      float64
+	}{
+		{
+			name:      "simple",
+			algorithm: movingaverage.SimpleAlgorithm,
+			values:    []float64{10, 0, 0, 0},
+			want:      2.5,
+		},
+		{
+			name:      "exponential weights recent values",
+			algorithm: movingaverage.ExponentialAlgorithm,
+			values:    []float64{10},
+			want:      4,
+		},
+		{
+			name:      "exponential snaps to zero",
+			algorithm: movingaverage.ExponentialAlgorithm,
+			values:    []float64{10, 0, 0, 0, 0},
+			want:      0,
+		},
+		{
+			name:      "exponential does not snap to zero before window",
+			algorithm: movingaverage.ExponentialAlgorithm,
+			values:    []float64{10, 0, 0, 0},
+			want:      0.864,
+		},
+		{
+			name:      "max",
+			algorithm: movingaverage.MaxAlgorithm,
+			values:    []float64{1, 10, 2, 3},
+			want:      10,
+		},
+		{
+			name:      "max after spike left window",
+			algorithm: movingaverage.MaxAlgorithm,
+			values:    []float64{1, 10, 2, 3, 4, 1},
+			want:      4,
+		},
+		{
+			name:      "p90",
+			algorithm: movingaverage.P90Algorithm,
+			values:    []float64{5, 1, 4, 2},
+			// Windows of less than 10 values are the same as Max.
+			want: 5,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			a := movingaverage.New(tc.algorithm, 4)
+			for _, v := range tc.values {
+				a.Next(v)
+			}
+			got := a.Calculate()
+			if diff := got - tc.want; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("got %v; want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestP90(t *testing.T) {
+	a := movingaverage.New(movingaverage.P90Algorithm, 10)
+	for _, v := range []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 100} {
+		a.Next(v)
+	}
+	if got := a.Calculate(); got != 9 {
+		t.Errorf("got %v; want 9", got)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	for _, algorithm := range []movingaverage.Algorithm{
+		movingaverage.SimpleAlgorithm,
+		movingaverage.ExponentialAlgorithm,
+		movingaverage.MaxAlgorithm,
+		movingaverage.P90Algorithm,
+	} {
+		t.Run(string(algorithm), func(t *testing.T) {
+			a := movingaverage.New(algorithm, 5)
+			for _, v := range []float64{3, 0, 7, 1} {
+				a.Next(v)
+			}
+			restored := movingaverage.Restore(a.State(), 5)
+			if restored.Algorithm() != algorithm {
+				t.Fatalf("got algorithm %v; want %v", restored.Algorithm(), algorithm)
+			}
+			// The restored average should evolve like the original.
+			for _, v := range []float64{0, 0, 2} {
+				a.Next(v)
+				restored.Next(v)
+				if got, want := restored.Calculate(), a.Calculate(); got != want {
+					t.Errorf("got %v; want %v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPrefilled(t *testing.T) {
+	for _, algorithm := range []movingaverage.Algorithm{
+		movingaverage.SimpleAlgorithm,
+		movingaverage.ExponentialAlgorithm,
+		movingaverage.MaxAlgorithm,
+		movingaverage.P90Algorithm,
+	} {
+		t.Run(string(algorithm), func(t *testing.T) {
+			if got := movingaverage.NewPrefilled(algorithm, 5, 5.5).Calculate(); got != 5.5 {
+				t.Errorf("got %v; want 5.5", got)
+			}
+		})
+	}
+}
+
+func TestRestoreResizesWindow(t *testing.T) {
+	state := movingaverage.State{Algorithm: movingaverage.MaxAlgorithm, Window: []float64{9, 1, 2}}
+	// The oldest measurement is dropped.
+	if got := movingaverage.Restore(state, 2).Calculate(); got != 2 {
+		t.Errorf("got %v; want 2", got)
+	}
+	// The window is padded with the oldest measurement.
+	if got := movingaverage.Restore(state, 5).State().Window; len(got) != 5 || got[0] != 9 || got[1] != 9 {
+		t.Errorf("got %v; want [9 9 9 1 2]", got)
+	}
+}
@@ -0,0 +1,55 @@
+package movingaverage
+
+import (
+	"sync"
+)
+
+// Exponential is an exponential moving average that weights recent
+// measurements more than a Simple average over a window of the same size
+// (smoothing factor 2/(size+1)), so it reacts faster to spikes.
+// An exponential average never reaches zero on its own, so it snaps to zero
+// after size consecutive zero measurements (like a Simple average would).
+type Exponential struct {
+	mtx   sync.Mutex
+	size  int
+	alpha float64
+	value float64
+	zeros int
+}
+
+func NewExponential(size int) *Exponential {
+	return &Exponential{
+		size:  size,
+		alpha: 2 / float64(size+1),
+	}
+}
+
+func (a *Exponential) Next(next float64) {
+	a.mtx.Lock()
+	a.value = a.alpha*next + (1-a.alpha)*a.value
+	if next == 0 {
+		a.zeros++
+	} else {
+		a.zeros = 0
+	}
+	if a.zeros >= a.size {
+		a.value = 0
+	}
+	a.mtx.Unlock()
+}
+
+func (a *Exponential) Calculate() float64 {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	return a.value
+}
+
+func (a *Exponential) Algorithm() Algorithm {
+	return ExponentialAlgorithm
+}
+
+func (a *Exponential) State() State {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	return State{Algorithm: ExponentialAlgorithm, Value: a.value, Zeros: a.zeros}
+}
@@ -1,6 +1,7 @@
 package movingaverage_test
 
 import (
+	"slices"
 	"testing"
 
 	"github.com/kubeai-project/kubeai/internal/movingaverage"
@@ -57,3 +58,14 @@ func TestSimple(t *testing.T) {
 		})
 	}
 }
+
+func TestSimpleHistoryIsOrdered(t *testing.T) {
+	a := movingaverage.NewSimple(make([]float64, 3))
+	for _, v := range []float64{1, 2, 3, 4} {
+		a.Next(v)
+	}
+	got := a.History()
+	if want := []float64{2, 3, 4}; !slices.Equal(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,95 @@
+package movingaverage
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// window is a ring buffer of the measurements of windowed averages.
+type window struct {
+	mtx     sync.Mutex
+	history []float64
+	index   int
+}
+
+func (a *window) Next(next float64) {
+	a.mtx.Lock()
+	a.history[a.index] = next
+	a.index++
+	if a.index == len(a.history) {
+		a.index = 0
+	}
+	a.mtx.Unlock()
+}
+
+// History returns the measurements in the window (oldest first).
+func (a *window) History() []float64 {
+	a.mtx.Lock()
+	result := make([]float64, 0, len(a.history))
+	result = append(result, a.history[a.index:]...)
+	result = append(result, a.history[:a.index]...)
+	a.mtx.Unlock()
+
+	return result
+}
+
+// Max returns the maximum of a history of measurements. It reacts to spikes
+// immediately and only goes down once the spike left the window.
+type Max struct {
+	window
+}
+
+func NewMax(seed []float64) *Max {
+	return &Max{
+		window: window{history: seed},
+	}
+}
+
+func (a *Max) Calculate() float64 {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	result := math.Inf(-1)
+	for _, p := range a.history {
+		result = max(result, p)
+	}
+	return result
+}
+
+func (a *Max) Algorithm() Algorithm {
+	return MaxAlgorithm
+}
+
+func (a *Max) State() State {
+	return State{Algorithm: MaxAlgorithm, Window: a.History()}
+}
+
+// P90 returns the 90th percentile (nearest rank) of a history of
+// measurements. Unlike Max, it ignores short spikes: the highest value is
+// only ignored once the history holds at least 10 measurements, before that
+// P90 equals Max.
+type P90 struct {
+	window
+}
+
+func NewP90(seed []float64) *P90 {
+	return &P90{
+		window: window{history: seed},
+	}
+}
+
+func (a *P90) Calculate() float64 {
+	sorted := a.History()
+	sort.Float64s(sorted)
+	rank := int(math.Ceil(0.9 * float64(len(sorted))))
+	return sorted[max(rank-1, 0)]
+}
+
+func (a *P90) Algorithm() Algorithm {
+	return P90Algorithm
+}
+
+func (a *P90) State() State {
+	return State{Algorithm: P90Algorithm, Window: a.History()}
+}
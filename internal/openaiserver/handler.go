@@ -1,25 +1,36 @@
 package openaiserver
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 
+	"github.com/kubeai-project/kubeai/internal/apikeys"
 	"github.com/kubeai-project/kubeai/internal/modelproxy"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+type Authenticator interface {
+	Authenticate(ctx context.Context, r *http.Request) (*apikeys.Key, error)
+}
+
 type Handler struct {
 	ModelProxy *modelproxy.Handler
 	K8sClient  client.Client
+	// Authenticator is used to authenticate API keys.
+	// If nil, requests are not authenticated.
+	Authenticator Authenticator
 	http.Handler
 }
 
-func NewHandler(k8sClient client.Client, modelProxy *modelproxy.Handler) *Handler {
+func NewHandler(k8sClient client.Client, modelProxy *modelproxy.Handler, authenticator Authenticator) *Handler {
 	h := &Handler{
-		K8sClient: k8sClient,
+		K8sClient:     k8sClient,
+		Authenticator: authenticator,
 	}
 
 	mux := http.NewServeMux()
@@ -27,7 +38,7 @@ func NewHandler(k8sClient client.Client, modelProxy *modelproxy.Handler) *Handle
 	// which enriches the handler's HTTP instrumentation with the pattern as the http.route.
 	handle := func(pattern string, routeHandler http.Handler) {
 		// Configure the "http.route" for the HTTP instrumentation.
-		mux.Handle(pattern, otelhttp.WithRouteTag(pattern, routeHandler))
+		mux.Handle(pattern, otelhttp.WithRouteTag(pattern, h.authenticate(routeHandler)))
 	}
 
 	// NOTE: Proxying all paths to backend engines is a security risk.
@@ -48,6 +59,26 @@ func NewHandler(k8sClient client.Client, modelProxy *modelproxy.Handler) *Handle
 	return h
 }
 
+// authenticate requires requests to present a valid API key (if an
+// Authenticator is configured) and stores the key in the request context.
+func (h *Handler) authenticate(next http.Handler) http.Handler {
+	if h.Authenticator == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, err := h.Authenticator.Authenticate(r.Context(), r)
+		if err != nil {
+			if errors.Is(err, apikeys.ErrMissingKey) || errors.Is(err, apikeys.ErrInvalidKey) {
+				sendErrorResponse(w, http.StatusUnauthorized, "%v", err)
+			} else {
+				sendErrorResponse(w, http.StatusInternalServerError, "authenticating: %v", err)
+			}
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(apikeys.NewContext(r.Context(), key)))
+	})
+}
+
 func sendErrorResponse(w http.ResponseWriter, status int, format string, args ...interface{}) {
 	msg := fmt.Sprintf(format, args...)
 	log.Printf("sending error response: %v: %v", status, msg)
@@ -3,8 +3,10 @@ package openaiserver
 import (
 	"encoding/json"
 	"net/http"
+	"slices"
 
 	kubeaiv1 "github.com/kubeai-project/kubeai/api/k8s/v1"
+	"github.com/kubeai-project/kubeai/internal/apikeys"
 	"github.com/kubeai-project/kubeai/internal/apiutils"
 	"k8s.io/apimachinery/pkg/labels"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -26,6 +28,9 @@ func (h *Handler) getModels(w http.ResponseWriter, r *http.Request) {
 
 	var listOpts []client.ListOption
 	headerSelectors := r.Header.Values("X-Label-Selector")
+	if key, ok := apikeys.FromContext(r.Context()); ok && key.Selector != "" {
+		headerSelectors = append(headerSelectors, key.Selector)
+	}
 	for _, sel := range headerSelectors {
 		parsedSel, err := labels.Parse(sel)
 		if err != nil {
@@ -61,6 +66,17 @@ func (h *Handler) getModels(w http.ResponseWriter, r *http.Request) {
 		models = append(models, k8sModelToOpenAIModels(k8sModel)...)
 	}
 
+	aliases := &kubeaiv1.ModelAliasList{}
+	if err := h.K8sClient.List(r.Context(), aliases, listOpts...); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "failed to list model aliases: %v", err)
+		return
+	}
+	for _, alias := range aliases.Items {
+		if m, ok := k8sModelAliasToOpenAIModel(alias, k8sModels); ok {
+			models = append(models, m)
+		}
+	}
+
 	// Wrapper struct to match the desired output format
 	response := struct {
 		Object string  `json:"object"`
@@ -107,3 +123,35 @@ func constructOpenAIModel(k8sM kubeaiv1.Model, adapter string) Model {
 	m.Features = k8sM.Spec.Features
 	return m
 }
+
+// k8sModelAliasToOpenAIModel returns the alias as a model if any of its
+// backends refer to one of the given (already filtered) Models. The features
+// of an alias are the union of the features of its backing Models.
+func k8sModelAliasToOpenAIModel(alias kubeaiv1.ModelAlias, k8sModels []kubeaiv1.Model) (Model, bool) {
+	m := Model{
+		ID:      alias.Name,
+		Created: alias.CreationTimestamp.Unix(),
+		Object:  "model",
+	}
+	var found bool
+	for _, route := range alias.Spec.Routes {
+		for _, backend := range route.Backends {
+			name, _ := apiutils.SplitModelAdapter(backend.Model)
+			for _, k8sModel := range k8sModels {
+				if k8sModel.Name != name {
+					continue
+				}
+				found = true
+				if m.OwnedBy == "" {
+					m.OwnedBy = k8sModel.Spec.Owner
+				}
+				for _, f := range k8sModel.Spec.Features {
+					if !slices.Contains(m.Features, f) {
+						m.Features = append(m.Features, f)
+					}
+				}
+			}
+		}
+	}
+	return m, found
+}
@@ -0,0 +1,213 @@
+package ratelimit
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kubeai-project/kubeai/internal/config"
+)
+
+// Peers reports the KubeAI replicas that the rate limits are divided among.
+type Peers interface {
+	GetSelfIPs() []string
+}
+
+// Limiter enforces per-caller request and token limits using token buckets.
+//
+// Limits are configured for all KubeAI replicas combined, but the replicas do
+// not share any state: each replica enforces an equal share of every limit
+// (see share). This approximates the combined limit as long as the requests
+// of a caller are spread evenly across replicas by the Service.
+// All methods are thread safe.
+type Limiter struct {
+	cfg   config.RateLimiting
+	peers Peers
+
+	mtx       sync.Mutex
+	callers   map[string]*callerState
+	lastSweep time.Time
+
+	// now is a hook for testing.
+	now func() time.Time
+}
+
+func NewLimiter(cfg config.RateLimiting, peers Peers) *Limiter {
+	return &Limiter{
+		cfg:     cfg,
+		peers:   peers,
+		callers: map[string]*callerState{},
+		now:     time.Now,
+	}
+}
+
+// Key returns the key that identifies the caller of a request, given the
+// identity of the caller and the label selectors of the request.
+func (l *Limiter) Key(caller string, selectors []string) string {
+	if l.cfg.KeyBy == config.RateLimitKeyByLabelSelector {
+		return strings.Join(selectors, ",")
+	}
+	return caller
+}
+
+type callerState struct {
+	requests bucket
+	tokens   bucket
+}
+
+// Allow reports whether a request from the given caller should be admitted.
+// If not, it returns the duration after which the caller should retry.
+func (l *Limiter) Allow(caller string) (time.Duration, bool) {
+	limit := l.limit(caller)
+	if limit.RequestsPerMinute == 0 && limit.TokensPerMinute == 0 {
+		return 0, true
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+	s := l.state(caller, limit, now)
+
+	var (
+		allowed    = true
+		retryAfter time.Duration
+	)
+	if limit.TokensPerMinute > 0 {
+		// Token usage is only known once a response is complete, so
+		// requests are admitted as long as the caller is not in debt.
+		capacity := l.share(limit.TokensPerMinute)
+		s.tokens.refill(now, capacity)
+		if s.tokens.level <= 0 {
+			allowed = false
+			retryAfter = max(retryAfter, s.tokens.timeUntil(0, capacity))
+		}
+	}
+	if limit.RequestsPerMinute > 0 {
+		capacity := l.share(limit.RequestsPerMinute)
+		s.requests.refill(now, capacity)
+		if s.requests.level < 1 {
+			allowed = false
+			retryAfter = max(retryAfter, s.requests.timeUntil(1, capacity))
+		}
+	}
+	if !allowed {
+		return retryAfter, false
+	}
+
+	if limit.RequestsPerMinute > 0 {
+		s.requests.level--
+	}
+	return 0, true
+}
+
+// ConsumeTokens accounts for tokens that were used in a response to the
+// given caller.
+func (l *Limiter) ConsumeTokens(caller string, n int64) {
+	limit := l.limit(caller)
+	if limit.TokensPerMinute == 0 || n <= 0 {
+		return
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	now := l.now()
+	s := l.state(caller, limit, now)
+	s.tokens.refill(now, l.share(limit.TokensPerMinute))
+	s.tokens.level -= float64(n)
+}
+
+func (l *Limiter) limit(caller string) config.RateLimit {
+	if limit, ok := l.cfg.Callers[caller]; ok {
+		return limit
+	}
+	return l.cfg.Default
+}
+
+// state returns the state of a caller, starting with full buckets.
+// Must be called with the lock held.
+func (l *Limiter) state(caller string, limit config.RateLimit, now time.Time) *callerState {
+	s, ok := l.callers[caller]
+	if !ok {
+		s = &callerState{
+			requests: bucket{level: burst(l.share(limit.RequestsPerMinute)), updated: now},
+			tokens:   bucket{level: burst(l.share(limit.TokensPerMinute)), updated: now},
+		}
+		l.callers[caller] = s
+	}
+	return s
+}
+
+// sweep removes the state of callers whose buckets have been refilled,
+// which is equivalent to starting over. This keeps the number of tracked
+// callers bounded when callers are identified by arbitrary header values.
+// Must be called with the lock held.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+
+	for caller, s := range l.callers {
+		limit := l.limit(caller)
+		requests, tokens := l.share(limit.RequestsPerMinute), l.share(limit.TokensPerMinute)
+		s.requests.refill(now, requests)
+		s.tokens.refill(now, tokens)
+		if s.requests.full(requests) && s.tokens.full(tokens) {
+			delete(l.callers, caller)
+		}
+	}
+}
+
+// share returns the share of a per-minute limit that this replica enforces:
+// the limit divided by the number of KubeAI replicas. It is a per-replica
+// approximation of the combined limit. Callers whose requests are not spread
+// evenly across replicas (i.e. because a client reuses a single connection)
+// are limited to less than the combined limit. While the number of replicas
+// changes, callers can briefly exceed it.
+func (l *Limiter) share(perMinute int64) float64 {
+	replicas := 1
+	if l.peers != nil {
+		// Before the replicas are discovered, assume this is the only replica.
+		replicas = max(1, len(l.peers.GetSelfIPs()))
+	}
+	return float64(perMinute) / float64(replicas)
+}
+
+// bucket is a token bucket that is refilled at a rate of
+// capacity per minute. The level can become negative when
+// more tokens are consumed than were available.
+type bucket struct {
+	level   float64
+	updated time.Time
+}
+
+func (b *bucket) refill(now time.Time, capacity float64) {
+	elapsed := now.Sub(b.updated)
+	b.updated = now
+	if elapsed <= 0 {
+		return
+	}
+	b.level = min(burst(capacity), b.level+capacity*elapsed.Minutes())
+}
+
+func (b *bucket) full(capacity float64) bool {
+	return capacity == 0 || b.level >= burst(capacity)
+}
+
+// burst returns the maximum level of a bucket. It is at least 1 so that
+// a request can be admitted even when a limit is shared by more replicas
+// than there are requests per minute.
+func burst(capacity float64) float64 {
+	return max(1, capacity)
+}
+
+// timeUntil returns the time until the bucket reaches the given level.
+func (b *bucket) timeUntil(level, capacity float64) time.Duration {
+	if b.level >= level || capacity <= 0 {
+		return 0
+	}
+	return time.Duration((level - b.level) / capacity * float64(time.Minute))
+}
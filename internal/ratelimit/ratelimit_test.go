@@ -0,0 +1,205 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kubeai-project/kubeai/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	type step struct {
+		// advance the clock before the step.
+		advance time.Duration
+		caller  string
+		// tokens is the number of tokens that are consumed by the caller
+		// (instead of sending a request).
+		tokens int64
+
+		expAllowed    bool
+		expRetryAfter time.Duration
+	}
+	cases := []struct {
+		name     string
+		cfg      config.RateLimiting
+		replicas int
+		steps    []step
+	}{
+		{
+			name: "unlimited",
+			steps: []step{
+				{caller: "a", expAllowed: true},
+				{caller: "a", tokens: 1_000_000},
+				{caller: "a", expAllowed: true},
+			},
+		},
+		{
+			name: "requests per minute",
+			cfg: config.RateLimiting{
+				Default: config.RateLimit{RequestsPerMinute: 2},
+			},
+			steps: []step{
+				{caller: "a", expAllowed: true},
+				{caller: "a", expAllowed: true},
+				{caller: "a", expAllowed: false, expRetryAfter: 30 * time.Second},
+				// Callers are limited independently.
+				{caller: "b", expAllowed: true},
+				{advance: 10 * time.Second, caller: "a", expAllowed: false, expRetryAfter: 20 * time.Second},
+				{advance: 20 * time.Second, caller: "a", expAllowed: true},
+				{caller: "a", expAllowed: false, expRetryAfter: 30 * time.Second},
+			},
+		},
+		{
+			name: "tokens per minute",
+			cfg: config.RateLimiting{
+				Default: config.RateLimit{TokensPerMinute: 600},
+			},
+			steps: []step{
+				{caller: "a", expAllowed: true},
+				{caller: "a", tokens: 500},
+				{caller: "a", expAllowed: true},
+				{caller: "a", tokens: 400},
+				// In debt by 300 tokens.
+				{caller: "a", expAllowed: false, expRetryAfter: 30 * time.Second},
+				{advance: 31 * time.Second, caller: "a", expAllowed: true},
+			},
+		},
+		{
+			name: "caller override",
+			cfg: config.RateLimiting{
+				Default: config.RateLimit{RequestsPerMinute: 1},
+				Callers: map[string]config.RateLimit{
+					"unlimited": {},
+				},
+			},
+			steps: []step{
+				{caller: "a", expAllowed: true},
+				{caller: "a", expAllowed: false, expRetryAfter: time.Minute},
+				{caller: "unlimited", expAllowed: true},
+				{caller: "unlimited", expAllowed: true},
+			},
+		},
+		{
+			name: "shared across replicas",
+			cfg: config.RateLimiting{
+				Default: config.RateLimit{RequestsPerMinute: 4},
+			},
+			replicas: 2,
+			steps: []step{
+				{caller: "a", expAllowed: true},
+				{caller: "a", expAllowed: true},
+				{caller: "a", expAllowed: false, expRetryAfter: 30 * time.Second},
+			},
+		},
+		{
+			name: "more replicas than requests per minute",
+			cfg: config.RateLimiting{
+				Default: config.RateLimit{RequestsPerMinute: 1},
+			},
+			replicas: 3,
+			steps: []step{
+				{caller: "a", expAllowed: true},
+				{caller: "a", expAllowed: false, expRetryAfter: 3 * time.Minute},
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			now := time.Now()
+			l := NewLimiter(c.cfg, &testPeers{n: c.replicas})
+			l.now = func() time.Time { return now }
+
+			for i, s := range c.steps {
+				now = now.Add(s.advance)
+				if s.tokens > 0 {
+					l.ConsumeTokens(s.caller, s.tokens)
+					continue
+				}
+				retryAfter, allowed := l.Allow(s.caller)
+				require.Equal(t, s.expAllowed, allowed, "step %d: allowed", i)
+				require.InDelta(t, s.expRetryAfter, retryAfter, float64(time.Millisecond), "step %d: retry after", i)
+			}
+		})
+	}
+}
+
+func TestLimiterKey(t *testing.T) {
+	selectors := []string{"tenancy=org-abc", "user=sam"}
+
+	l := NewLimiter(config.RateLimiting{KeyBy: config.RateLimitKeyByCaller}, nil)
+	require.Equal(t, "caller-a", l.Key("caller-a", selectors))
+
+	l = NewLimiter(config.RateLimiting{KeyBy: config.RateLimitKeyByLabelSelector}, nil)
+	require.Equal(t, "tenancy=org-abc,user=sam", l.Key("caller-a", selectors))
+}
+
+func TestLimiterSweep(t *testing.T) {
+	now := time.Now()
+	l := NewLimiter(config.RateLimiting{
+		Default: config.RateLimit{RequestsPerMinute: 60},
+	}, nil)
+	l.now = func() time.Time { return now }
+
+	_, allowed := l.Allow("a")
+	require.True(t, allowed)
+	require.Len(t, l.callers, 1)
+
+	now = now.Add(2 * time.Minute)
+	_, allowed = l.Allow("b")
+	require.True(t, allowed)
+	require.Len(t, l.callers, 1, "state of idle caller should be removed")
+}
+
+func TestLimiterShare(t *testing.T) {
+	l := NewLimiter(config.RateLimiting{}, nil)
+	require.Equal(t, 60.0, l.share(60), "without peers the replica enforces the whole limit")
+
+	peers := &testPeers{}
+	l = NewLimiter(config.RateLimiting{}, peers)
+	require.Equal(t, 60.0, l.share(60), "replicas that were not discovered yet enforce the whole limit")
+	peers.n = 3
+	require.Equal(t, 20.0, l.share(60))
+	require.Equal(t, 0.0, l.share(0))
+}
+
+func TestLimiterReplicasChange(t *testing.T) {
+	now := time.Now()
+	peers := &testPeers{n: 2}
+	l := NewLimiter(config.RateLimiting{
+		Default: config.RateLimit{RequestsPerMinute: 60},
+	}, peers)
+	l.now = func() time.Time { return now }
+
+	// The bucket holds the share of 2 replicas.
+	for range 30 {
+		_, allowed := l.Allow("a")
+		require.True(t, allowed)
+	}
+	_, allowed := l.Allow("a")
+	require.False(t, allowed)
+
+	// The bucket is refilled at the rate of the share of 3 replicas
+	// once another replica is discovered.
+	peers.n = 3
+	now = now.Add(3 * time.Second)
+	_, allowed = l.Allow("a")
+	require.True(t, allowed)
+	_, allowed = l.Allow("a")
+	require.False(t, allowed)
+	now = now.Add(time.Minute)
+	for range 20 {
+		_, allowed := l.Allow("a")
+		require.True(t, allowed)
+	}
+	_, allowed = l.Allow("a")
+	require.False(t, allowed, "the bucket should not hold more than the share of 3 replicas")
+}
+
+type testPeers struct {
+	n int
+}
+
+func (p *testPeers) GetSelfIPs() []string {
+	return make([]string, p.n)
+}
@@ -0,0 +1,65 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCron(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * FOO *",
+	} {
+		_, err := ParseCron(expr)
+		require.Error(t, err, expr)
+	}
+}
+
+func TestCronMatches(t *testing.T) {
+	// Monday.
+	mon0830 := time.Date(2024, time.January, 1, 8, 30, 0, 0, time.UTC)
+
+	cases := []struct {
+		expr string
+		t    time.Time
+		exp  bool
+	}{
+		{"* * * * *", mon0830, true},
+		{"30 8 * * *", mon0830, true},
+		{"31 8 * * *", mon0830, false},
+		{"* 8-17 * * MON-FRI", mon0830, true},
+		{"* 8-17 * * mon-fri", mon0830.Add(5 * 24 * time.Hour), false},
+		{"* 9-17 * * 1-5", mon0830, false},
+		{"*/15 * * * *", mon0830, true},
+		{"*/20 * * * *", mon0830, false},
+		{"10/20 * * * *", mon0830, true},
+		{"0,30 8,20 * * *", mon0830, true},
+		{"* * * JAN *", mon0830, true},
+		{"* * * 2-12 *", mon0830, false},
+		// Sunday as 0 and 7.
+		{"* * * * 0", mon0830.Add(-24 * time.Hour), true},
+		{"* * * * 7", mon0830.Add(-24 * time.Hour), true},
+		// Either the day of month or the day of week has to match
+		// when both are restricted.
+		{"* * 15 * MON", mon0830, true},
+		{"* * 1 * TUE", mon0830, true},
+		{"* * 15 * TUE", mon0830, false},
+		{"* * 15 * *", mon0830, false},
+	}
+	for _, c := range cases {
+		cron, err := ParseCron(c.expr)
+		require.NoError(t, err, c.expr)
+		require.Equal(t, c.exp, cron.Matches(c.t), "%q at %v", c.expr, c.t)
+	}
+}
@@ -0,0 +1,119 @@
+// Package schedule applies the scheduled scaling windows of Models.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	kubeaiv1 "github.com/kubeai-project/kubeai/api/k8s/v1"
+)
+
+// ReplicaBounds returns the min and max replicas of the Model at the given
+// time. The bounds of the first active window of the schedule of the Model
+// override the bounds of the Model. Invalid windows are ignored.
+func ReplicaBounds(model *kubeaiv1.Model, now time.Time) (int32, *int32) {
+	windows, err := parseWindows(model)
+	if err != nil {
+		log.Printf("Ignoring invalid schedule windows of model %q: %v", model.Name, err)
+	}
+	return replicaBounds(model, windows, now)
+}
+
+// ScaledToZeroUntil returns the time at which the schedule of the Model stops
+// keeping it at zero replicas (the first minute without an active window that
+// sets MaxReplicas to 0). It returns false if the Model is not kept at zero
+// replicas at the given time. The end of the windows is searched for up to a
+// week ahead.
+func ScaledToZeroUntil(model *kubeaiv1.Model, now time.Time) (time.Time, bool) {
+	windows, _ := parseWindows(model)
+	if !scaledToZero(model, windows, now) {
+		return time.Time{}, false
+	}
+	t := now.Truncate(time.Minute)
+	for end := now.Add(scaledToZeroSearch); t.Before(end); t = t.Add(time.Minute) {
+		if !scaledToZero(model, windows, t) {
+			break
+		}
+	}
+	return t, true
+}
+
+// scaledToZeroSearch limits how far ahead ScaledToZeroUntil searches for the
+// end of the windows.
+const scaledToZeroSearch = 7 * 24 * time.Hour
+
+// Validate returns an error for every window of the Model that has an invalid
+// cron expression or time zone.
+func Validate(model *kubeaiv1.Model) error {
+	_, err := parseWindows(model)
+	return err
+}
+
+type window struct {
+	kubeaiv1.ScheduleWindow
+	cron *Cron
+	loc  *time.Location
+}
+
+// parseWindows parses the schedule windows of the Model. Invalid windows are
+// left out of the result and reported in the returned error.
+func parseWindows(model *kubeaiv1.Model) ([]window, error) {
+	var (
+		windows []window
+		errs    error
+	)
+	for i, w := range model.Spec.Schedule {
+		pw, err := parseWindow(w)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("window %d: %w", i, err))
+			continue
+		}
+		windows = append(windows, pw)
+	}
+	return windows, errs
+}
+
+func parseWindow(w kubeaiv1.ScheduleWindow) (window, error) {
+	cron, err := ParseCron(w.Cron)
+	if err != nil {
+		return window{}, fmt.Errorf("parsing cron %q: %w", w.Cron, err)
+	}
+	loc := time.UTC
+	if w.TimeZone != "" {
+		loc, err = time.LoadLocation(w.TimeZone)
+		if err != nil {
+			return window{}, fmt.Errorf("loading time zone: %w", err)
+		}
+	}
+	return window{ScheduleWindow: w, cron: cron, loc: loc}, nil
+}
+
+func replicaBounds(model *kubeaiv1.Model, windows []window, now time.Time) (int32, *int32) {
+	min, max := model.Spec.MinReplicas, model.Spec.MaxReplicas
+
+	for _, w := range windows {
+		if !w.cron.Matches(now.In(w.loc)) {
+			continue
+		}
+		if w.MinReplicas != nil {
+			min = *w.MinReplicas
+		}
+		if w.MaxReplicas != nil {
+			max = w.MaxReplicas
+		}
+		break
+	}
+
+	// The window might only override one of the bounds.
+	if max != nil && min > *max {
+		min = *max
+	}
+	return min, max
+}
+
+func scaledToZero(model *kubeaiv1.Model, windows []window, now time.Time) bool {
+	_, max := replicaBounds(model, windows, now)
+	return max != nil && *max == 0
+}
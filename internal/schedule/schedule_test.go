@@ -0,0 +1,114 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	v1 "github.com/kubeai-project/kubeai/api/k8s/v1"
+	"github.com/stretchr/testify/require"
+	"k8s.io/utils/ptr"
+)
+
+func TestReplicaBounds(t *testing.T) {
+	// Monday, 8:30 in New York.
+	now := time.Date(2024, time.January, 1, 13, 30, 0, 0, time.UTC)
+
+	cases := map[string]struct {
+		spec   v1.ModelSpec
+		expMin int32
+		expMax *int32
+	}{
+		"no schedule": {
+			spec:   v1.ModelSpec{MinReplicas: 1, MaxReplicas: ptr.To[int32](5)},
+			expMin: 1,
+			expMax: ptr.To[int32](5),
+		},
+		"inactive window": {
+			spec: v1.ModelSpec{
+				MinReplicas: 1,
+				MaxReplicas: ptr.To[int32](5),
+				Schedule: []v1.ScheduleWindow{
+					{Cron: "* 9-17 * * MON-FRI", TimeZone: "America/New_York", MinReplicas: ptr.To[int32](3)},
+				},
+			},
+			expMin: 1,
+			expMax: ptr.To[int32](5),
+		},
+		"first active window": {
+			spec: v1.ModelSpec{
+				MinReplicas: 1,
+				MaxReplicas: ptr.To[int32](5),
+				Schedule: []v1.ScheduleWindow{
+					{Cron: "* 8-17 * * MON-FRI", TimeZone: "America/New_York", MinReplicas: ptr.To[int32](3), MaxReplicas: ptr.To[int32](10)},
+					{Cron: "* * * * *", MaxReplicas: ptr.To[int32](0)},
+				},
+			},
+			expMin: 3,
+			expMax: ptr.To[int32](10),
+		},
+		"scale to zero": {
+			spec: v1.ModelSpec{
+				MinReplicas: 1,
+				Schedule: []v1.ScheduleWindow{
+					{Cron: "* 0-6 * * *", MaxReplicas: ptr.To[int32](0)},
+					{Cron: "* 13 * * *", MaxReplicas: ptr.To[int32](0)},
+				},
+			},
+			expMin: 0,
+			expMax: ptr.To[int32](0),
+		},
+		"invalid windows are ignored": {
+			spec: v1.ModelSpec{
+				MinReplicas: 1,
+				Schedule: []v1.ScheduleWindow{
+					{Cron: "* * * *", MinReplicas: ptr.To[int32](2)},
+					{Cron: "* * * * *", TimeZone: "Invalid/Zone", MinReplicas: ptr.To[int32](3)},
+				},
+			},
+			expMin: 1,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			min, max := ReplicaBounds(&v1.Model{Spec: c.spec}, now)
+			require.Equal(t, c.expMin, min)
+			require.Equal(t, c.expMax, max)
+		})
+	}
+}
+
+func TestScaledToZeroUntil(t *testing.T) {
+	model := &v1.Model{Spec: v1.ModelSpec{
+		MinReplicas: 1,
+		Schedule: []v1.ScheduleWindow{
+			{Cron: "* 0-5 * * *", TimeZone: "America/New_York", MaxReplicas: ptr.To[int32](0)},
+		},
+	}}
+
+	// Monday, 3:30 in New York.
+	until, ok := ScaledToZeroUntil(model, time.Date(2024, time.January, 1, 8, 30, 15, 0, time.UTC))
+	require.True(t, ok)
+	require.Equal(t, time.Date(2024, time.January, 1, 11, 0, 0, 0, time.UTC), until)
+
+	// Monday, 8:30 in New York.
+	_, ok = ScaledToZeroUntil(model, time.Date(2024, time.January, 1, 13, 30, 0, 0, time.UTC))
+	require.False(t, ok)
+}
+
+func TestValidate(t *testing.T) {
+	require.NoError(t, Validate(&v1.Model{Spec: v1.ModelSpec{
+		Schedule: []v1.ScheduleWindow{
+			{Cron: "* 8-17 * * MON-FRI", TimeZone: "America/New_York"},
+		},
+	}}))
+
+	err := Validate(&v1.Model{Spec: v1.ModelSpec{
+		Schedule: []v1.ScheduleWindow{
+			{Cron: "* 8-17 * * MON-FRI"},
+			{Cron: "* 25 * * *"},
+			{Cron: "* * * * *", TimeZone: "Invalid/Zone"},
+		},
+	}})
+	require.ErrorContains(t, err, "window 1: parsing cron")
+	require.ErrorContains(t, err, "window 2: loading time zone")
+}
@@ -0,0 +1,190 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kubeai-project/kubeai/internal/apikeys"
+	"github.com/kubeai-project/kubeai/internal/openaiserver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAPIKeys(t *testing.T) {
+	sysCfg := baseSysCfg(t)
+	sysCfg.APIKeys.Enabled = true
+	initTest(t, sysCfg)
+
+	const (
+		tenancyLabelKey = "tenancy"
+		tenantA         = "team-a"
+		tenantB         = "team-b"
+
+		keyA     = "key-for-team-a"
+		keyAdmin = "key-for-admin"
+	)
+
+	// Model with an active backend to send requests to.
+	mA := modelForTest(t)
+	mA.Name = mA.Name + "a"
+	mA.Labels[tenancyLabelKey] = tenantA
+	require.NoError(t, testK8sClient.Create(testCtx, mA))
+
+	testModelBackend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Println("Serving request from testBackend")
+		w.WriteHeader(200)
+	}))
+	updateModelWithBackend(t, mA, testModelBackend)
+	updateModel(t, mA, func() {
+		mA.Spec.MinReplicas = 1
+	}, "Set MinReplicas to 1")
+	requireModelPods(t, mA, 1, "Min replica Pod should be created", 5*time.Second)
+	markAllModelPodsReady(t, mA)
+
+	mB := modelForTest(t)
+	mB.Name = mB.Name + "b"
+	mB.Labels[tenancyLabelKey] = tenantB
+	require.NoError(t, testK8sClient.Create(testCtx, mB))
+
+	for name, spec := range map[string]struct {
+		key      string
+		selector string
+	}{
+		"api-key-team-a": {key: keyA, selector: tenancyLabelKey + "=" + tenantA},
+		"api-key-admin":  {key: keyAdmin},
+	} {
+		require.NoError(t, testK8sClient.Create(testCtx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: testNS,
+				Labels:    map[string]string{apikeys.SecretLabel: "true"},
+			},
+			StringData: map[string]string{
+				apikeys.SecretHashKey:          apikeys.Hash(spec.key),
+				apikeys.SecretLabelSelectorKey: spec.selector,
+			},
+		}))
+	}
+
+	inferenceCases := map[string]struct {
+		modelName       string
+		key             string
+		selectorHeaders []string
+		expCode         int
+	}{
+		"missing key": {
+			modelName: mA.Name,
+			expCode:   http.StatusUnauthorized,
+		},
+		"invalid key": {
+			modelName: mA.Name,
+			key:       "not-a-valid-key",
+			expCode:   http.StatusUnauthorized,
+		},
+		"key allows model": {
+			modelName: mA.Name,
+			key:       keyA,
+			expCode:   http.StatusOK,
+		},
+		"key does not allow model": {
+			modelName: mB.Name,
+			key:       keyA,
+			expCode:   http.StatusNotFound,
+		},
+		"key does not allow model even with spoofed selector": {
+			modelName:       mB.Name,
+			key:             keyA,
+			selectorHeaders: []string{tenancyLabelKey + "=" + tenantB},
+			expCode:         http.StatusNotFound,
+		},
+		"unrestricted key": {
+			modelName: mA.Name,
+			key:       keyAdmin,
+			expCode:   http.StatusOK,
+		},
+	}
+	for name, c := range inferenceCases {
+		t.Run("inference "+name, func(t *testing.T) {
+			require.EventuallyWithT(t, func(t *assert.CollectT) {
+				body := []byte(fmt.Sprintf(`{"model": %q}`, c.modelName))
+				req, err := http.NewRequest(http.MethodPost, "http://localhost:8000/openai/v1/completions", bytes.NewReader(body))
+				if !assert.NoError(t, err) {
+					return
+				}
+				if c.key != "" {
+					req.Header.Set("Authorization", "Bearer "+c.key)
+				}
+				for _, selector := range c.selectorHeaders {
+					req.Header.Add("X-Label-Selector", selector)
+				}
+				res, err := testHTTPClient.Do(req)
+				if !assert.NoError(t, err) {
+					return
+				}
+				defer res.Body.Close()
+				assert.Equal(t, c.expCode, res.StatusCode)
+			}, 5*time.Second, time.Second/10, name)
+		})
+	}
+
+	listCases := map[string]struct {
+		key       string
+		expCode   int
+		expModels []string
+	}{
+		"missing key": {
+			expCode: http.StatusUnauthorized,
+		},
+		"restricted key": {
+			key:       keyA,
+			expCode:   http.StatusOK,
+			expModels: []string{mA.Name},
+		},
+		"unrestricted key": {
+			key:       keyAdmin,
+			expCode:   http.StatusOK,
+			expModels: []string{mA.Name, mB.Name},
+		},
+	}
+	for name, c := range listCases {
+		t.Run("list "+name, func(t *testing.T) {
+			require.EventuallyWithT(t, func(t *assert.CollectT) {
+				req, err := http.NewRequest(http.MethodGet, "http://localhost:8000/openai/v1/models", nil)
+				if !assert.NoError(t, err) {
+					return
+				}
+				if c.key != "" {
+					req.Header.Set("Authorization", "Bearer "+c.key)
+				}
+				res, err := testHTTPClient.Do(req)
+				if !assert.NoError(t, err) {
+					return
+				}
+				defer res.Body.Close()
+				if !assert.Equal(t, c.expCode, res.StatusCode) || c.expCode != http.StatusOK {
+					return
+				}
+
+				var respBody struct {
+					Data []openaiserver.Model `json:"data"`
+				}
+				if !assert.NoError(t, json.NewDecoder(res.Body).Decode(&respBody)) {
+					return
+				}
+				ids := make([]string, len(respBody.Data))
+				for i, m := range respBody.Data {
+					ids[i] = m.ID
+				}
+				assert.ElementsMatch(t, c.expModels, ids)
+			}, 5*time.Second, time.Second/10, name)
+		})
+	}
+}
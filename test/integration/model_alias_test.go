@@ -0,0 +1,70 @@
+package integration
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	v1 "github.com/kubeai-project/kubeai/api/k8s/v1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestModelAlias tests that requests for a ModelAlias are routed to the
+// backing Models.
+func TestModelAlias(t *testing.T) {
+	sysCfg := baseSysCfg(t)
+	initTest(t, sysCfg)
+
+	backendModel := func(suffix string) *v1.Model {
+		m := modelForTest(t)
+		m.Name = m.Name + suffix
+		require.NoError(t, testK8sClient.Create(testCtx, m))
+
+		backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("served by " + suffix))
+		}))
+		t.Cleanup(backend.Close)
+		updateModelWithBackend(t, m, backend)
+		updateModel(t, m, func() {
+			m.Spec.MinReplicas = 1
+		}, "Set MinReplicas to 1")
+		requireModelPods(t, m, 1, "Min replica Pod should be created", 5*time.Second)
+		markAllModelPodsReady(t, m)
+		return m
+	}
+	mOld := backendModel("old")
+	mNew := backendModel("new")
+
+	alias := &v1.ModelAlias{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "alias-" + mOld.Name,
+			Namespace: testNS,
+			Labels:    mOld.Labels,
+		},
+		Spec: v1.ModelAliasSpec{
+			Routes: []v1.ModelAliasRoute{
+				{
+					Backends: []v1.ModelAliasBackend{
+						{Model: mOld.Name, Weight: 0},
+						{Model: mNew.Name, Weight: 1},
+					},
+				},
+			},
+		},
+	}
+	require.NoError(t, testK8sClient.Create(testCtx, alias))
+	t.Cleanup(func() {
+		if err := testK8sClient.Delete(testCtx, alias); err != nil {
+			t.Logf("Cleanup: deleting ModelAlias: %v", err)
+		}
+	})
+
+	selectors := []string{modelLabelSelectorForTest(t)}
+	requireOpenAIModelList(t, selectors, []string{mOld.Name, mNew.Name, alias.Name}, "Alias should be listed")
+
+	// The alias is in the cache once it is listed.
+	sendOpenAIInferenceRequest(t, alias.Name, selectors, http.StatusOK, "served by new", "Request to alias")
+}